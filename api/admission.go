@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// ErrRateLimited is returned when a workflow start is rejected by admission control.
+var ErrRateLimited = errors.New("rate limited")
+
+// retryAfterError tells the caller when to retry.
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// retryAfter returns how long the caller of a rejected request should wait, if known.
+func retryAfter(err error) (time.Duration, bool) {
+	var ra *retryAfterError
+	if errors.As(err, &ra) {
+		return ra.after, true
+	}
+	return 0, false
+}
+
+// WithLimits sets the initial admission limits for workflow starts.
+func WithLimits(limits config.Admission) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+const (
+	// maxBucketKeys bounds the per-tenant and per-caller buckets kept in memory. Buckets that
+	// have refilled are dropped first when it is reached.
+	maxBucketKeys = 10000
+	// inflightRecheckInterval throttles how often tracked instances are checked for
+	// completion once the in-flight limit is reached.
+	inflightRecheckInterval = time.Second
+	inflightRecheckWorkers  = 16
+)
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last call and reports whether the bucket is full.
+func (b *bucket) refill(l config.RateLimit, now time.Time) bool {
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	return b.tokens >= float64(l.Burst)
+}
+
+// wait returns how long until the bucket holds a token.
+func (b *bucket) wait(l config.RateLimit) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// buckets is a set of token buckets sharing a limit, keyed by tenant or caller.
+type buckets struct {
+	limit config.RateLimit
+	byKey map[string]*bucket
+}
+
+func newBuckets(l config.RateLimit) *buckets {
+	return &buckets{limit: l, byKey: make(map[string]*bucket)}
+}
+
+func (bs *buckets) get(key string, now time.Time) *bucket {
+	b, ok := bs.byKey[key]
+	if !ok {
+		if len(bs.byKey) >= maxBucketKeys {
+			for k, old := range bs.byKey {
+				if old.refill(bs.limit, now) {
+					delete(bs.byKey, k)
+				}
+			}
+		}
+		b = &bucket{tokens: float64(bs.limit.Burst), last: now}
+		bs.byKey[key] = b
+	}
+	b.refill(bs.limit, now)
+	return b
+}
+
+// admission enforces the start rate limits and the in-flight instance cap.
+type admission struct {
+	mu       sync.Mutex
+	limits   config.Admission
+	global   *buckets
+	tenants  *buckets
+	callers  *buckets
+	inflight map[string]struct{}
+	reserved int // slots admitted but not yet tracked or released
+	checked  time.Time
+
+	decisions metric.Int64Counter
+}
+
+func newAdmission(limits config.Admission) *admission {
+	a := &admission{inflight: make(map[string]struct{})}
+	a.setLimits(limits)
+
+	meter := otel.Meter("api.admission")
+	a.decisions, _ = meter.Int64Counter("admission.decisions",
+		metric.WithDescription("Workflow start admission decisions by limit and outcome"))
+	_, _ = meter.Int64ObservableGauge("admission.inflight",
+		metric.WithDescription("Instances started by this replica that have not completed"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			o.Observe(int64(a.reserved + len(a.inflight)))
+			return nil
+		}))
+	_, _ = meter.Float64ObservableGauge("admission.limit",
+		metric.WithDescription("Configured admission limits; rates are per second"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			l := a.Limits()
+			for name, rl := range map[string]config.RateLimit{"global": l.Global, "tenant": l.PerTenant, "caller": l.PerCaller} {
+				o.Observe(rl.Rate, metric.WithAttributes(attribute.String("limit", name), attribute.String("kind", "rate")))
+				o.Observe(float64(rl.Burst), metric.WithAttributes(attribute.String("limit", name), attribute.String("kind", "burst")))
+			}
+			o.Observe(float64(l.MaxInFlight), metric.WithAttributes(attribute.String("limit", "inflight"), attribute.String("kind", "max")))
+			return nil
+		}))
+	return a
+}
+
+// Limits returns the current limits.
+func (a *admission) Limits() config.Admission {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limits
+}
+
+// setLimits replaces the limits. Buckets restart full.
+func (a *admission) setLimits(limits config.Admission) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limits = limits
+	a.global = newBuckets(limits.Global)
+	a.tenants = newBuckets(limits.PerTenant)
+	a.callers = newBuckets(limits.PerCaller)
+}
+
+// admit takes a token from every bucket that applies to the caller of ctx, or none if any
+// of them is empty, and reserves an in-flight slot for the instance about to be scheduled.
+// The caller must hand the slot to track once the instance is scheduled, or back to release
+// if scheduling fails. check is called to drop completed instances when the in-flight cap is
+// reached.
+//
+// The in-flight count is kept per replica and starts from zero when the process restarts,
+// so MaxInFlight bounds what each replica has started since then, not the cluster.
+func (a *admission) admit(ctx context.Context, check func(ctx context.Context, ids []string) []string) error {
+	rechecked := false
+	for {
+		a.mu.Lock()
+		max := a.limits.MaxInFlight
+		if max <= 0 || a.reserved+len(a.inflight) < max {
+			err := a.takeTokens(ctx)
+			if err == nil {
+				a.reserved++
+			}
+			a.mu.Unlock()
+			return err
+		}
+		if rechecked || time.Since(a.checked) < inflightRecheckInterval {
+			a.mu.Unlock()
+			a.record(ctx, "inflight", false)
+			return &retryAfterError{
+				err:   fmt.Errorf("%w: %d instances in flight", ErrRateLimited, max),
+				after: inflightRecheckInterval,
+			}
+		}
+		a.checked = time.Now()
+		ids := make([]string, 0, len(a.inflight))
+		for id := range a.inflight {
+			ids = append(ids, id)
+		}
+		a.mu.Unlock()
+
+		done := check(ctx, ids)
+		a.mu.Lock()
+		for _, id := range done {
+			delete(a.inflight, id)
+		}
+		a.mu.Unlock()
+		rechecked = true
+	}
+}
+
+// takeTokens takes a token from every bucket that applies to the caller of ctx, or none if
+// any of them is empty. a.mu must be held.
+func (a *admission) takeTokens(ctx context.Context) error {
+	now := time.Now()
+	type applied struct {
+		name string
+		set  *buckets
+		b    *bucket
+	}
+	var apply []applied
+	if a.limits.Global.Rate > 0 {
+		apply = append(apply, applied{"global", a.global, a.global.get("", now)})
+	}
+	if t := tenant.FromContext(ctx); t != "" && a.limits.PerTenant.Rate > 0 {
+		apply = append(apply, applied{"tenant", a.tenants, a.tenants.get(t, now)})
+	}
+	if id, ok := IdentityFromContext(ctx); ok && a.limits.PerCaller.Rate > 0 {
+		apply = append(apply, applied{"caller", a.callers, a.callers.get(id.Method+"/"+id.Subject, now)})
+	}
+
+	for _, ap := range apply {
+		if d := ap.b.wait(ap.set.limit); d > 0 {
+			a.record(ctx, ap.name, false)
+			return &retryAfterError{
+				err:   fmt.Errorf("%w: %s start rate exceeded", ErrRateLimited, ap.name),
+				after: d,
+			}
+		}
+	}
+	for _, ap := range apply {
+		ap.b.tokens--
+	}
+	a.record(ctx, "all", true)
+	return nil
+}
+
+func (a *admission) record(ctx context.Context, limit string, admitted bool) {
+	outcome := "rejected"
+	if admitted {
+		outcome = "admitted"
+	}
+	a.decisions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("limit", limit),
+		attribute.String("outcome", outcome),
+	))
+}
+
+// track turns the slot reserved by admit into an in-flight instance, counted until it
+// completes.
+func (a *admission) track(instanceID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reserved--
+	if a.limits.MaxInFlight > 0 {
+		a.inflight[instanceID] = struct{}{}
+	}
+}
+
+// release gives back the slot reserved by admit when the instance could not be scheduled.
+func (a *admission) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reserved--
+}
+
+// completedInstances returns the instances among ids that completed or no longer exist.
+func (s *Service) completedInstances(ctx context.Context, ids []string) []string {
+	var (
+		mu   sync.Mutex
+		done []string
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, inflightRecheckWorkers)
+	)
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			meta, err := s.runtime.Client().FetchWorkflowMetadata(ctx, id)
+			if (err == nil && workflow.WorkflowMetadataIsComplete(meta)) || instances.IsNotFound(err) {
+				mu.Lock()
+				done = append(done, id)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return done
+}
+
+func setLimits(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var limits config.Admission
+	if err := json.NewDecoder(r.Body).Decode(&limits); err != nil {
+		http.Error(w, fmt.Sprintf("invalid limits: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := svc.SetLimits(limits); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, svc.Limits())
+}
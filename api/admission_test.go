@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+func TestBucket(t *testing.T) {
+	l := config.RateLimit{Rate: 2, Burst: 3}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &bucket{tokens: 0, last: now}
+
+	if d := b.wait(l); d != 500*time.Millisecond {
+		t.Errorf("empty bucket: wait %s, want 500ms", d)
+	}
+	if full := b.refill(l, now.Add(time.Second)); full || b.tokens != 2 || b.wait(l) != 0 {
+		t.Errorf("after 1s: %v tokens, full %v", b.tokens, full)
+	}
+	// Tokens never exceed the burst.
+	if full := b.refill(l, now.Add(time.Hour)); !full || b.tokens != 3 {
+		t.Errorf("after an hour: %v tokens, full %v", b.tokens, full)
+	}
+}
+
+// noneCompleted is an in-flight check that finds no instance completed.
+func noneCompleted(context.Context, []string) []string { return nil }
+
+func TestAdmissionRateLimits(t *testing.T) {
+	// The rates are low enough for no token to come back during the test.
+	a := newAdmission(config.Admission{
+		Global:    config.RateLimit{Rate: 0.001, Burst: 3},
+		PerTenant: config.RateLimit{Rate: 0.001, Burst: 1},
+	})
+	ctx := func(t string) context.Context { return tenant.WithContext(context.Background(), t) }
+
+	for i, tc := range []struct {
+		tenant string
+		want   bool
+	}{
+		{tenant: "acme", want: true},
+		// acme's bucket is empty; the rejected start does not take from the global one.
+		{tenant: "acme", want: false},
+		{tenant: "globex", want: true},
+		{tenant: "initech", want: true},
+		// The global bucket is empty now.
+		{tenant: "hooli", want: false},
+	} {
+		err := a.admit(ctx(tc.tenant), noneCompleted)
+		if (err == nil) != tc.want {
+			t.Fatalf("start %d as %s: %v, want admitted %v", i, tc.tenant, err, tc.want)
+		}
+		if err != nil {
+			d, ok := retryAfter(err)
+			if !errors.Is(err, ErrRateLimited) || !ok || d <= 0 {
+				t.Errorf("start %d as %s: %v, retry after %s", i, tc.tenant, err, d)
+			}
+			continue
+		}
+		a.track("id")
+	}
+
+	// New limits start with full buckets.
+	a.setLimits(config.Admission{Global: config.RateLimit{Rate: 0.001, Burst: 1}})
+	if err := a.admit(ctx("hooli"), noneCompleted); err != nil {
+		t.Errorf("start after new limits: %v", err)
+	}
+}
+
+func TestAdmissionInFlight(t *testing.T) {
+	a := newAdmission(config.Admission{MaxInFlight: 2})
+	var checked [][]string
+	check := func(_ context.Context, ids []string) []string {
+		checked = append(checked, ids)
+		return nil
+	}
+
+	// Slots are reserved on admission, before the instances are tracked.
+	for range 2 {
+		if err := a.admit(context.Background(), check); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.admit(context.Background(), check); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("third start: %v, want %v", err, ErrRateLimited)
+	}
+	if len(checked) != 1 || len(checked[0]) != 0 {
+		t.Errorf("checked %v, want one check of no tracked instances", checked)
+	}
+
+	// A slot given back by a start that failed is free again.
+	a.release()
+	if err := a.admit(context.Background(), check); err != nil {
+		t.Fatalf("start after release: %v", err)
+	}
+	a.track("a")
+	a.track("b")
+	if a.reserved != 0 || len(a.inflight) != 2 {
+		t.Fatalf("%d reserved and %v in flight, want a and b tracked", a.reserved, a.inflight)
+	}
+
+	// Completed instances are dropped when the cap is reached, at most once per interval.
+	a.checked = time.Time{}
+	done := func(_ context.Context, ids []string) []string {
+		checked = append(checked, ids)
+		return []string{"a"}
+	}
+	if err := a.admit(context.Background(), done); err != nil {
+		t.Fatalf("start once a completed: %v", err)
+	}
+	a.track("c")
+	checked = nil
+	if err := a.admit(context.Background(), done); !errors.Is(err, ErrRateLimited) || checked != nil {
+		t.Errorf("start right after a check: %v, checked %v, want rejected without checking", err, checked)
+	}
+}
+
+func TestStartReleasesSlotOnFailure(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"), WithLimits(config.Admission{MaxInFlight: 1}))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+	start := func(id string) int {
+		return call(mux, http.MethodPost, "/workflows", "", `{"workflow":"SimpleWorkflow","instanceId":"`+id+`"}`).Code
+	}
+
+	sidecar.Fail("StartInstance", errors.New("sidecar unavailable"))
+	if code := start("order-1"); code != http.StatusInternalServerError {
+		t.Fatalf("failed start: %d", code)
+	}
+	sidecar.Fail("StartInstance", nil)
+	if code := start("order-1"); code != http.StatusAccepted {
+		t.Fatalf("start after a failed one: %d, want %d", code, http.StatusAccepted)
+	}
+
+	rec := call(mux, http.MethodPost, "/workflows", "", `{"workflow":"SimpleWorkflow","instanceId":"order-2"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("start beyond the cap: %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Once order-1 completes, its slot is given to the next start.
+	in, _ := sidecar.Instance("order-1")
+	in.Status = protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED
+	sidecar.Put(in)
+	svc.admission.checked = time.Time{}
+	if code := start("order-2"); code != http.StatusAccepted {
+		t.Errorf("start after order-1 completed: %d, want %d", code, http.StatusAccepted)
+	}
+}
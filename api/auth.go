@@ -0,0 +1,271 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	workflowv1 "github.com/javier-aliaga/dapr-go-samples/proto/workflow/v1"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// Role gates what an authenticated caller may do. Each role includes the ones below it.
+type Role string
+
+const (
+	// RoleViewer may read workflow status.
+	RoleViewer Role = "viewer"
+	// RoleOperator may also start workflows and raise events.
+	RoleOperator Role = "operator"
+	// RoleAdmin may also terminate and purge workflows.
+	RoleAdmin Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// ParseRole returns the role named s.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if roleRank[r] == 0 {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return r, nil
+}
+
+// Allows reports whether r includes required.
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] > 0 && roleRank[r] >= roleRank[required]
+}
+
+// Errors returned when a caller is not let through.
+var (
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Subject string
+	Role    Role
+	// Tenant is the tenant the caller is bound to, or "" if it may pick any.
+	Tenant string
+	// Method is how the caller authenticated, "api_key" or "jwt".
+	Method string
+}
+
+// Credentials are the credentials presented with a request.
+type Credentials struct {
+	APIKey      string
+	BearerToken string
+}
+
+// Authenticator resolves credentials to an identity. Implementations return an error
+// wrapping ErrUnauthenticated for missing or invalid credentials.
+type Authenticator interface {
+	Authenticate(ctx context.Context, creds Credentials) (Identity, error)
+}
+
+// WithAuthenticator requires callers of the HTTP and gRPC APIs to authenticate with a.
+// A nil authenticator leaves the APIs open.
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = a
+	}
+}
+
+// NewAuthenticator builds the authenticator described by cfg. It returns nil when cfg
+// configures neither API keys nor JWTs.
+func NewAuthenticator(cfg config.Auth) (Authenticator, error) {
+	var auth multiAuthenticator
+	if len(cfg.APIKeys) > 0 {
+		keys, err := NewAPIKeyAuthenticator(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		auth.apiKeys = keys
+	}
+	if cfg.JWT.JWKSFile != "" {
+		jwt, err := NewJWTAuthenticator(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		auth.jwt = jwt
+	}
+	if auth.apiKeys == nil && auth.jwt == nil {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// multiAuthenticator dispatches on the kind of credentials presented.
+type multiAuthenticator struct {
+	apiKeys Authenticator
+	jwt     Authenticator
+}
+
+func (m multiAuthenticator) Authenticate(ctx context.Context, creds Credentials) (Identity, error) {
+	switch {
+	case creds.APIKey != "" && m.apiKeys != nil:
+		return m.apiKeys.Authenticate(ctx, creds)
+	case creds.BearerToken != "" && m.jwt != nil:
+		return m.jwt.Authenticate(ctx, creds)
+	default:
+		return Identity{}, fmt.Errorf("%w: no supported credentials", ErrUnauthenticated)
+	}
+}
+
+// APIKeyAuthenticator accepts static API keys, looked up by their SHA-256.
+type APIKeyAuthenticator struct {
+	keys map[[sha256.Size]byte]Identity
+}
+
+// NewAPIKeyAuthenticator returns an authenticator for the given keys.
+func NewAPIKeyAuthenticator(keys []config.APIKey) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{keys: make(map[[sha256.Size]byte]Identity, len(keys))}
+	for _, k := range keys {
+		role, err := ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api key %s: %w", k.Name, err)
+		}
+		if k.Tenant != "" {
+			if err := tenant.Validate(k.Tenant); err != nil {
+				return nil, fmt.Errorf("api key %s: %w", k.Name, err)
+			}
+		}
+		b, err := hex.DecodeString(k.SHA256)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("api key %s: invalid sha256", k.Name)
+		}
+		a.keys[[sha256.Size]byte(b)] = Identity{Subject: k.Name, Role: role, Tenant: k.Tenant, Method: "api_key"}
+	}
+	return a, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(_ context.Context, creds Credentials) (Identity, error) {
+	id, ok := a.keys[sha256.Sum256([]byte(creds.APIKey))]
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: unknown api key", ErrUnauthenticated)
+	}
+	return id, nil
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the caller authenticated for the request, if any.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// authorize authenticates creds, checks the resulting role against required and records
+// the caller on the current span. It returns the context carrying the identity.
+func authorize(ctx context.Context, a Authenticator, creds Credentials, required Role) (context.Context, error) {
+	id, err := a.Authenticate(ctx, creds)
+	if err != nil {
+		return ctx, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("enduser.id", id.Subject),
+		attribute.String("enduser.role", string(id.Role)),
+		attribute.String("auth.method", id.Method),
+	)
+
+	if !id.Role.Allows(required) {
+		return ctx, fmt.Errorf("%w: %s needs the %s role", ErrPermissionDenied, id.Subject, required)
+	}
+	return context.WithValue(ctx, identityKey{}, id), nil
+}
+
+// requireRole wraps h so that only callers with the required role reach it.
+func requireRole(a Authenticator, required Role, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		creds := Credentials{APIKey: r.Header.Get("X-API-Key")}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			creds.BearerToken = strings.TrimSpace(token)
+		}
+
+		ctx, err := authorize(r.Context(), a, creds, required)
+		if err != nil {
+			if errors.Is(err, ErrUnauthenticated) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			writeError(w, err)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// grpcRoles is the role required by each gRPC method. Methods that are not listed need
+// the admin role.
+var grpcRoles = map[string]Role{
+	workflowv1.WorkflowService_StartWorkflow_FullMethodName: RoleOperator,
+	workflowv1.WorkflowService_GetWorkflow_FullMethodName:   RoleViewer,
+	workflowv1.WorkflowService_RaiseEvent_FullMethodName:    RoleOperator,
+	workflowv1.WorkflowService_Terminate_FullMethodName:     RoleAdmin,
+	workflowv1.WorkflowService_List_FullMethodName:          RoleViewer,
+	workflowv1.WorkflowService_WatchWorkflow_FullMethodName: RoleViewer,
+}
+
+func grpcRole(method string) Role {
+	if r, ok := grpcRoles[method]; ok {
+		return r
+	}
+	return RoleAdmin
+}
+
+// grpcCredentials reads the x-api-key and authorization metadata.
+func grpcCredentials(ctx context.Context) Credentials {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var creds Credentials
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		creds.APIKey = v[0]
+	}
+	if v := md.Get("authorization"); len(v) > 0 {
+		if token, ok := strings.CutPrefix(v[0], "Bearer "); ok {
+			creds.BearerToken = strings.TrimSpace(token)
+		}
+	}
+	return creds
+}
+
+func unaryAuthInterceptor(a Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, a, grpcCredentials(ctx), grpcRole(info.FullMethod))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuthInterceptor(a Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), a, grpcCredentials(ss.Context()), grpcRole(info.FullMethod))
+		if err != nil {
+			return grpcError(err)
+		}
+		return handler(srv, &scopedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// scopedStream overrides the context of a ServerStream.
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *scopedStream) Context() context.Context {
+	return s.ctx
+}
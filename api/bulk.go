@@ -0,0 +1,451 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// Bulk operations.
+const (
+	BulkTerminate = "terminate"
+	BulkPurge     = "purge"
+	BulkSuspend   = "suspend"
+	BulkResume    = "resume"
+)
+
+// Bulk job states.
+const (
+	BulkRunning   = "running"
+	BulkCompleted = "completed"
+	BulkCanceled  = "canceled"
+	BulkFailed    = "failed"
+)
+
+const (
+	defaultBulkConcurrency = 10
+	maxBulkConcurrency     = 50
+	bulkPageSize           = 500
+	// maxBulkRunning bounds the jobs running at once, and maxBulkJobs the jobs kept in
+	// memory. The oldest finished jobs are forgotten first.
+	maxBulkRunning = 4
+	maxBulkJobs    = 100
+	// Only the first matches and errors of a job are kept.
+	maxBulkPreview = 1000
+	maxBulkErrors  = 100
+)
+
+var runtimeStatuses = []string{
+	"RUNNING", "COMPLETED", "CONTINUED_AS_NEW", "FAILED", "CANCELED",
+	"TERMINATED", "PENDING", "SUSPENDED", "STALLED",
+}
+
+// BulkFilter selects the instances of a bulk operation. Every field that is set must match.
+type BulkFilter struct {
+	Workflow string `json:"workflow,omitempty"`
+	// RuntimeStatus matches any of the listed statuses, such as RUNNING or FAILED.
+	RuntimeStatus []string   `json:"runtimeStatus,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+	// IDPrefix matches the start of the (tenant-local) instance ID.
+	IDPrefix string `json:"idPrefix,omitempty"`
+}
+
+func (f BulkFilter) validate() error {
+	if f.Workflow == "" && len(f.RuntimeStatus) == 0 && f.CreatedBefore == nil && f.IDPrefix == "" {
+		return errors.New("filter needs at least one field")
+	}
+	for _, st := range f.RuntimeStatus {
+		if !slices.Contains(runtimeStatuses, st) {
+			return fmt.Errorf("unknown runtime status %q", st)
+		}
+	}
+	return nil
+}
+
+// needsMetadata reports whether matching takes more than the instance ID.
+func (f BulkFilter) needsMetadata() bool {
+	return f.Workflow != "" || len(f.RuntimeStatus) > 0 || f.CreatedBefore != nil
+}
+
+func (f BulkFilter) matches(meta *workflow.WorkflowMetadata) bool {
+	if f.Workflow != "" && meta.Name != f.Workflow {
+		return false
+	}
+	if len(f.RuntimeStatus) > 0 && !slices.Contains(f.RuntimeStatus, meta.String()) {
+		return false
+	}
+	if f.CreatedBefore != nil && (meta.CreatedAt == nil || !meta.CreatedAt.AsTime().Before(*f.CreatedBefore)) {
+		return false
+	}
+	return true
+}
+
+// BulkRequest describes a bulk operation.
+type BulkRequest struct {
+	// Operation is terminate, purge, suspend or resume.
+	Operation string     `json:"operation"`
+	Filter    BulkFilter `json:"filter"`
+	// DryRun only counts and previews the matching instances.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Reason is recorded by terminate, suspend and resume.
+	Reason string `json:"reason,omitempty"`
+	// Concurrency bounds the instances processed at once. Defaults to 10.
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// BulkJob reports the progress of a bulk operation. Instances are checked against the
+// filter and processed as they are listed, a page at a time, so Candidates keeps growing
+// until the listing ends and Checked trails it.
+type BulkJob struct {
+	ID        string     `json:"id"`
+	Operation string     `json:"operation"`
+	Filter    BulkFilter `json:"filter"`
+	DryRun    bool       `json:"dryRun,omitempty"`
+	State     string     `json:"state"`
+	StartedBy string     `json:"startedBy,omitempty"`
+
+	// Listed counts the instance IDs listed, and Candidates the ones matching IDPrefix.
+	Listed     int `json:"listed"`
+	Candidates int `json:"candidates"`
+	Checked    int `json:"checked"`
+	Matched    int `json:"matched"`
+	Succeeded  int `json:"succeeded"`
+	Failed     int `json:"failed"`
+
+	// Preview holds the first matching instance IDs of a dry run.
+	Preview []string    `json:"preview,omitempty"`
+	Errors  []BulkError `json:"errors,omitempty"`
+	// Error is why the job failed or was canceled.
+	Error string `json:"error,omitempty"`
+
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// BulkError is the failure of the operation on one instance.
+type BulkError struct {
+	InstanceID string `json:"instanceId"`
+	Error      string `json:"error"`
+}
+
+// bulkJob is a BulkJob in progress.
+type bulkJob struct {
+	mu     sync.Mutex
+	tenant string
+	job    BulkJob
+	cancel context.CancelFunc
+}
+
+func (j *bulkJob) snapshot() BulkJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	job.Preview = slices.Clone(j.job.Preview)
+	job.Errors = slices.Clone(j.job.Errors)
+	return job
+}
+
+func (j *bulkJob) update(f func(job *BulkJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(&j.job)
+}
+
+func (j *bulkJob) fail(id string, err error) {
+	j.update(func(job *BulkJob) {
+		job.Failed++
+		if len(job.Errors) < maxBulkErrors {
+			job.Errors = append(job.Errors, BulkError{InstanceID: id, Error: err.Error()})
+		}
+	})
+}
+
+// bulkJobs holds the bulk jobs of a Service.
+type bulkJobs struct {
+	mu    sync.Mutex
+	jobs  map[string]*bulkJob
+	order []string
+}
+
+func newBulkJobs() *bulkJobs {
+	return &bulkJobs{jobs: make(map[string]*bulkJob)}
+}
+
+// add stores j, forgetting the oldest finished jobs beyond maxBulkJobs. It fails when
+// maxBulkRunning jobs are already running.
+func (b *bulkJobs) add(j *bulkJob) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	running := 0
+	for _, other := range b.jobs {
+		if other.snapshot().State == BulkRunning {
+			running++
+		}
+	}
+	if running >= maxBulkRunning {
+		return fmt.Errorf("%w: %d bulk jobs are already running", ErrUnavailable, running)
+	}
+
+	b.jobs[j.job.ID] = j
+	b.order = append(b.order, j.job.ID)
+	for i := 0; len(b.jobs) > maxBulkJobs && i < len(b.order); {
+		id := b.order[i]
+		if b.jobs[id].snapshot().State == BulkRunning {
+			i++
+			continue
+		}
+		delete(b.jobs, id)
+		b.order = slices.Delete(b.order, i, i+1)
+	}
+	return nil
+}
+
+// get returns the job with the given ID if it belongs to the tenant of ctx.
+func (b *bulkJobs) get(ctx context.Context, id string) (*bulkJob, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	j, ok := b.jobs[id]
+	if !ok || j.tenant != tenant.FromContext(ctx) {
+		return nil, fmt.Errorf("bulk job %s %w", id, ErrNotFound)
+	}
+	return j, nil
+}
+
+func (b *bulkJobs) list(ctx context.Context) []*bulkJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := tenant.FromContext(ctx)
+	var out []*bulkJob
+	for _, id := range b.order {
+		if j := b.jobs[id]; j.tenant == t {
+			out = append(out, j)
+		}
+	}
+	return out
+}
+
+// StartBulk starts a bulk operation in the background and returns the new job. The job
+// acts with the tenant of ctx and keeps running after ctx is done; use CancelBulk to stop
+// it.
+func (s *Service) StartBulk(ctx context.Context, req BulkRequest) (BulkJob, error) {
+	switch req.Operation {
+	case BulkTerminate, BulkPurge, BulkSuspend, BulkResume:
+	default:
+		return BulkJob{}, fmt.Errorf("%w: unknown bulk operation %q", ErrInvalidArgument, req.Operation)
+	}
+	if err := req.Filter.validate(); err != nil {
+		return BulkJob{}, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	if req.Concurrency < 0 || req.Concurrency > maxBulkConcurrency {
+		return BulkJob{}, fmt.Errorf("%w: concurrency must be between 1 and %d", ErrInvalidArgument, maxBulkConcurrency)
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = defaultBulkConcurrency
+	}
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	j := &bulkJob{
+		tenant: tenant.FromContext(ctx),
+		cancel: cancel,
+		job: BulkJob{
+			ID:        uuid.NewString(),
+			Operation: req.Operation,
+			Filter:    req.Filter,
+			DryRun:    req.DryRun,
+			State:     BulkRunning,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+	if id, ok := IdentityFromContext(ctx); ok {
+		j.job.StartedBy = id.Subject
+	}
+	if err := s.bulk.add(j); err != nil {
+		cancel()
+		return BulkJob{}, err
+	}
+
+	log.Infof("Bulk job %s started: %s %+v (dry run: %t)", j.job.ID, req.Operation, req.Filter, req.DryRun)
+	go s.runBulk(jobCtx, j, req)
+	return j.snapshot(), nil
+}
+
+// BulkJob returns a bulk job of the tenant of ctx.
+func (s *Service) BulkJob(ctx context.Context, id string) (BulkJob, error) {
+	j, err := s.bulk.get(ctx, id)
+	if err != nil {
+		return BulkJob{}, err
+	}
+	return j.snapshot(), nil
+}
+
+// BulkJobs returns the bulk jobs of the tenant of ctx, oldest first.
+func (s *Service) BulkJobs(ctx context.Context) []BulkJob {
+	jobs := s.bulk.list(ctx)
+	out := make([]BulkJob, 0, len(jobs))
+	for _, j := range jobs {
+		out = append(out, j.snapshot())
+	}
+	return out
+}
+
+// CancelBulk stops a running bulk job. Instances already processed stay processed.
+func (s *Service) CancelBulk(ctx context.Context, id string) (BulkJob, error) {
+	j, err := s.bulk.get(ctx, id)
+	if err != nil {
+		return BulkJob{}, err
+	}
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+func (s *Service) runBulk(ctx context.Context, j *bulkJob, req BulkRequest) {
+	defer j.cancel()
+
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for range req.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range work {
+				s.bulkApply(ctx, j, req, id)
+			}
+		}()
+	}
+	// Purging while paging can shift later pages; instances skipped that way are picked up
+	// by running the job again.
+	err := s.bulkList(ctx, j, req.Filter, work)
+	close(work)
+	wg.Wait()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	now := time.Now().UTC()
+	j.update(func(job *BulkJob) {
+		job.FinishedAt = &now
+		switch {
+		case errors.Is(err, context.Canceled):
+			job.State = BulkCanceled
+			job.Error = "canceled"
+		case err != nil:
+			job.State = BulkFailed
+			job.Error = err.Error()
+		default:
+			job.State = BulkCompleted
+		}
+	})
+	job := j.snapshot()
+	log.Infof("Bulk job %s %s: %d matched, %d succeeded, %d failed",
+		job.ID, job.State, job.Matched, job.Succeeded, job.Failed)
+}
+
+// bulkList pages through all instances of the tenant and sends those matching the ID prefix
+// of f to work, one page at a time.
+func (s *Service) bulkList(ctx context.Context, j *bulkJob, f BulkFilter, work chan<- string) error {
+	token := ""
+	for {
+		page, err := s.List(ctx, bulkPageSize, token)
+		if err != nil {
+			return err
+		}
+		var ids []string
+		for _, id := range page.InstanceIDs {
+			if strings.HasPrefix(id, f.IDPrefix) {
+				ids = append(ids, id)
+			}
+		}
+		j.update(func(job *BulkJob) {
+			job.Listed += len(page.InstanceIDs)
+			job.Candidates += len(ids)
+		})
+		for _, id := range ids {
+			select {
+			case work <- id:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if page.ContinuationToken == "" {
+			return nil
+		}
+		token = page.ContinuationToken
+	}
+}
+
+func (s *Service) bulkApply(ctx context.Context, j *bulkJob, req BulkRequest, id string) {
+	if req.Filter.needsMetadata() {
+		meta, err := s.runtime.Client().FetchWorkflowMetadata(ctx, instanceID(ctx, id))
+		if instances.IsNotFound(err) {
+			j.update(func(job *BulkJob) { job.Checked++ })
+			return
+		}
+		if err != nil {
+			j.update(func(job *BulkJob) { job.Checked++ })
+			j.fail(id, err)
+			return
+		}
+		if !req.Filter.matches(meta) {
+			j.update(func(job *BulkJob) { job.Checked++ })
+			return
+		}
+	}
+
+	j.update(func(job *BulkJob) {
+		job.Checked++
+		job.Matched++
+		if req.DryRun && len(job.Preview) < maxBulkPreview {
+			job.Preview = append(job.Preview, id)
+		}
+	})
+	if req.DryRun {
+		return
+	}
+
+	var err error
+	switch req.Operation {
+	case BulkTerminate:
+		err = s.Terminate(ctx, id, req.Reason)
+	case BulkPurge:
+		err = s.Purge(ctx, id)
+	case BulkSuspend:
+		err = s.Suspend(ctx, id, req.Reason)
+	case BulkResume:
+		err = s.Resume(ctx, id, req.Reason)
+	}
+	if err != nil {
+		j.fail(id, err)
+		return
+	}
+	j.update(func(job *BulkJob) { job.Succeeded++ })
+}
+
+func startBulk(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid bulk request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	job, err := svc.StartBulk(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/bulk/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
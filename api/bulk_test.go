@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+func TestBulkFilter(t *testing.T) {
+	cutoff := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := &workflow.WorkflowMetadata{
+		Name:          "SimpleWorkflow",
+		RuntimeStatus: protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING,
+		CreatedAt:     timestamppb.New(cutoff.Add(-time.Hour)),
+	}
+	for _, tc := range []struct {
+		name   string
+		filter BulkFilter
+		valid  bool
+		match  bool
+	}{
+		{name: "empty"},
+		{name: "unknown status", filter: BulkFilter{RuntimeStatus: []string{"DONE"}}},
+		{name: "workflow", filter: BulkFilter{Workflow: "SimpleWorkflow"}, valid: true, match: true},
+		{name: "other workflow", filter: BulkFilter{Workflow: "OrderSagaWorkflow"}, valid: true},
+		{name: "any status", filter: BulkFilter{RuntimeStatus: []string{"FAILED", "RUNNING"}}, valid: true, match: true},
+		{name: "other status", filter: BulkFilter{RuntimeStatus: []string{"FAILED"}}, valid: true},
+		{name: "created before", filter: BulkFilter{CreatedBefore: &cutoff}, valid: true, match: true},
+		{name: "created after", filter: BulkFilter{CreatedBefore: ptr(cutoff.Add(-2 * time.Hour))}, valid: true},
+		{name: "every field", filter: BulkFilter{Workflow: "SimpleWorkflow", RuntimeStatus: []string{"RUNNING"}, CreatedBefore: &cutoff, IDPrefix: "order-"},
+			valid: true, match: true},
+		{name: "one field differs", filter: BulkFilter{Workflow: "SimpleWorkflow", RuntimeStatus: []string{"COMPLETED"}, CreatedBefore: &cutoff},
+			valid: true},
+	} {
+		if err := tc.filter.validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: validate %v, want valid %v", tc.name, err, tc.valid)
+		}
+		if tc.valid && tc.filter.needsMetadata() && tc.filter.matches(meta) != tc.match {
+			t.Errorf("%s: matches %v, want %v", tc.name, !tc.match, tc.match)
+		}
+	}
+	if (BulkFilter{IDPrefix: "order-"}).needsMetadata() {
+		t.Error("a filter on the ID prefix alone fetches the instances")
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// waitBulk waits for the job id to finish and returns it.
+func waitBulk(t *testing.T, ctx context.Context, svc *Service, id string) BulkJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := svc.BulkJob(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != BulkRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("bulk job still running: %+v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBulkJob(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow", "OrderSagaWorkflow"))
+	acme := tenant.WithContext(context.Background(), "acme")
+	cutoff := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	running := protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING
+	for _, in := range []daprtest.Instance{
+		{ID: "acme~order-1", Name: "SimpleWorkflow", Status: running, CreatedAt: cutoff.Add(-time.Hour)},
+		{ID: "acme~order-2", Name: "SimpleWorkflow", Status: running, CreatedAt: cutoff.Add(time.Hour)},
+		{ID: "acme~order-3", Name: "OrderSagaWorkflow", Status: running, CreatedAt: cutoff.Add(-time.Hour)},
+		{ID: "acme~order-4", Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED, CreatedAt: cutoff.Add(-time.Hour)},
+		{ID: "acme~other-1", Name: "SimpleWorkflow", Status: running, CreatedAt: cutoff.Add(-time.Hour)},
+		{ID: "globex~order-1", Name: "SimpleWorkflow", Status: running, CreatedAt: cutoff.Add(-time.Hour)},
+	} {
+		sidecar.Put(in)
+	}
+	req := BulkRequest{
+		Operation: BulkTerminate,
+		Filter:    BulkFilter{Workflow: "SimpleWorkflow", RuntimeStatus: []string{"RUNNING"}, CreatedBefore: &cutoff, IDPrefix: "order-"},
+		DryRun:    true,
+	}
+
+	// A dry run previews the matches and changes nothing.
+	job, err := svc.StartBulk(acme, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitBulk(t, acme, svc, job.ID)
+	if job.State != BulkCompleted || job.Listed != 5 || job.Candidates != 4 || job.Checked != 4 || job.Matched != 1 ||
+		!reflect.DeepEqual(job.Preview, []string{"order-1"}) || job.Succeeded != 0 {
+		t.Errorf("dry run: %+v", job)
+	}
+
+	req.DryRun = false
+	job, err = svc.StartBulk(acme, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = waitBulk(t, acme, svc, job.ID); job.State != BulkCompleted || job.Matched != 1 || job.Succeeded != 1 || job.Preview != nil {
+		t.Errorf("terminate: %+v", job)
+	}
+	for _, id := range sidecar.IDs() {
+		in, _ := sidecar.Instance(id)
+		if want := id == "acme~order-1"; (in.Status == protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED) != want {
+			t.Errorf("%s is %s after the bulk terminate", id, in.Status)
+		}
+	}
+
+	// Jobs belong to the tenant that started them.
+	if _, err := svc.BulkJob(tenant.WithContext(context.Background(), "globex"), job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("globex got acme's job: %v", err)
+	}
+	if jobs := svc.BulkJobs(acme); len(jobs) != 2 {
+		t.Errorf("acme has %d jobs, want 2", len(jobs))
+	}
+
+	for _, bad := range []BulkRequest{
+		{Operation: "delete", Filter: BulkFilter{IDPrefix: "order-"}},
+		{Operation: BulkPurge},
+		{Operation: BulkPurge, Filter: BulkFilter{IDPrefix: "order-"}, Concurrency: maxBulkConcurrency + 1},
+	} {
+		if _, err := svc.StartBulk(acme, bad); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%+v: %v, want %v", bad, err, ErrInvalidArgument)
+		}
+	}
+}
+
+func TestBulkJobPages(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"))
+	ctx := context.Background()
+	n := 2*bulkPageSize + 100
+	for i := range n {
+		sidecar.Put(daprtest.Instance{ID: fmt.Sprintf("order-%d", i), Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING})
+	}
+
+	job, err := svc.StartBulk(ctx, BulkRequest{Operation: BulkTerminate, Filter: BulkFilter{IDPrefix: "order-"}, Concurrency: maxBulkConcurrency})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = waitBulk(t, ctx, svc, job.ID); job.State != BulkCompleted || job.Listed != n || job.Succeeded != n {
+		t.Fatalf("terminate: %s with %d listed and %d terminated, want all %d", job.State, job.Listed, job.Succeeded, n)
+	}
+
+	// Purging shifts the pages that follow, so a purge may take more than one run.
+	for run := 1; len(sidecar.IDs()) > 0; run++ {
+		if run > 3 {
+			t.Fatalf("%d instances left after %d purges", len(sidecar.IDs()), run-1)
+		}
+		job, err := svc.StartBulk(ctx, BulkRequest{Operation: BulkPurge, Filter: BulkFilter{RuntimeStatus: []string{"TERMINATED"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job = waitBulk(t, ctx, svc, job.ID); job.State != BulkCompleted || job.Failed != 0 || job.Succeeded == 0 {
+			t.Fatalf("purge %d: %s with %d purged and %d failed: %s", run, job.State, job.Succeeded, job.Failed, job.Error)
+		}
+	}
+
+	sidecar.Fail("ListInstanceIDs", errors.New("state store down"))
+	job, err = svc.StartBulk(ctx, BulkRequest{Operation: BulkPurge, Filter: BulkFilter{IDPrefix: "order-"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = waitBulk(t, ctx, svc, job.ID); job.State != BulkFailed || job.Error == "" {
+		t.Errorf("listing failed: %+v, want the job failed", job)
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	workflowv1 "github.com/javier-aliaga/dapr-go-samples/proto/workflow/v1"
+)
+
+// NewGRPCServer returns a gRPC server exposing svc as workflow.v1.WorkflowService, with
+// OpenTelemetry server instrumentation. Callers authenticate with the x-api-key or
+// authorization metadata when svc has an authenticator, and pick their tenant with
+// x-tenant-id metadata.
+func NewGRPCServer(svc *Service) *grpc.Server {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	if svc.auth != nil {
+		unary = append(unary, unaryAuthInterceptor(svc.auth))
+		stream = append(stream, streamAuthInterceptor(svc.auth))
+	}
+	unary = append(unary, unaryTenantInterceptor(svc.requireTenant))
+	stream = append(stream, streamTenantInterceptor(svc.requireTenant))
+
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+	workflowv1.RegisterWorkflowServiceServer(srv, &grpcServer{svc: svc})
+	return srv
+}
+
+type grpcServer struct {
+	workflowv1.UnimplementedWorkflowServiceServer
+	svc *Service
+}
+
+func (g *grpcServer) StartWorkflow(ctx context.Context, req *workflowv1.StartWorkflowRequest) (*workflowv1.StartWorkflowResponse, error) {
+	instanceID, err := g.svc.Start(ctx, StartOptions{
+		Workflow:    req.GetWorkflow(),
+		InstanceID:  req.GetInstanceId(),
+		Input:       json.RawMessage(req.GetInput()),
+		CallbackURL: req.GetCallbackUrl(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &workflowv1.StartWorkflowResponse{InstanceId: instanceID}, nil
+}
+
+func (g *grpcServer) GetWorkflow(ctx context.Context, req *workflowv1.GetWorkflowRequest) (*workflowv1.WorkflowStatus, error) {
+	st, err := g.svc.Get(ctx, req.GetInstanceId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return st.proto(), nil
+}
+
+func (g *grpcServer) RaiseEvent(ctx context.Context, req *workflowv1.RaiseEventRequest) (*workflowv1.RaiseEventResponse, error) {
+	if err := g.svc.RaiseEvent(ctx, req.GetInstanceId(), req.GetEventName(), []byte(req.GetPayload())); err != nil {
+		return nil, grpcError(err)
+	}
+	return &workflowv1.RaiseEventResponse{}, nil
+}
+
+func (g *grpcServer) Terminate(ctx context.Context, req *workflowv1.TerminateRequest) (*workflowv1.TerminateResponse, error) {
+	if err := g.svc.Terminate(ctx, req.GetInstanceId(), req.GetReason()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &workflowv1.TerminateResponse{}, nil
+}
+
+func (g *grpcServer) List(ctx context.Context, req *workflowv1.ListRequest) (*workflowv1.ListResponse, error) {
+	page, err := g.svc.List(ctx, req.GetPageSize(), req.GetContinuationToken())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &workflowv1.ListResponse{
+		InstanceIds:       page.InstanceIDs,
+		ContinuationToken: page.ContinuationToken,
+	}, nil
+}
+
+func (g *grpcServer) WatchWorkflow(req *workflowv1.WatchWorkflowRequest, stream grpc.ServerStreamingServer[workflowv1.WatchWorkflowResponse]) error {
+	err := g.svc.Watch(stream.Context(), req.GetInstanceId(), WatchHandler{
+		Send: func(event string, st WorkflowStatus) error {
+			return stream.Send(&workflowv1.WatchWorkflowResponse{Event: event, Status: st.proto()})
+		},
+	})
+	if err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+// proto converts the API view of an instance to its protobuf message.
+func (s WorkflowStatus) proto() *workflowv1.WorkflowStatus {
+	return &workflowv1.WorkflowStatus{
+		InstanceId:    s.InstanceID,
+		Name:          s.Name,
+		RuntimeStatus: s.RuntimeStatus,
+		CreatedAt:     timestampOrNil(s.CreatedAt),
+		LastUpdatedAt: timestampOrNil(s.LastUpdatedAt),
+		CustomStatus:  string(s.CustomStatus),
+		Input:         s.Input,
+		Output:        s.Output,
+		Failure:       s.Failure,
+	}
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// grpcError reports a Service error with the matching gRPC status code.
+func grpcError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, ErrInvalidArgument):
+		code = codes.InvalidArgument
+	case errors.Is(err, ErrUnavailable):
+		code = codes.Unavailable
+	case errors.Is(err, ErrUnauthenticated):
+		code = codes.Unauthenticated
+	case errors.Is(err, ErrPermissionDenied):
+		code = codes.PermissionDenied
+	case errors.Is(err, ErrRateLimited):
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, err.Error())
+}
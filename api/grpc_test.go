@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+	workflowv1 "github.com/javier-aliaga/dapr-go-samples/proto/workflow/v1"
+)
+
+// grpcClient serves svc over gRPC until the test ends and returns a client for it.
+func grpcClient(t *testing.T, svc *Service) workflowv1.WorkflowServiceClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewGRPCServer(svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///"+lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return workflowv1.NewWorkflowServiceClient(conn)
+}
+
+func apiKey(name, key string, role Role, tenant string) config.APIKey {
+	sum := sha256.Sum256([]byte(key))
+	return config.APIKey{Name: name, SHA256: hex.EncodeToString(sum[:]), Role: string(role), Tenant: tenant}
+}
+
+// as returns a context that calls with the API key and tenant metadata given, when set.
+func as(key, tenant string) context.Context {
+	var kv []string
+	if key != "" {
+		kv = append(kv, "x-api-key", key)
+	}
+	if tenant != "" {
+		kv = append(kv, "x-tenant-id", tenant)
+	}
+	return metadata.NewOutgoingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+func expectCode(t *testing.T, what string, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Errorf("%s: %v, want %s", what, err, want)
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	auth, err := NewAPIKeyAuthenticator([]config.APIKey{
+		apiKey("ops", "ops-key", RoleOperator, ""),
+		apiKey("acme-viewer", "acme-key", RoleViewer, "acme"),
+		apiKey("admin", "admin-key", RoleAdmin, ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := grpcClient(t, NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"), WithAuthenticator(auth)))
+
+	start := &workflowv1.StartWorkflowRequest{Workflow: "SimpleWorkflow", InstanceId: "order-1", Input: `{"n":1}`}
+	_, err = client.StartWorkflow(as("", "acme"), start)
+	expectCode(t, "start without a key", err, codes.Unauthenticated)
+	_, err = client.StartWorkflow(as("acme-key", "acme"), start)
+	expectCode(t, "start as viewer", err, codes.PermissionDenied)
+	_, err = client.StartWorkflow(as("ops-key", "acme"), &workflowv1.StartWorkflowRequest{Workflow: "Unknown"})
+	expectCode(t, "start an unknown workflow", err, codes.InvalidArgument)
+
+	resp, err := client.StartWorkflow(as("ops-key", "acme"), start)
+	if err != nil || resp.GetInstanceId() != "order-1" {
+		t.Fatalf("start: %v, %v", resp, err)
+	}
+	if in, ok := sidecar.Instance("acme~order-1"); !ok || in.Input != `{"n":1}` {
+		t.Fatalf("started %+v, want the instance scoped to acme", in)
+	}
+
+	// The viewer key is bound to acme, so it sees acme's instances without asking.
+	st, err := client.GetWorkflow(as("acme-key", ""), &workflowv1.GetWorkflowRequest{InstanceId: "order-1"})
+	if err != nil || st.GetInstanceId() != "order-1" || st.GetName() != "SimpleWorkflow" || st.GetRuntimeStatus() != "RUNNING" || st.GetCreatedAt() == nil {
+		t.Errorf("get: %v, %v", st, err)
+	}
+	_, err = client.GetWorkflow(as("acme-key", "globex"), &workflowv1.GetWorkflowRequest{InstanceId: "order-1"})
+	expectCode(t, "get as another tenant", err, codes.PermissionDenied)
+	_, err = client.GetWorkflow(as("ops-key", "globex"), &workflowv1.GetWorkflowRequest{InstanceId: "order-1"})
+	expectCode(t, "get another tenant's instance", err, codes.NotFound)
+
+	list, err := client.List(as("acme-key", ""), &workflowv1.ListRequest{})
+	if err != nil || !reflect.DeepEqual(list.GetInstanceIds(), []string{"order-1"}) {
+		t.Errorf("list: %v, %v", list, err)
+	}
+
+	_, err = client.RaiseEvent(as("ops-key", "acme"), &workflowv1.RaiseEventRequest{InstanceId: "order-1", EventName: "approval", Payload: "not json"})
+	expectCode(t, "raise an event with an invalid payload", err, codes.InvalidArgument)
+	_, err = client.RaiseEvent(as("ops-key", "acme"), &workflowv1.RaiseEventRequest{InstanceId: "order-1", EventName: "approval", Payload: `true`})
+	if err != nil {
+		t.Errorf("raise event: %v", err)
+	}
+
+	_, err = client.Terminate(as("ops-key", "acme"), &workflowv1.TerminateRequest{InstanceId: "order-1"})
+	expectCode(t, "terminate as operator", err, codes.PermissionDenied)
+	if _, err := client.Terminate(as("admin-key", "acme"), &workflowv1.TerminateRequest{InstanceId: "order-1", Reason: "cancelled"}); err != nil {
+		t.Fatalf("terminate: %v", err)
+	}
+	if in, _ := sidecar.Instance("acme~order-1"); in.Status != protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED {
+		t.Errorf("instance is %s after terminate", in.Status)
+	}
+}
+
+func TestGRPCWatchWorkflow(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	client := grpcClient(t, NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow")))
+	sidecar.Put(daprtest.Instance{ID: "acme~order-1", Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED, Output: `"done"`})
+
+	stream, err := client.WatchWorkflow(as("", "acme"), &workflowv1.WatchWorkflowRequest{InstanceId: "order-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := stream.Recv()
+	if err != nil || ev.GetEvent() != "completed" || ev.GetStatus().GetInstanceId() != "order-1" || ev.GetStatus().GetOutput() != `"done"` {
+		t.Fatalf("first event: %v, %v", ev, err)
+	}
+	if ev, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("stream went on with %v, %v", ev, err)
+	}
+
+	stream, err = client.WatchWorkflow(as("", "globex"), &workflowv1.WatchWorkflowRequest{InstanceId: "order-1"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectCode(t, "watch another tenant's instance", err, codes.NotFound)
+}
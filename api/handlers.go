@@ -1,35 +1,411 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 
-	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/lifecycle"
+	"github.com/javier-aliaga/dapr-go-samples/retention"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+	"github.com/javier-aliaga/dapr-go-samples/watchdog"
+	"github.com/javier-aliaga/dapr-go-samples/workflows"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/protobuf/encoding/protojson"
 
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
 	"github.com/dapr/kit/logger"
 )
 
 var log = logger.NewLogger("api.handlers")
-var lastInstanceID string
+
+// Mux registers routes, like an *http.ServeMux.
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+}
 
 // handle registers a route and ensures the server span name is the route name.
 // Use a stable, low-cardinality name like "GET /healthz".
-func handle(mux *http.ServeMux, pattern string, h http.Handler) {
+func handle(mux Mux, pattern string, h http.Handler) {
 	mux.Handle(pattern, otelhttp.NewHandler(h, pattern))
 }
 
-func RegisterRoutes(mux *http.ServeMux, runtime *dapr.WorkflowRuntime) {
+// Option configures NewService.
+type Option func(*options)
+
+type options struct {
+	maxWatchers   int
+	authenticator Authenticator
+	requireTenant bool
+	limits        config.Admission
+	retention     *retention.Manager
+	watchdog      *watchdog.Watchdog
+	subscriptions []config.Subscription
+	appToken      string
+}
+
+// WithMaxWatchers caps the number of concurrent watch streams across the HTTP and gRPC
+// APIs. Non-positive values keep the default.
+func WithMaxWatchers(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxWatchers = n
+		}
+	}
+}
+
+// WithRetention reports the runs of m through the admin API.
+func WithRetention(m *retention.Manager) Option {
+	return func(o *options) {
+		o.retention = m
+	}
+}
+
+// WithWatchdog reports the stuck instances found by w through the admin API.
+func WithWatchdog(w *watchdog.Watchdog) Option {
+	return func(o *options) {
+		o.watchdog = w
+	}
+}
+
+// RegisterHealth registers the liveness probe alone, for apps that do not serve the API.
+func RegisterHealth(mux Mux) {
 	handle(mux, "GET /healthz", http.HandlerFunc(healthHandler))
+}
+
+// RegisterRoutes registers the workflow API, the pub/sub subscriptions of svc and the
+// OpenAPI document, served at GET /openapi.json.
+func RegisterRoutes(mux Mux, svc *Service) {
+	rt := newRouter(mux, svc)
+
+	notFound := []int{http.StatusNotFound, http.StatusInternalServerError}
+	invalid := []int{http.StatusBadRequest, http.StatusInternalServerError}
+	start := []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError}
+
+	rt.handle("GET /healthz", operation{
+		ID:          "health",
+		Summary:     "Liveness probe",
+		Tags:        []string{"meta"},
+		Status:      http.StatusOK,
+		Response:    "",
+		ContentType: "text/plain",
+	}, http.HandlerFunc(healthHandler))
+
+	rt.handle("POST /workflow/event", operation{
+		ID:       "raiseLastEvent",
+		Role:     RoleOperator,
+		Summary:  `Raise "event" on the last SimpleWorkflow started by this app`,
+		Tags:     []string{"simple"},
+		Status:   http.StatusAccepted,
+		Response: "",
+		Errors:   []int{http.StatusInternalServerError},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raiseEvent(w, r, svc)
+	}))
+
+	rt.handle("POST /workflow", operation{
+		ID:              "startSimpleWorkflow",
+		Role:            RoleOperator,
+		Summary:         "Start a SimpleWorkflow",
+		Tags:            []string{"simple"},
+		Request:         StartRequest{},
+		RequestOptional: true,
+		Status:          http.StatusAccepted,
+		Response:        "",
+		Errors:          start,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startSimpleWorkflow(w, r, svc)
+	}))
+
+	rt.handle("POST /approvals", operation{
+		ID:       "startApproval",
+		Role:     RoleOperator,
+		Summary:  "Start an ApprovalWorkflow",
+		Tags:     []string{"approvals"},
+		Request:  workflows.ApprovalRequest{},
+		Status:   http.StatusAccepted,
+		Response: StartResponse{},
+		Errors:   start,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startApprovalWorkflow(w, r, svc)
+	}))
+
+	rt.handle("POST /monitors", operation{
+		ID:       "startMonitor",
+		Role:     RoleOperator,
+		Summary:  "Start a MonitorWorkflow for a URL",
+		Tags:     []string{"monitors"},
+		Request:  MonitorRequest{},
+		Status:   http.StatusAccepted,
+		Response: StartResponse{},
+		Errors:   start,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startMonitor(w, r, svc)
+	}))
+
+	rt.handle("DELETE /monitors/{id}", operation{
+		ID:       "stopMonitor",
+		Role:     RoleAdmin,
+		Summary:  "Stop a monitor",
+		Tags:     []string{"monitors"},
+		Status:   http.StatusAccepted,
+		Response: "",
+		Errors:   notFound,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stopMonitor(w, r, svc)
+	}))
+
+	rt.handle("GET /workflows", operation{
+		ID:      "listWorkflows",
+		Role:    RoleViewer,
+		Summary: "List workflow instance IDs",
+		Tags:    []string{"workflows"},
+		Query: []queryParam{
+			{Name: "pageSize", Description: "Maximum number of IDs to return", Type: "integer"},
+			{Name: "continuationToken", Description: "Token returned by the previous page", Type: "string"},
+		},
+		Status:   http.StatusOK,
+		Response: InstancePage{},
+		Errors:   invalid,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listWorkflows(w, r, svc)
+	}))
+
+	rt.handle("POST /workflows", operation{
+		ID:       "startWorkflow",
+		Role:     RoleOperator,
+		Summary:  "Start any workflow registered by this app",
+		Tags:     []string{"workflows"},
+		Request:  StartWorkflowRequest{},
+		Status:   http.StatusAccepted,
+		Response: StartResponse{},
+		Errors:   start,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startWorkflow(w, r, svc)
+	}))
+
+	rt.handle("GET /workflows/{id}", operation{
+		ID:       "getWorkflow",
+		Role:     RoleViewer,
+		Summary:  "Get the status of a workflow instance",
+		Tags:     []string{"workflows"},
+		Status:   http.StatusOK,
+		Response: WorkflowStatus{},
+		Errors:   notFound,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		getWorkflow(w, r, svc)
+	}))
+
+	rt.handle("GET /workflows/{id}/history", operation{
+		ID:       "getWorkflowHistory",
+		Role:     RoleViewer,
+		Summary:  "Get the history events of a workflow instance",
+		Tags:     []string{"workflows"},
+		Status:   http.StatusOK,
+		Response: []HistoryEvent{},
+		Errors:   notFound,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		getWorkflowHistory(w, r, svc)
+	}))
+
+	rt.handle("GET /workflows/{id}/watch", operation{
+		ID:          "watchWorkflow",
+		Role:        RoleViewer,
+		Summary:     `Stream status changes as Server-Sent Events; each "status" or "completed" event carries a WorkflowStatus`,
+		Tags:        []string{"workflows"},
+		Status:      http.StatusOK,
+		Response:    WorkflowStatus{},
+		ContentType: "text/event-stream",
+		Errors:      []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		watchWorkflow(w, r, svc)
+	}))
+
+	rt.handle("GET /workflows/{id}/callbacks", operation{
+		ID:       "listCallbackDeliveries",
+		Role:     RoleViewer,
+		Summary:  "List completion callback delivery attempts",
+		Tags:     []string{"workflows"},
+		Status:   http.StatusOK,
+		Response: []callbacks.Delivery{},
+		Errors:   []int{http.StatusInternalServerError},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listCallbackDeliveries(w, r, svc)
+	}))
+
+	rt.handle("POST /workflows/{id}/events/{event}", operation{
+		ID:              "raiseWorkflowEvent",
+		Role:            RoleOperator,
+		Summary:         "Raise an external event on a workflow instance",
+		Tags:            []string{"workflows"},
+		Request:         anyJSON{},
+		RequestOptional: true,
+		Status:          http.StatusAccepted,
+		Response:        "",
+		Errors:          []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raiseWorkflowEvent(w, r, svc)
+	}))
+
+	rt.handle("POST /workflows/{id}/terminate", operation{
+		ID:              "terminateWorkflow",
+		Role:            RoleAdmin,
+		Summary:         "Terminate a workflow instance and its children",
+		Tags:            []string{"workflows"},
+		Request:         TerminateRequest{},
+		RequestOptional: true,
+		Status:          http.StatusAccepted,
+		Response:        "",
+		Errors:          []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		terminateWorkflow(w, r, svc)
+	}))
+
+	rt.handle("POST /workflows/{id}/purge", operation{
+		ID:       "purgeWorkflow",
+		Role:     RoleAdmin,
+		Summary:  "Delete the state of a finished workflow instance and its children",
+		Tags:     []string{"workflows"},
+		Status:   http.StatusOK,
+		Response: "",
+		Errors:   []int{http.StatusNotFound, http.StatusInternalServerError},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		purgeWorkflow(w, r, svc)
+	}))
+
+	rt.handle("POST /bulk", operation{
+		ID:       "startBulk",
+		Role:     RoleAdmin,
+		Summary:  "Terminate, purge, suspend or resume the instances matching a filter in the background",
+		Tags:     []string{"bulk"},
+		Request:  BulkRequest{},
+		Status:   http.StatusAccepted,
+		Response: BulkJob{},
+		Errors:   []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startBulk(w, r, svc)
+	}))
+
+	rt.handle("GET /bulk", operation{
+		ID:       "listBulkJobs",
+		Role:     RoleViewer,
+		Summary:  "List recent bulk jobs",
+		Tags:     []string{"bulk"},
+		Status:   http.StatusOK,
+		Response: []BulkJob{},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, svc.BulkJobs(r.Context()))
+	}))
+
+	rt.handle("GET /bulk/{id}", operation{
+		ID:       "getBulkJob",
+		Role:     RoleViewer,
+		Summary:  "Get the progress of a bulk job",
+		Tags:     []string{"bulk"},
+		Status:   http.StatusOK,
+		Response: BulkJob{},
+		Errors:   []int{http.StatusNotFound},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.BulkJob(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}))
+
+	rt.handle("DELETE /bulk/{id}", operation{
+		ID:       "cancelBulkJob",
+		Role:     RoleAdmin,
+		Summary:  "Cancel a bulk job; instances already processed stay processed",
+		Tags:     []string{"bulk"},
+		Status:   http.StatusAccepted,
+		Response: BulkJob{},
+		Errors:   []int{http.StatusNotFound},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.CancelBulk(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	}))
 
-	handle(mux, "POST /workflow/event", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		raiseEvent(w, r, runtime)
+	rt.handle("GET /admin/retention", operation{
+		ID:       "getRetentionReport",
+		Role:     RoleAdmin,
+		Global:   true,
+		Summary:  "Get the report of the latest retention run",
+		Tags:     []string{"admin"},
+		Status:   http.StatusOK,
+		Response: retention.Report{},
+		Errors:   []int{http.StatusNotFound},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, err := svc.RetentionReport()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
 	}))
 
-	handle(mux, "POST /workflow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		startSimpleWorkflow(w, r, runtime)
+	rt.handle("GET /admin/stuck", operation{
+		ID:       "listStuckInstances",
+		Role:     RoleAdmin,
+		Global:   true,
+		Summary:  "List the running instances the latest watchdog scan found stuck",
+		Tags:     []string{"admin"},
+		Status:   http.StatusOK,
+		Response: watchdog.Scan{},
+		Errors:   []int{http.StatusNotFound},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scan, err := svc.StuckInstances()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, scan)
 	}))
+
+	rt.handle("GET /admin/limits", operation{
+		ID:       "getLimits",
+		Role:     RoleAdmin,
+		Global:   true,
+		Summary:  "Get the admission limits on workflow starts",
+		Tags:     []string{"admin"},
+		Status:   http.StatusOK,
+		Response: config.Admission{},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, svc.Limits())
+	}))
+
+	rt.handle("PUT /admin/limits", operation{
+		ID:       "setLimits",
+		Role:     RoleAdmin,
+		Global:   true,
+		Summary:  "Replace the admission limits on workflow starts",
+		Tags:     []string{"admin"},
+		Request:  config.Admission{},
+		Status:   http.StatusOK,
+		Response: config.Admission{},
+		Errors:   []int{http.StatusBadRequest},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setLimits(w, r, svc)
+	}))
+
+	registerSubscriptions(rt, svc)
+	rt.serveDocument()
 }
 
 //
@@ -61,36 +437,370 @@ func healthHandler(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write([]byte("ok"))
 }
 
-func startSimpleWorkflow(w http.ResponseWriter, r *http.Request, runtime *dapr.WorkflowRuntime) {
-	client := runtime.Client()
-	ctx := r.Context()
+// StartRequest is the optional body of POST /workflow.
+type StartRequest struct {
+	// CallbackURL receives the final outcome of the instance, signed with the HMAC secret.
+	CallbackURL string `json:"callbackUrl,omitempty"`
+}
+
+// lastInstances remembers the last SimpleWorkflow each tenant started, so POST
+// /workflow/event raises its event on an instance of the caller's own tenant.
+type lastInstances struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+func newLastInstances() *lastInstances {
+	return &lastInstances{ids: make(map[string]string)}
+}
+
+func (l *lastInstances) set(tenantID, instanceID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ids[tenantID] = instanceID
+}
+
+func (l *lastInstances) get(tenantID string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id, ok := l.ids[tenantID]
+	return id, ok
+}
+
+func startSimpleWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var req StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("invalid start request: %v", err), http.StatusBadRequest)
+		return
+	}
 
 	log.Infof("Starting workflow")
 
-	instanceID, err := client.ScheduleWorkflow(ctx, "SimpleWorkflow")
+	instanceID, err := svc.Start(r.Context(), StartOptions{Workflow: "SimpleWorkflow", CallbackURL: req.CallbackURL})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to start workflow: %v", err), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	lastInstanceID = instanceID
+	svc.lastInstances.set(tenant.FromContext(r.Context()), instanceID)
 	resp := "New Workflow Instance created " + instanceID
 	writeJSON(w, http.StatusAccepted, resp)
 }
 
-func raiseEvent(w http.ResponseWriter, r *http.Request, runtime *dapr.WorkflowRuntime) {
-	client := runtime.Client()
-	ctx := r.Context()
+// StartResponse is returned by the routes that start a workflow.
+type StartResponse struct {
+	InstanceID string `json:"instanceId"`
+}
+
+// StartWorkflowRequest is the body of POST /workflows.
+type StartWorkflowRequest struct {
+	Workflow    string          `json:"workflow"`
+	InstanceID  string          `json:"instanceId,omitempty"`
+	Input       json.RawMessage `json:"input,omitempty"`
+	CallbackURL string          `json:"callbackUrl,omitempty"`
+}
+
+// startWorkflow starts any workflow registered by this app.
+func startWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var req StartWorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid start request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	instanceID, err := svc.Start(r.Context(), StartOptions{
+		Workflow:    req.Workflow,
+		InstanceID:  req.InstanceID,
+		Input:       req.Input,
+		CallbackURL: req.CallbackURL,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, StartResponse{InstanceID: instanceID})
+}
+
+// listWorkflows returns a page of instance IDs. The pageSize and continuationToken query
+// parameters select the page.
+func listWorkflows(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var pageSize uint64
+	if v := r.URL.Query().Get("pageSize"); v != "" {
+		var err error
+		if pageSize, err = strconv.ParseUint(v, 10, 32); err != nil {
+			http.Error(w, fmt.Sprintf("invalid pageSize: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := svc.List(r.Context(), uint32(pageSize), r.URL.Query().Get("continuationToken"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// TerminateRequest is the optional body of POST /workflows/{id}/terminate.
+type TerminateRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func terminateWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	id := r.PathValue("id")
+
+	var req TerminateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("invalid terminate request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := svc.Terminate(r.Context(), id, req.Reason); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, "Workflow terminated "+id)
+}
+
+func purgeWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	id := r.PathValue("id")
+	if err := svc.Purge(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, "Workflow purged "+id)
+}
+
+func getWorkflowHistory(w http.ResponseWriter, r *http.Request, svc *Service) {
+	events, err := svc.History(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
 
-	err := client.RaiseEvent(ctx, lastInstanceID, "event")
+func listCallbackDeliveries(w http.ResponseWriter, r *http.Request, svc *Service) {
+	deliveries, err := svc.CallbackDeliveries(r.Context(), r.PathValue("id"))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to raise event for workflow %s: %v", lastInstanceID, err), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	resp := "Event raised for " + lastInstanceID
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func raiseEvent(w http.ResponseWriter, r *http.Request, svc *Service) {
+	id, ok := svc.lastInstances.get(tenant.FromContext(r.Context()))
+	if !ok {
+		http.Error(w, "no workflow started", http.StatusNotFound)
+		return
+	}
+
+	if err := svc.RaiseEvent(r.Context(), id, "event", nil); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := "Event raised for " + id
 	writeJSON(w, http.StatusAccepted, resp)
 }
 
+func startApprovalWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var req workflows.ApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid approval request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Approver == "" {
+		http.Error(w, "approver is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := req.Interval(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode approval request: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	instanceID, err := svc.Start(r.Context(), StartOptions{Workflow: "ApprovalWorkflow", Input: input})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, StartResponse{InstanceID: instanceID})
+}
+
+// WorkflowStatus is the API view of a workflow instance.
+type WorkflowStatus struct {
+	InstanceID    string     `json:"instanceId"`
+	Name          string     `json:"name"`
+	RuntimeStatus string     `json:"runtimeStatus"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	LastUpdatedAt *time.Time `json:"lastUpdatedAt,omitempty"`
+	// CustomStatus is the decoded custom status when it is JSON, otherwise a JSON string.
+	CustomStatus json.RawMessage `json:"customStatus,omitempty"`
+	Input        string          `json:"input,omitempty"`
+	Output       string          `json:"output,omitempty"`
+	Failure      string          `json:"failure,omitempty"`
+}
+
+// NewWorkflowStatus returns the status reported for the instance described by meta.
+func NewWorkflowStatus(meta *workflow.WorkflowMetadata) WorkflowStatus {
+	status := WorkflowStatus{
+		InstanceID:    meta.InstanceId,
+		Name:          meta.Name,
+		RuntimeStatus: meta.String(),
+		CustomStatus:  customStatusJSON(meta.CustomStatus.GetValue()),
+		Input:         meta.Input.GetValue(),
+		Output:        meta.Output.GetValue(),
+		Failure:       meta.FailureDetails.GetErrorMessage(),
+	}
+	if meta.CreatedAt != nil {
+		t := meta.CreatedAt.AsTime()
+		status.CreatedAt = &t
+	}
+	if meta.LastUpdatedAt != nil {
+		t := meta.LastUpdatedAt.AsTime()
+		status.LastUpdatedAt = &t
+	}
+	return status
+}
+
+// HistoryEvent is an entry of the history of an instance.
+type HistoryEvent struct {
+	EventID   int32     `json:"eventId"`
+	Timestamp time.Time `json:"timestamp"`
+	// Type is the kind of event, such as taskScheduled or eventRaised.
+	Type string `json:"type"`
+	// Name is the activity, workflow or event name, for events that have one.
+	Name string `json:"name,omitempty"`
+	// Event is the whole event in protobuf JSON form.
+	Event json.RawMessage `json:"event"`
+}
+
+// NewHistoryEvents returns the history reported for the given runtime events.
+func NewHistoryEvents(events []*protos.HistoryEvent) []HistoryEvent {
+	out := make([]HistoryEvent, 0, len(events))
+	for _, e := range events {
+		he := HistoryEvent{
+			EventID:   e.GetEventId(),
+			Timestamp: e.GetTimestamp().AsTime(),
+		}
+		m := e.ProtoReflect()
+		if fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("eventType")); fd != nil {
+			he.Type = fd.JSONName()
+		}
+		switch {
+		case e.GetExecutionStarted() != nil:
+			he.Name = e.GetExecutionStarted().GetName()
+		case e.GetTaskScheduled() != nil:
+			he.Name = e.GetTaskScheduled().GetName()
+		case e.GetSubOrchestrationInstanceCreated() != nil:
+			he.Name = e.GetSubOrchestrationInstanceCreated().GetName()
+		case e.GetEventRaised() != nil:
+			he.Name = e.GetEventRaised().GetName()
+		case e.GetEventSent() != nil:
+			he.Name = e.GetEventSent().GetName()
+		}
+		he.Event, _ = protojson.Marshal(e)
+		out = append(out, he)
+	}
+	return out
+}
+
+// customStatusJSON returns the custom status as embeddable JSON so clients get structured
+// progress objects rather than an escaped string.
+func customStatusJSON(cs string) json.RawMessage {
+	if cs == "" {
+		return nil
+	}
+	if json.Valid([]byte(cs)) {
+		return json.RawMessage(cs)
+	}
+	b, _ := json.Marshal(cs)
+	return b
+}
+
+func getWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	status, err := svc.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// raiseWorkflowEvent raises the named event on an instance. A non-empty request body is
+// forwarded as the event payload and must be valid JSON.
+func raiseWorkflowEvent(w http.ResponseWriter, r *http.Request, svc *Service) {
+	id := r.PathValue("id")
+	event := r.PathValue("event")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := svc.RaiseEvent(r.Context(), id, event, body); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, "Event "+event+" raised for "+id)
+}
+
+// publishEventRaised emits a workflow.event_raised lifecycle event. Failures are logged
+// since the event itself was already delivered to the workflow.
+func publishEventRaised(ctx context.Context, instanceID, event string) {
+	ev := lifecycle.New(lifecycle.TypeEventRaised, lifecycle.Data{InstanceID: instanceID, Event: event})
+	if err := lifecycle.Publish(ctx, ev.WithTraceContext(ctx)); err != nil {
+		log.Errorf("Failed to publish %s for workflow %s: %v", lifecycle.TypeEventRaised, instanceID, err)
+	}
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL.
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// writeError reports a Service error with the matching HTTP status, and when to retry if
+// the error says so.
+func writeError(w http.ResponseWriter, err error) {
+	if d, ok := retryAfter(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+	}
+
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrInvalidArgument):
+		code = http.StatusBadRequest
+	case errors.Is(err, ErrUnavailable):
+		code = http.StatusServiceUnavailable
+	case errors.Is(err, ErrUnauthenticated):
+		code = http.StatusUnauthorized
+	case errors.Is(err, ErrPermissionDenied):
+		code = http.StatusForbidden
+	case errors.Is(err, ErrRateLimited):
+		code = http.StatusTooManyRequests
+	}
+	http.Error(w, err.Error(), code)
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// jwtLeeway tolerates clock skew when checking exp and nbf.
+const jwtLeeway = time.Minute
+
+// JWTAuthenticator validates RS256/384/512 and ES256/384/512 bearer tokens against the keys
+// of a local JWKS file. The caller's role is the highest one listed in the roles claim.
+type JWTAuthenticator struct {
+	keys        map[string]jwk
+	issuer      string
+	audience    string
+	rolesClaim  string
+	tenantClaim string
+	now         func() time.Time
+}
+
+type jwk struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// NewJWTAuthenticator loads the JWKS file named in cfg.
+func NewJWTAuthenticator(cfg config.JWT) (*JWTAuthenticator, error) {
+	b, err := os.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks: %w", err)
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse jwks %s: %w", cfg.JWKSFile, err)
+	}
+
+	a := &JWTAuthenticator{
+		keys:        keys,
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		rolesClaim:  cfg.RolesClaim,
+		tenantClaim: cfg.TenantClaim,
+		now:         time.Now,
+	}
+	if a.rolesClaim == "" {
+		a.rolesClaim = "roles"
+	}
+	if a.tenantClaim == "" {
+		a.tenantClaim = "tenant"
+	}
+	return a, nil
+}
+
+func parseJWKS(b []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: invalid n: %w", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: invalid e: %w", k.Kid, err)
+			}
+			// The exponent must fit the int of rsa.PublicKey; real keys use 65537.
+			exp := new(big.Int).SetBytes(e)
+			if exp.BitLen() > 31 || exp.Int64() < 3 || exp.Bit(0) == 0 {
+				return nil, fmt.Errorf("key %s: invalid exponent", k.Kid)
+			}
+			if k.Alg != "" && !strings.HasPrefix(k.Alg, "RS") {
+				return nil, fmt.Errorf("key %s: algorithm %q does not fit an RSA key", k.Kid, k.Alg)
+			}
+			keys[k.Kid] = jwk{alg: k.Alg, key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(exp.Int64()),
+			}}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, fmt.Errorf("key %s: unsupported curve %q", k.Kid, k.Crv)
+			}
+			// Each curve signs with exactly one algorithm, whether or not the key names it.
+			alg := ecdsaAlgs[k.Crv]
+			if k.Alg != "" && k.Alg != alg {
+				return nil, fmt.Errorf("key %s: algorithm %q does not fit curve %s", k.Kid, k.Alg, k.Crv)
+			}
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: invalid x: %w", k.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(k.Y)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: invalid y: %w", k.Kid, err)
+			}
+			keys[k.Kid] = jwk{alg: alg, key: &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}}
+		default:
+			return nil, fmt.Errorf("key %s: unsupported key type %q", k.Kid, k.Kty)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// ecdsaAlgs maps each supported curve to the one algorithm that signs with it.
+var ecdsaAlgs = map[string]string{"P-256": "ES256", "P-384": "ES384", "P-521": "ES512"}
+
+func (a *JWTAuthenticator) Authenticate(_ context.Context, creds Credentials) (Identity, error) {
+	claims, err := a.verify(creds.BearerToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Identity{}, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+
+	// Unknown role names are ignored so tokens can carry roles meant for other services.
+	var role Role
+	for _, name := range claimStrings(claims[a.rolesClaim]) {
+		if r, err := ParseRole(name); err == nil && roleRank[r] > roleRank[role] {
+			role = r
+		}
+	}
+	if role == "" {
+		return Identity{}, fmt.Errorf("%w: token for %s carries no known role", ErrPermissionDenied, sub)
+	}
+
+	t, _ := claims[a.tenantClaim].(string)
+	if t != "" {
+		if err := tenant.Validate(t); err != nil {
+			return Identity{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+	}
+
+	return Identity{Subject: sub, Role: role, Tenant: t, Method: "jwt"}, nil
+}
+
+// verify checks the signature and registered claims of token and returns its claims.
+func (a *JWTAuthenticator) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	key, ok := a.keys[header.Kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", header.Kid)
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("key %q is not for %s", header.Kid, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	switch pub := key.key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg[:2] != "RS" || rsa.VerifyPKCS1v15(pub, hash, digest, sig) != nil {
+			return nil, errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if header.Alg[:2] != "ES" || len(sig) != 2*size {
+			return nil, errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return nil, errors.New("invalid signature")
+		}
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+
+	now := a.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return nil, errors.New("unexpected issuer")
+	}
+	if a.audience != "" && !slices.Contains(claimStrings(claims["aud"]), a.audience) {
+		return nil, errors.New("unexpected audience")
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// claimStrings reads a claim that is either a list of strings or a space-separated string.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+)
+
+var b64 = base64.RawURLEncoding.EncodeToString
+
+// signJWT signs claims with key the way an issuer using alg and kid does.
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(header) + "." + b64(payload)
+	if alg == "none" {
+		return signed + "."
+	}
+
+	hash := jwtHashes[alg]
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func rsaJWK(kid, alg string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kid": kid, "kty": "RSA", "alg": alg, "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid, alg, crv string, key *ecdsa.PublicKey) map[string]string {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return map[string]string{"kid": kid, "kty": "EC", "alg": alg, "crv": crv,
+		"x": b64(key.X.FillBytes(make([]byte, size))), "y": b64(key.Y.FillBytes(make([]byte, size)))}
+}
+
+// writeJWKS writes keys as a JWKS file and returns its path.
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewJWTAuthenticator(config.JWT{
+		JWKSFile: writeJWKS(t,
+			rsaJWK("rsa", "RS256", &rsaKey.PublicKey),
+			// The EC key names no algorithm: its curve alone allows only ES256.
+			ecJWK("ec", "", "P-256", &ecKey.PublicKey),
+		),
+		Issuer:   "https://issuer.example",
+		Audience: "workflows",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	claims := func(edit func(map[string]any)) map[string]any {
+		c := map[string]any{
+			"sub":   "alice",
+			"iss":   "https://issuer.example",
+			"aud":   []string{"other", "workflows"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"roles": []string{"viewer", "operator", "unknown"},
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+
+	for _, tc := range []struct {
+		name     string
+		token    string
+		wantRole Role
+		// wantErr is the error the token is rejected with, if any.
+		wantErr error
+	}{
+		{name: "RS256", token: signJWT(t, "RS256", "rsa", rsaKey, claims(nil)), wantRole: RoleOperator},
+		{name: "ES256", token: signJWT(t, "ES256", "ec", ecKey, claims(nil)), wantRole: RoleOperator},
+		{name: "roles as a string", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["roles"] = "viewer admin" })),
+			wantRole: RoleAdmin},
+		{name: "no known role", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["roles"] = []string{"owner"} })),
+			wantErr: ErrPermissionDenied},
+		{name: "no roles", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { delete(c, "roles") })),
+			wantErr: ErrPermissionDenied},
+		{name: "no subject", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { delete(c, "sub") })),
+			wantErr: ErrUnauthenticated},
+
+		{name: "malformed", token: "not-a-token", wantErr: ErrUnauthenticated},
+		{name: "signed by another key", token: signJWT(t, "ES256", "ec", otherKey, claims(nil)), wantErr: ErrUnauthenticated},
+		{name: "claims changed after signing", token: func() string {
+			parts := strings.Split(signJWT(t, "ES256", "ec", ecKey, claims(nil)), ".")
+			forged, _ := json.Marshal(claims(func(c map[string]any) { c["roles"] = []string{"admin"} }))
+			return parts[0] + "." + b64(forged) + "." + parts[2]
+		}(), wantErr: ErrUnauthenticated},
+		{name: "alg none", token: signJWT(t, "none", "rsa", nil, claims(nil)), wantErr: ErrUnauthenticated},
+		{name: "alg other than the key's", token: signJWT(t, "RS512", "rsa", rsaKey, claims(nil)), wantErr: ErrUnauthenticated},
+		{name: "alg other than the curve's", token: signJWT(t, "ES384", "ec", ecKey, claims(nil)), wantErr: ErrUnauthenticated},
+		{name: "RSA alg on an EC key", token: signJWT(t, "RS256", "ec", rsaKey, claims(nil)), wantErr: ErrUnauthenticated},
+		{name: "unknown kid", token: signJWT(t, "ES256", "gone", ecKey, claims(nil)), wantErr: ErrUnauthenticated},
+
+		{name: "no expiry", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { delete(c, "exp") })),
+			wantErr: ErrUnauthenticated},
+		{name: "expired", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() })),
+			wantErr: ErrUnauthenticated},
+		{name: "expired within leeway", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["exp"] = now.Add(-30 * time.Second).Unix() })),
+			wantRole: RoleOperator},
+		{name: "not valid yet", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["nbf"] = now.Add(2 * time.Minute).Unix() })),
+			wantErr: ErrUnauthenticated},
+		{name: "not valid yet within leeway", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["nbf"] = now.Add(30 * time.Second).Unix() })),
+			wantRole: RoleOperator},
+		{name: "wrong issuer", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["iss"] = "https://evil.example" })),
+			wantErr: ErrUnauthenticated},
+		{name: "wrong audience", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["aud"] = "other" })),
+			wantErr: ErrUnauthenticated},
+		{name: "audience as a string", token: signJWT(t, "ES256", "ec", ecKey, claims(func(c map[string]any) { c["aud"] = "workflows" })),
+			wantRole: RoleOperator},
+	} {
+		id, err := a.Authenticate(context.Background(), Credentials{BearerToken: tc.token})
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("%s: error %v, want %v", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if id.Subject != "alice" || id.Role != tc.wantRole || id.Method != "jwt" {
+			t.Errorf("%s: identity %+v, want alice as %s", tc.name, id, tc.wantRole)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withE := func(e []byte) map[string]string {
+		k := rsaJWK("rsa", "", &rsaKey.PublicKey)
+		k["e"] = b64(e)
+		return k
+	}
+
+	for _, tc := range []struct {
+		name    string
+		key     map[string]string
+		wantAlg string
+		wantErr string
+	}{
+		{name: "RSA", key: rsaJWK("rsa", "RS384", &rsaKey.PublicKey), wantAlg: "RS384"},
+		{name: "EC without alg", key: ecJWK("ec", "", "P-384", &ecKey.PublicKey), wantAlg: "ES384"},
+		{name: "EC with its alg", key: ecJWK("ec", "ES384", "P-384", &ecKey.PublicKey), wantAlg: "ES384"},
+		{name: "EC with another curve's alg", key: ecJWK("ec", "ES256", "P-384", &ecKey.PublicKey), wantErr: "does not fit curve"},
+		{name: "RSA with an EC alg", key: rsaJWK("rsa", "ES256", &rsaKey.PublicKey), wantErr: "does not fit an RSA key"},
+		{name: "exponent over 32 bits", key: withE([]byte{1, 0, 0, 0, 0, 1}), wantErr: "invalid exponent"},
+		{name: "huge exponent", key: withE(append([]byte{1}, make([]byte, 64)...)), wantErr: "invalid exponent"},
+		{name: "exponent 1", key: withE([]byte{1}), wantErr: "invalid exponent"},
+		{name: "even exponent", key: withE([]byte{1, 0, 0}), wantErr: "invalid exponent"},
+		{name: "unsupported curve", key: map[string]string{"kid": "ec", "kty": "EC", "crv": "P-224"}, wantErr: "unsupported curve"},
+	} {
+		b, _ := json.Marshal(map[string]any{"keys": []map[string]string{tc.key}})
+		keys, err := parseJWKS(b)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: error %v, want %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := keys[tc.key["kid"]].alg; got != tc.wantAlg {
+			t.Errorf("%s: alg %q, want %q", tc.name, got, tc.wantAlg)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/workflows"
+)
+
+// MonitorRequest is the body of POST /monitors. Intervals are Go duration strings.
+type MonitorRequest struct {
+	Target      string `json:"target"`
+	MinInterval string `json:"minInterval,omitempty"`
+	MaxInterval string `json:"maxInterval,omitempty"`
+}
+
+const (
+	defaultMonitorMinInterval = 10 * time.Second
+	defaultMonitorMaxInterval = 5 * time.Minute
+)
+
+func (m MonitorRequest) state() (workflows.MonitorState, error) {
+	state := workflows.MonitorState{
+		Target:      m.Target,
+		MinInterval: defaultMonitorMinInterval,
+		MaxInterval: defaultMonitorMaxInterval,
+	}
+
+	if !isHTTPURL(m.Target) {
+		return state, fmt.Errorf("target must be an http(s) URL")
+	}
+
+	var err error
+	if m.MinInterval != "" {
+		if state.MinInterval, err = time.ParseDuration(m.MinInterval); err != nil {
+			return state, fmt.Errorf("invalid minInterval: %w", err)
+		}
+	}
+	if m.MaxInterval != "" {
+		if state.MaxInterval, err = time.ParseDuration(m.MaxInterval); err != nil {
+			return state, fmt.Errorf("invalid maxInterval: %w", err)
+		}
+	}
+	if state.MinInterval <= 0 || state.MaxInterval < state.MinInterval {
+		return state, fmt.Errorf("intervals must satisfy 0 < minInterval <= maxInterval")
+	}
+
+	return state, nil
+}
+
+func startMonitor(w http.ResponseWriter, r *http.Request, svc *Service) {
+	var req MonitorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid monitor request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	state, err := req.state()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	input, err := json.Marshal(state)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode monitor state: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	instanceID, err := svc.Start(r.Context(), StartOptions{Workflow: "MonitorWorkflow", Input: input})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	log.Infof("Started monitor %s for %s", instanceID, state.Target)
+	writeJSON(w, http.StatusAccepted, StartResponse{InstanceID: instanceID})
+}
+
+// stopMonitor terminates the monitor. Monitors never complete on their own, so termination
+// is the normal way to end them.
+func stopMonitor(w http.ResponseWriter, r *http.Request, svc *Service) {
+	id := r.PathValue("id")
+
+	status, err := svc.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, fmt.Sprintf("monitor %s not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status.Name != "MonitorWorkflow" {
+		http.Error(w, fmt.Sprintf("workflow %s is not a monitor", id), http.StatusNotFound)
+		return
+	}
+
+	if err := svc.Terminate(r.Context(), id, "stopped via API"); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	log.Infof("Stopped monitor %s", id)
+	writeJSON(w, http.StatusAccepted, "Monitor stopped "+id)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+	"github.com/javier-aliaga/dapr-go-samples/workflows"
+)
+
+func TestMonitorsAPI(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "MonitorWorkflow", "SimpleWorkflow"))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{body: `{"target":"http://example.com/health"}`, want: http.StatusAccepted},
+		{body: `{"target":"example.com"}`, want: http.StatusBadRequest},
+		{body: `{"target":"http://example.com","minInterval":"soon"}`, want: http.StatusBadRequest},
+		{body: `{"target":"http://example.com","minInterval":"0s"}`, want: http.StatusBadRequest},
+		{body: `{"target":"http://example.com","minInterval":"1m","maxInterval":"30s"}`, want: http.StatusBadRequest},
+		{body: `not json`, want: http.StatusBadRequest},
+	} {
+		if rec := call(mux, http.MethodPost, "/monitors", "", tc.body); rec.Code != tc.want {
+			t.Errorf("POST /monitors %s: %d %s, want %d", tc.body, rec.Code, rec.Body, tc.want)
+		}
+	}
+
+	ids := sidecar.IDs()
+	if len(ids) != 1 {
+		t.Fatalf("started %v, want one monitor", ids)
+	}
+	in, _ := sidecar.Instance(ids[0])
+	var state workflows.MonitorState
+	if err := json.Unmarshal([]byte(in.Input), &state); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != "MonitorWorkflow" || state.MinInterval != 10*time.Second || state.MaxInterval != 5*time.Minute {
+		t.Errorf("started %s with %+v, want the default intervals", in.Name, state)
+	}
+
+	sidecar.Put(daprtest.Instance{ID: "simple", Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING})
+	for _, tc := range []struct {
+		id   string
+		want int
+	}{
+		{id: "simple", want: http.StatusNotFound},
+		{id: "missing", want: http.StatusNotFound},
+		{id: ids[0], want: http.StatusAccepted},
+	} {
+		if rec := call(mux, http.MethodDelete, "/monitors/"+tc.id, "", ""); rec.Code != tc.want {
+			t.Errorf("DELETE /monitors/%s: %d %s, want %d", tc.id, rec.Code, rec.Body, tc.want)
+		}
+	}
+	if in, _ := sidecar.Instance(ids[0]); in.Status != protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED {
+		t.Errorf("monitor is %s, want terminated", in.Status)
+	}
+	if in, _ := sidecar.Instance("simple"); in.Status != protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING {
+		t.Errorf("DELETE /monitors/simple left SimpleWorkflow %s", in.Status)
+	}
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// operation documents a route in the OpenAPI document served at /openapi.json. Every route
+// of RegisterRoutes is registered through router.handle, which requires one, so the
+// document cannot fall behind the routes.
+type operation struct {
+	// ID is the unique operationId.
+	ID string
+	// Role is required to call the route when authentication is enabled. Routes without
+	// one are public.
+	Role Role
+	// Global routes act across tenants. They are not scoped to a tenant and reject callers
+	// bound to one.
+	Global bool
+	// Sidecar routes are called by the Dapr sidecar rather than by API callers. They require
+	// Dapr's app API token when the app has one.
+	Sidecar bool
+	Summary string
+	Tags    []string
+	Query   []queryParam
+
+	// Request is a value of the JSON request body type, or nil for no body.
+	Request any
+	// RequestOptional marks the request body as optional.
+	RequestOptional bool
+
+	// Status is the success status code.
+	Status int
+	// Response is a value of the success response type, or nil for no body.
+	Response any
+	// ContentType of the success response. Defaults to application/json.
+	ContentType string
+	// Errors lists the error status codes the route can return. Errors are plain text.
+	Errors []int
+}
+
+type queryParam struct {
+	Name        string
+	Description string
+	// Type is the JSON schema type of the parameter.
+	Type string
+}
+
+// anyJSON documents a body that can be any JSON value.
+type anyJSON = json.RawMessage
+
+// router registers documented routes on a mux and collects their OpenAPI description.
+type router struct {
+	mux      Mux
+	auth     Authenticator
+	appToken string
+	tenancy  bool
+	paths    map[string]map[string]any
+	schemas  *schemaRegistry
+	ids      map[string]bool
+}
+
+// newRouter returns a router for mux. Routes with a role are scoped to the caller's tenant
+// and, when svc has an authenticator, require callers to authenticate.
+func newRouter(mux Mux, svc *Service) *router {
+	return &router{
+		mux:      mux,
+		auth:     svc.auth,
+		appToken: svc.appToken,
+		tenancy:  svc.requireTenant,
+		paths:    make(map[string]map[string]any),
+		schemas:  &schemaRegistry{defs: make(map[string]any)},
+		ids:      make(map[string]bool),
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)\}`)
+
+// handle registers h like the package-level handle and adds op to the document. It panics
+// when op is incomplete, so an undocumented route fails at startup.
+func (rt *router) handle(pattern string, op operation, h http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		panic(fmt.Sprintf("route %q has no method", pattern))
+	}
+	if op.ID == "" || op.Summary == "" || op.Status == 0 {
+		panic(fmt.Sprintf("route %q needs an operation ID, summary and status", pattern))
+	}
+	if rt.ids[op.ID] {
+		panic(fmt.Sprintf("duplicate operation ID %q", op.ID))
+	}
+	rt.ids[op.ID] = true
+
+	if op.Role != "" {
+		if op.Global {
+			h = requireUnbound(h)
+		} else {
+			h = scopeTenant(rt.tenancy, h)
+		}
+		if rt.auth != nil {
+			h = requireRole(rt.auth, op.Role, h)
+		}
+	}
+	if op.Sidecar && rt.appToken != "" {
+		h = requireAppToken(rt.appToken, h)
+	}
+	handle(rt.mux, pattern, h)
+
+	if rt.paths[path] == nil {
+		rt.paths[path] = make(map[string]any)
+	}
+	rt.paths[path][strings.ToLower(method)] = rt.describe(path, op)
+}
+
+func (rt *router) describe(path string, op operation) map[string]any {
+	doc := map[string]any{
+		"operationId": op.ID,
+		"summary":     op.Summary,
+	}
+	if len(op.Tags) > 0 {
+		doc["tags"] = op.Tags
+	}
+
+	var params []map[string]any
+	if op.Role != "" && !op.Global {
+		params = append(params, map[string]any{
+			"name":        TenantHeader,
+			"in":          "header",
+			"required":    rt.tenancy,
+			"description": "Tenant to act for, for callers that are not bound to one",
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, q := range op.Query {
+		params = append(params, map[string]any{
+			"name":        q.Name,
+			"in":          "query",
+			"description": q.Description,
+			"schema":      map[string]any{"type": q.Type},
+		})
+	}
+	if len(params) > 0 {
+		doc["parameters"] = params
+	}
+
+	if op.Request != nil {
+		doc["requestBody"] = map[string]any{
+			"required": !op.RequestOptional,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": rt.schemas.schemaOf(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	success := map[string]any{"description": http.StatusText(op.Status)}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success["content"] = map[string]any{
+			contentType: map[string]any{"schema": rt.schemas.schemaOf(reflect.TypeOf(op.Response))},
+		}
+	}
+	responses := map[string]any{fmt.Sprint(op.Status): success}
+	errs := op.Errors
+	if rt.auth != nil && op.Role != "" {
+		doc["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+		doc["description"] = fmt.Sprintf("Requires the %s role.", op.Role)
+		errs = append([]int{http.StatusUnauthorized, http.StatusForbidden}, errs...)
+	}
+	if op.Sidecar && rt.appToken != "" {
+		doc["security"] = []map[string][]string{{"daprApiToken": {}}}
+		doc["description"] = "Called by the Dapr sidecar with its app API token."
+		errs = append([]int{http.StatusUnauthorized}, errs...)
+	}
+	for _, code := range errs {
+		responses[fmt.Sprint(code)] = map[string]any{
+			"description": http.StatusText(code),
+			"content": map[string]any{
+				"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+			},
+		}
+	}
+	doc["responses"] = responses
+
+	return doc
+}
+
+// document returns the OpenAPI 3.1 document of the routes registered so far.
+func (rt *router) document() map[string]any {
+	components := map[string]any{"schemas": rt.schemas.defs}
+	schemes := map[string]any{}
+	if rt.auth != nil {
+		schemes["apiKey"] = map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"}
+		schemes["bearer"] = map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+	}
+	if rt.appToken != "" {
+		schemes["daprApiToken"] = map[string]any{"type": "apiKey", "in": "header", "name": AppTokenHeader}
+	}
+	if len(schemes) > 0 {
+		components["securitySchemes"] = schemes
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "dapr-go-samples workflow API",
+			"version": "1.0.0",
+		},
+		"paths":      rt.paths,
+		"components": components,
+	}
+}
+
+// serveDocument registers GET /openapi.json. It must be called after every other route.
+func (rt *router) serveDocument() {
+	var b []byte
+	rt.handle("GET /openapi.json", operation{
+		ID:       "getOpenAPI",
+		Summary:  "This OpenAPI document",
+		Tags:     []string{"meta"},
+		Status:   http.StatusOK,
+		Response: map[string]any{},
+	}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}))
+
+	var err error
+	if b, err = json.MarshalIndent(rt.document(), "", "  "); err != nil {
+		panic(fmt.Sprintf("encode OpenAPI document: %v", err))
+	}
+}
+
+// schemaRegistry derives JSON schemas from Go types the way encoding/json encodes them.
+// Named structs are added to the components and referenced.
+type schemaRegistry struct {
+	defs map[string]any
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+func (s *schemaRegistry) schemaOf(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "description": "Duration in nanoseconds"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		if _, ok := s.defs[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate.
+			s.defs[t.Name()] = nil
+			s.defs[t.Name()] = s.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (s *schemaRegistry) structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	s.addFields(t, props, &required)
+	sort.Strings(required)
+
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *schemaRegistry) addFields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.addFields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+)
+
+// recordingMux records the patterns registered on an http.ServeMux.
+type recordingMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *recordingMux) Handle(pattern string, h http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, h)
+}
+
+var docPathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestRoutesMatchOpenAPI checks that every registered route is in the OpenAPI document and
+// every operation of the document is served by the route it describes.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	svc := NewService(nil,
+		WithSubscriptions([]config.Subscription{
+			{PubSub: "pubsub", Topic: "orders", Workflow: "OrderSagaWorkflow", InstanceIDField: "orderId"},
+			{PubSub: "pubsub", Topic: "approvals", Workflow: "ApprovalWorkflow", InstanceIDField: "id"},
+		}),
+		WithAppToken("token"),
+	)
+	mux := &recordingMux{ServeMux: http.NewServeMux()}
+	RegisterRoutes(mux, svc)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: %d", rec.Code)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string                `json:"operationId"`
+			Security    []map[string][]string `json:"security"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+
+	registered := make(map[string]bool)
+	for _, pattern := range mux.patterns {
+		registered[pattern] = true
+		method, path, _ := strings.Cut(pattern, " ")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is not in the OpenAPI document", pattern)
+		}
+	}
+
+	documented := 0
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			documented++
+			pattern := strings.ToUpper(method) + " " + path
+			if !registered[pattern] {
+				t.Errorf("operation %s (%s) is not registered", op.OperationID, pattern)
+				continue
+			}
+			// The mux must send a request for the documented path to that route.
+			target := docPathParam.ReplaceAllString(path, "x")
+			if _, got := mux.Handler(httptest.NewRequest(strings.ToUpper(method), target, nil)); got != pattern {
+				t.Errorf("%s %s is served by %q, want %q", strings.ToUpper(method), target, got, pattern)
+			}
+		}
+	}
+	if documented != len(mux.patterns) {
+		t.Errorf("document has %d operations for %d routes", documented, len(mux.patterns))
+	}
+
+	for _, pattern := range []string{"GET /dapr/subscribe", "POST /events/pubsub/orders", "POST /events/pubsub/approvals"} {
+		method, path, _ := strings.Cut(pattern, " ")
+		op, ok := doc.Paths[path][strings.ToLower(method)]
+		if !ok {
+			t.Errorf("subscription route %q is not documented", pattern)
+			continue
+		}
+		if len(op.Security) != 1 || op.Security[0]["daprApiToken"] == nil {
+			t.Errorf("subscription route %q has security %v, want the Dapr app token", pattern, op.Security)
+		}
+	}
+}
+
+func TestSubscriptionRoutesRequireAppToken(t *testing.T) {
+	svc := NewService(nil, WithAppToken("token"))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	for _, tc := range []struct {
+		token string
+		want  int
+	}{
+		{"", http.StatusUnauthorized},
+		{"wrong", http.StatusUnauthorized},
+		{"token", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+		if tc.token != "" {
+			req.Header.Set(AppTokenHeader, tc.token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("token %q: got %d, want %d", tc.token, rec.Code, tc.want)
+		}
+	}
+}
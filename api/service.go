@@ -0,0 +1,441 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+	"github.com/javier-aliaga/dapr-go-samples/retention"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+	"github.com/javier-aliaga/dapr-go-samples/watchdog"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// Errors returned by Service. The HTTP and gRPC layers map them to status codes; anything
+// else is reported as an internal error.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrUnavailable     = errors.New("unavailable")
+)
+
+// Service implements the workflow operations shared by the HTTP and gRPC APIs. When the
+// context is scoped to a tenant, instance IDs are tenant-local: they are prefixed with the
+// tenant on the way in and stripped on the way out, so a tenant can only reach its own
+// instances.
+type Service struct {
+	runtime  *dapr.WorkflowRuntime
+	watchers *watchers
+	auth     Authenticator
+	// requireTenant rejects requests that resolve to no tenant.
+	requireTenant bool
+	admission     *admission
+	bulk          *bulkJobs
+	retention     *retention.Manager
+	watchdog      *watchdog.Watchdog
+	subscriptions []config.Subscription
+	appToken      string
+	lastInstances *lastInstances
+}
+
+// NewService returns a Service backed by runtime.
+func NewService(runtime *dapr.WorkflowRuntime, opts ...Option) *Service {
+	o := options{maxWatchers: defaultMaxWatchers}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Service{
+		runtime:  runtime,
+		watchers: newWatchers(o.maxWatchers),
+		auth:     o.authenticator,
+
+		requireTenant: o.requireTenant,
+		admission:     newAdmission(o.limits),
+		bulk:          newBulkJobs(),
+		retention:     o.retention,
+		watchdog:      o.watchdog,
+		subscriptions: o.subscriptions,
+		appToken:      o.appToken,
+		lastInstances: newLastInstances(),
+	}
+}
+
+// StartOptions describes a workflow instance to start.
+type StartOptions struct {
+	// Workflow is the registered name of the workflow.
+	Workflow string
+	// InstanceID is optional; the runtime generates one when empty.
+	InstanceID string
+	// Input is optional JSON input.
+	Input json.RawMessage
+	// CallbackURL is added to the input object as "callbackUrl".
+	CallbackURL string
+}
+
+// Start schedules a new instance and returns its ID. Starts beyond the admission limits
+// fail with ErrRateLimited.
+func (s *Service) Start(ctx context.Context, opts StartOptions) (string, error) {
+	t := tenant.FromContext(ctx)
+	if !slices.Contains(s.runtime.Workflows(), opts.Workflow) {
+		return "", fmt.Errorf("%w: unknown workflow %q", ErrInvalidArgument, opts.Workflow)
+	}
+	if len(opts.Input) > 0 && !json.Valid(opts.Input) {
+		return "", fmt.Errorf("%w: input must be valid JSON", ErrInvalidArgument)
+	}
+
+	input := opts.Input
+	if opts.CallbackURL != "" {
+		if err := callbacks.CheckURL(opts.CallbackURL); err != nil {
+			return "", fmt.Errorf("%w: callbackUrl: %v", ErrInvalidArgument, err)
+		}
+		var err error
+		if input, err = withCallbackURL(input, opts.CallbackURL); err != nil {
+			return "", err
+		}
+	}
+
+	id := opts.InstanceID
+	if id == "" && t != "" {
+		id = uuid.NewString()
+	}
+
+	var wopts []workflow.NewWorkflowOptions
+	if id != "" {
+		wopts = append(wopts, workflow.WithInstanceID(tenant.InstanceID(t, id)))
+	}
+	if len(input) > 0 {
+		wopts = append(wopts, workflow.WithInput(input))
+	}
+
+	if err := s.admission.admit(ctx, s.completedInstances); err != nil {
+		return "", err
+	}
+
+	instanceID, err := s.runtime.Client().ScheduleWorkflow(ctx, opts.Workflow, wopts...)
+	if err != nil {
+		s.admission.release()
+		return "", fmt.Errorf("failed to start workflow: %w", err)
+	}
+	s.admission.track(instanceID)
+	id, _ = tenant.LocalID(t, instanceID)
+	return id, nil
+}
+
+// withCallbackURL sets the "callbackUrl" field of a JSON object input, which is where
+// workflows.WithCompletionCallback looks for it.
+func withCallbackURL(input json.RawMessage, callbackURL string) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if len(input) > 0 && string(input) != "null" {
+		if err := json.Unmarshal(input, &fields); err != nil {
+			return nil, fmt.Errorf("%w: input must be a JSON object to carry a callbackUrl", ErrInvalidArgument)
+		}
+	}
+	fields["callbackUrl"], _ = json.Marshal(callbackURL)
+	return json.Marshal(fields)
+}
+
+// Get returns the status of an instance, including its payloads.
+func (s *Service) Get(ctx context.Context, id string) (WorkflowStatus, error) {
+	meta, err := s.fetch(ctx, id)
+	if err != nil {
+		return WorkflowStatus{}, err
+	}
+	return scopedStatus(id, meta), nil
+}
+
+// instanceID returns the runtime instance ID of the tenant-local id.
+func instanceID(ctx context.Context, id string) string {
+	return tenant.InstanceID(tenant.FromContext(ctx), id)
+}
+
+// scopedStatus returns the status of meta under the tenant-local id it was fetched with.
+func scopedStatus(id string, meta *workflow.WorkflowMetadata) WorkflowStatus {
+	st := NewWorkflowStatus(meta)
+	st.InstanceID = id
+	return st
+}
+
+func (s *Service) fetch(ctx context.Context, id string) (*workflow.WorkflowMetadata, error) {
+	meta, err := s.runtime.Client().FetchWorkflowMetadata(ctx, instanceID(ctx, id), workflow.WithFetchPayloads(true))
+	if instances.IsNotFound(err) {
+		return nil, fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch workflow %s: %w", id, err)
+	}
+	return meta, nil
+}
+
+// RaiseEvent raises the named event on an instance. A non-empty payload must be valid JSON.
+// A workflow.event_raised lifecycle event is published once the event is delivered.
+func (s *Service) RaiseEvent(ctx context.Context, id, event string, payload []byte) error {
+	if event == "" {
+		return fmt.Errorf("%w: event name is required", ErrInvalidArgument)
+	}
+
+	var opts []workflow.RaiseEventOptions
+	if len(payload) > 0 {
+		if !json.Valid(payload) {
+			return fmt.Errorf("%w: event payload must be valid JSON", ErrInvalidArgument)
+		}
+		opts = append(opts, workflow.WithEventPayload(json.RawMessage(payload)))
+	}
+
+	err := s.runtime.Client().RaiseEvent(ctx, instanceID(ctx, id), event, opts...)
+	if instances.IsNotFound(err) {
+		return fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to raise event %s for workflow %s: %w", event, id, err)
+	}
+
+	publishEventRaised(ctx, instanceID(ctx, id), event)
+	return nil
+}
+
+// Terminate stops an instance and its children. reason, when set, becomes its output.
+func (s *Service) Terminate(ctx context.Context, id, reason string) error {
+	opts := []workflow.TerminateOptions{workflow.WithRecursiveTerminate(true)}
+	if reason != "" {
+		opts = append(opts, workflow.WithOutput(reason))
+	}
+
+	err := s.runtime.Client().TerminateWorkflow(ctx, instanceID(ctx, id), opts...)
+	if instances.IsNotFound(err) {
+		return fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to terminate workflow %s: %w", id, err)
+	}
+	return nil
+}
+
+// Suspend pauses an instance until it is resumed.
+func (s *Service) Suspend(ctx context.Context, id, reason string) error {
+	err := s.runtime.Client().SuspendWorkflow(ctx, instanceID(ctx, id), reason)
+	if instances.IsNotFound(err) {
+		return fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to suspend workflow %s: %w", id, err)
+	}
+	return nil
+}
+
+// Resume continues a suspended instance.
+func (s *Service) Resume(ctx context.Context, id, reason string) error {
+	err := s.runtime.Client().ResumeWorkflow(ctx, instanceID(ctx, id), reason)
+	if instances.IsNotFound(err) {
+		return fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resume workflow %s: %w", id, err)
+	}
+	return nil
+}
+
+// Purge deletes the state of a completed instance and its children.
+func (s *Service) Purge(ctx context.Context, id string) error {
+	err := s.runtime.Client().PurgeWorkflowState(ctx, instanceID(ctx, id), workflow.WithRecursivePurge(true))
+	if instances.IsNotFound(err) {
+		return fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to purge workflow %s: %w", id, err)
+	}
+	if err := callbacks.Current().Log.Delete(ctx, instanceID(ctx, id)); err != nil {
+		log.Warnf("Purged workflow %s but kept its callback deliveries: %v", id, err)
+	}
+	return nil
+}
+
+// History returns the history events of an instance.
+func (s *Service) History(ctx context.Context, id string) ([]HistoryEvent, error) {
+	resp, err := s.runtime.Client().GetInstanceHistory(ctx, instanceID(ctx, id))
+	if instances.IsNotFound(err) {
+		return nil, fmt.Errorf("workflow %s %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of workflow %s: %w", id, err)
+	}
+	return NewHistoryEvents(resp.Events), nil
+}
+
+// InstancePage is one page of instance IDs. ContinuationToken is empty on the last page.
+type InstancePage struct {
+	InstanceIDs       []string `json:"instanceIds"`
+	ContinuationToken string   `json:"continuationToken,omitempty"`
+}
+
+// List returns a page of instance IDs. A zero pageSize uses the runtime default. Pages of
+// a tenant only hold its own instances, so they can be shorter than pageSize.
+func (s *Service) List(ctx context.Context, pageSize uint32, token string) (InstancePage, error) {
+	var opts []workflow.ListInstanceIDsOptions
+	if pageSize > 0 {
+		opts = append(opts, workflow.WithListInstanceIDsPageSize(pageSize))
+	}
+	if token != "" {
+		opts = append(opts, workflow.WithListInstanceIDsContinuationToken(token))
+	}
+
+	resp, err := s.runtime.Client().ListInstanceIDs(ctx, opts...)
+	if err != nil {
+		return InstancePage{}, fmt.Errorf("failed to list workflows: %w", err)
+	}
+
+	t := tenant.FromContext(ctx)
+	page := InstancePage{InstanceIDs: make([]string, 0, len(resp.InstanceIds))}
+	for _, full := range resp.InstanceIds {
+		if id, ok := tenant.LocalID(t, full); ok {
+			page.InstanceIDs = append(page.InstanceIDs, id)
+		}
+	}
+	if resp.ContinuationToken != nil {
+		page.ContinuationToken = *resp.ContinuationToken
+	}
+	return page, nil
+}
+
+// WatchHandler receives the updates of Service.Watch.
+type WatchHandler struct {
+	// Send is called with "status" whenever the runtime or custom status changes and with
+	// "completed" once for the terminal state.
+	Send func(event string, status WorkflowStatus) error
+	// Heartbeat, when set, is called periodically while nothing changes.
+	Heartbeat func() error
+}
+
+// Watch calls h for every status change of an instance until it completes, ctx is done or
+// h returns an error. Errors raised before the first update, such as ErrNotFound, are
+// returned so callers can still report them as a status code.
+func (s *Service) Watch(ctx context.Context, id string, h WatchHandler) error {
+	if !s.watchers.tryAcquire() {
+		return &retryAfterError{
+			err:   fmt.Errorf("%w: too many concurrent watchers", ErrUnavailable),
+			after: watchRetryAfter,
+		}
+	}
+	defer s.watchers.release()
+
+	meta, err := s.fetch(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	client := s.runtime.Client()
+	completed := make(chan *workflow.WorkflowMetadata, 1)
+	go func() {
+		meta, err := client.WaitForWorkflowCompletion(ctx, instanceID(ctx, id), workflow.WithFetchPayloads(true))
+		if err == nil {
+			completed <- meta
+		}
+	}()
+
+	poll := time.NewTicker(watchPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var last []byte
+	for {
+		st := scopedStatus(id, meta)
+		if workflow.WorkflowMetadataIsComplete(meta) {
+			_ = h.Send("completed", st)
+			return nil
+		}
+		if b, err := json.Marshal(st); err == nil && !bytes.Equal(b, last) {
+			last = b
+			if err := h.Send("status", st); err != nil {
+				log.Debugf("Watcher of %s disconnected: %v", id, err)
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case meta = <-completed:
+		case <-heartbeat.C:
+			if h.Heartbeat != nil {
+				if err := h.Heartbeat(); err != nil {
+					return nil
+				}
+			}
+		case <-poll.C:
+			next, err := client.FetchWorkflowMetadata(ctx, instanceID(ctx, id), workflow.WithFetchPayloads(true))
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Warnf("Watcher of %s failed to fetch status: %v", id, err)
+				continue
+			}
+			meta = next
+		}
+	}
+}
+
+// Limits returns the current admission limits.
+func (s *Service) Limits() config.Admission {
+	return s.admission.Limits()
+}
+
+// SetLimits replaces the admission limits.
+func (s *Service) SetLimits(limits config.Admission) error {
+	if err := limits.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	s.admission.setLimits(limits)
+	log.Infof("Admission limits updated: %+v", limits)
+	return nil
+}
+
+// RetentionReport returns the report of the latest retention run.
+func (s *Service) RetentionReport() (retention.Report, error) {
+	if s.retention == nil {
+		return retention.Report{}, fmt.Errorf("retention is not enabled: %w", ErrNotFound)
+	}
+	report, ok := s.retention.LastReport()
+	if !ok {
+		return retention.Report{}, fmt.Errorf("retention has not run yet: %w", ErrNotFound)
+	}
+	return report, nil
+}
+
+// StuckInstances returns the result of the latest watchdog scan.
+func (s *Service) StuckInstances() (watchdog.Scan, error) {
+	if s.watchdog == nil {
+		return watchdog.Scan{}, fmt.Errorf("watchdog is not enabled: %w", ErrNotFound)
+	}
+	scan, ok := s.watchdog.LastScan()
+	if !ok {
+		return watchdog.Scan{}, fmt.Errorf("watchdog has not scanned yet: %w", ErrNotFound)
+	}
+	return scan, nil
+}
+
+// CallbackDeliveries returns the completion callback attempts recorded for an instance.
+func (s *Service) CallbackDeliveries(ctx context.Context, id string) ([]callbacks.Delivery, error) {
+	deliveries, err := callbacks.Current().Log.List(ctx, instanceID(ctx, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery log for %s: %w", id, err)
+	}
+	for i := range deliveries {
+		deliveries[i].InstanceID = id
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+// configureCallbacks installs callback settings with a fresh delivery log until the test
+// ends, and returns the log.
+func configureCallbacks(t *testing.T, allowPrivate bool) *callbacks.MemoryLog {
+	t.Helper()
+	prev := *callbacks.Current()
+	t.Cleanup(func() { callbacks.Configure(prev) })
+	deliveries := callbacks.NewMemoryLog()
+	callbacks.Configure(callbacks.Settings{Log: deliveries, AllowPrivateNetworks: allowPrivate})
+	return deliveries
+}
+
+func TestStartChecksCallbackURL(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	for _, tc := range []struct {
+		url          string
+		allowPrivate bool
+		want         int
+	}{
+		{url: "https://example.com/hook", want: http.StatusAccepted},
+		{url: "http://127.0.0.1:9000/hook", want: http.StatusBadRequest},
+		{url: "http://10.0.0.5/hook", want: http.StatusBadRequest},
+		{url: "http://169.254.169.254/latest/meta-data", want: http.StatusBadRequest},
+		{url: "http://127.0.0.1:9000/hook", allowPrivate: true, want: http.StatusAccepted},
+		{url: "file:///etc/passwd", allowPrivate: true, want: http.StatusBadRequest},
+	} {
+		configureCallbacks(t, tc.allowPrivate)
+		rec := call(mux, http.MethodPost, "/workflow", "", `{"callbackUrl":"`+tc.url+`"}`)
+		if rec.Code != tc.want {
+			t.Errorf("%s with private networks %v: %d %s, want %d", tc.url, tc.allowPrivate, rec.Code, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestPurgeDeletesCallbackDeliveries(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+	deliveries := configureCallbacks(t, false)
+
+	ctx := context.Background()
+	for _, id := range []string{"acme~order-1", "order-1"} {
+		sidecar.Put(daprtest.Instance{ID: id, Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED})
+		_ = deliveries.Record(ctx, callbacks.Delivery{InstanceID: id, Succeeded: true})
+	}
+
+	if rec := call(mux, http.MethodPost, "/workflows/order-1/purge", "acme", ""); rec.Code != http.StatusOK {
+		t.Fatalf("purge: %d %s", rec.Code, rec.Body)
+	}
+	if logged, _ := deliveries.List(ctx, "acme~order-1"); len(logged) != 0 {
+		t.Errorf("deliveries of the purged instance %+v, want none", logged)
+	}
+	if logged, _ := deliveries.List(ctx, "order-1"); len(logged) != 1 {
+		t.Errorf("deliveries of another tenant's instance %+v, want them kept", logged)
+	}
+}
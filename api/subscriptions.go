@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// Status values understood by the Dapr sidecar in a subscription response.
+const (
+	subscriptionSuccess = "SUCCESS"
+	subscriptionRetry   = "RETRY"
+	subscriptionDrop    = "DROP"
+)
+
+// Dapr's app API token. When AppTokenEnv is set in the app's environment, the sidecar sends
+// its value in the AppTokenHeader of every call to the app.
+const (
+	AppTokenEnv    = "APP_API_TOKEN"
+	AppTokenHeader = "dapr-api-token"
+)
+
+// WithSubscriptions starts a workflow for every message the sidecar delivers for subs.
+func WithSubscriptions(subs []config.Subscription) Option {
+	return func(o *options) {
+		o.subscriptions = subs
+	}
+}
+
+// WithAppToken makes the routes called by the Dapr sidecar require Dapr's app API token.
+// An empty token leaves them open.
+func WithAppToken(token string) Option {
+	return func(o *options) {
+		o.appToken = token
+	}
+}
+
+// subscription is one entry of the GET /dapr/subscribe response.
+type subscription struct {
+	PubSubName string `json:"pubsubname"`
+	Topic      string `json:"topic"`
+	Route      string `json:"route"`
+}
+
+// subscriptionResponse tells the sidecar what to do with a delivered message.
+type subscriptionResponse struct {
+	Status string `json:"status"`
+}
+
+// cloudEvent is the part of the CloudEvent envelope delivered by Dapr that we need.
+type cloudEvent struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Data       json.RawMessage `json:"data"`
+	DataBase64 string          `json:"data_base64"`
+}
+
+// registerSubscriptions exposes the programmatic subscription endpoint and one delivery
+// route per subscription of svc. Each delivered message starts the configured workflow with
+// the message data as input.
+func registerSubscriptions(rt *router, svc *Service) {
+	list := make([]subscription, 0, len(svc.subscriptions))
+	for _, sub := range svc.subscriptions {
+		route := "/events/" + sub.PubSub + "/" + sub.Topic
+		list = append(list, subscription{PubSubName: sub.PubSub, Topic: sub.Topic, Route: route})
+
+		rt.handle("POST "+route, operation{
+			ID:       "deliver-" + sub.PubSub + "-" + sub.Topic,
+			Sidecar:  true,
+			Summary:  fmt.Sprintf("Start %s for a message on %s/%s", sub.Workflow, sub.PubSub, sub.Topic),
+			Tags:     []string{"subscriptions"},
+			Request:  cloudEvent{},
+			Status:   http.StatusOK,
+			Response: subscriptionResponse{},
+			Errors:   []int{http.StatusTooManyRequests, http.StatusInternalServerError},
+		}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			startFromMessage(w, r, svc, sub)
+		}))
+	}
+
+	rt.handle("GET /dapr/subscribe", operation{
+		ID:       "listSubscriptions",
+		Sidecar:  true,
+		Summary:  "Topics the sidecar delivers to this app",
+		Tags:     []string{"subscriptions"},
+		Status:   http.StatusOK,
+		Response: []subscription{},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, list)
+	}))
+}
+
+// requireAppToken wraps h so that only callers presenting token in the AppTokenHeader, the
+// sidecar, reach it.
+func requireAppToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(AppTokenHeader)), []byte(token)) != 1 {
+			writeError(w, fmt.Errorf("%w: missing or invalid %s header", ErrUnauthenticated, AppTokenHeader))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// startFromMessage starts the workflow of sub through svc, so that the tenant of sub and
+// the admission limits apply as they do to API callers.
+func startFromMessage(w http.ResponseWriter, r *http.Request, svc *Service, sub config.Subscription) {
+	var ev cloudEvent
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		log.Warnf("Dropping undecodable message on %s/%s: %v", sub.PubSub, sub.Topic, err)
+		writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionDrop})
+		return
+	}
+
+	data := []byte(ev.Data)
+	if ev.DataBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(ev.DataBase64)
+		if err != nil {
+			log.Warnf("Dropping message %s on %s/%s: invalid data_base64: %v", ev.ID, sub.PubSub, sub.Topic, err)
+			writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionDrop})
+			return
+		}
+		data = decoded
+	}
+
+	key, err := lookupField(data, sub.InstanceIDField)
+	if err != nil {
+		log.Warnf("Dropping message %s on %s/%s: %v", ev.ID, sub.PubSub, sub.Topic, err)
+		writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionDrop})
+		return
+	}
+	id := sub.InstanceIDPrefix + key
+	// Instance IDs of tenants are "<tenant>~<id>", so a key carrying the separator could
+	// reach an instance of another tenant.
+	if strings.Contains(id, tenant.Separator) {
+		log.Warnf("Dropping message %s on %s/%s: instance ID %q contains %q", ev.ID, sub.PubSub, sub.Topic, id, tenant.Separator)
+		writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionDrop})
+		return
+	}
+
+	ctx := r.Context()
+	if sub.Tenant != "" {
+		ctx = tenant.WithContext(ctx, sub.Tenant)
+	}
+
+	// The instance ID is derived from the message, so an existing instance means this
+	// message (or one with the same key) was already handled.
+	if _, err := svc.runtime.Client().FetchWorkflowMetadata(ctx, instanceID(ctx, id)); err == nil {
+		log.Infof("Workflow %s already exists, acknowledging message %s", id, ev.ID)
+		writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionSuccess})
+		return
+	} else if !instances.IsNotFound(err) {
+		log.Errorf("Failed to look up workflow %s: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, subscriptionResponse{Status: subscriptionRetry})
+		return
+	}
+
+	_, err = svc.Start(ctx, StartOptions{Workflow: sub.Workflow, InstanceID: id, Input: data})
+	switch {
+	case err == nil, status.Code(err) == codes.AlreadyExists:
+	case errors.Is(err, ErrRateLimited):
+		log.Warnf("Deferring message %s on %s/%s: %v", ev.ID, sub.PubSub, sub.Topic, err)
+		writeJSON(w, http.StatusTooManyRequests, subscriptionResponse{Status: subscriptionRetry})
+		return
+	case errors.Is(err, ErrInvalidArgument):
+		log.Warnf("Dropping message %s on %s/%s: %v", ev.ID, sub.PubSub, sub.Topic, err)
+		writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionDrop})
+		return
+	default:
+		log.Errorf("Failed to start %s for message %s: %v", sub.Workflow, ev.ID, err)
+		writeJSON(w, http.StatusInternalServerError, subscriptionResponse{Status: subscriptionRetry})
+		return
+	}
+
+	log.Infof("Started %s %s from message %s on %s/%s", sub.Workflow, id, ev.ID, sub.PubSub, sub.Topic)
+	writeJSON(w, http.StatusOK, subscriptionResponse{Status: subscriptionSuccess})
+}
+
+// lookupField returns the string or number found at the dot-separated path in a JSON object.
+func lookupField(data []byte, path string) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var cur any
+	if err := dec.Decode(&cur); err != nil {
+		return "", fmt.Errorf("message data is not JSON: %w", err)
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("field %s not found", path)
+		}
+		if cur, ok = obj[part]; !ok {
+			return "", fmt.Errorf("field %s not found", path)
+		}
+	}
+
+	switch v := cur.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("field %s is empty", path)
+		}
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("field %s is not a string or number", path)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+// deliver posts a CloudEvent with data to route the way the sidecar does, and returns the
+// status code and the status of the subscription response.
+func deliver(t *testing.T, h http.Handler, route, token, data string) (int, string) {
+	t.Helper()
+	body := `{"id":"msg-1","type":"com.dapr.event.sent","data":` + data + `}`
+	req := httptest.NewRequest(http.MethodPost, route, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	if token != "" {
+		req.Header.Set(AppTokenHeader, token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp subscriptionResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp.Status
+}
+
+func TestSubscriptionStartsWorkflow(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "OrderSagaWorkflow"),
+		WithSubscriptions([]config.Subscription{
+			{PubSub: "pubsub", Topic: "orders", Workflow: "OrderSagaWorkflow", InstanceIDField: "order.id", InstanceIDPrefix: "order-"},
+			{PubSub: "pubsub", Topic: "acme-orders", Workflow: "OrderSagaWorkflow", InstanceIDField: "id", Tenant: "acme"},
+		}),
+		WithAppToken("token"),
+	)
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	for _, tc := range []struct {
+		name       string
+		route      string
+		token      string
+		data       string
+		wantCode   int
+		wantStatus string
+		// started is the instance the message starts, if any.
+		started string
+	}{
+		{name: "no app token", route: "/events/pubsub/orders", data: `{"order":{"id":"1"}}`, wantCode: http.StatusUnauthorized},
+		{name: "new message", route: "/events/pubsub/orders", token: "token", data: `{"order":{"id":"1"}}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionSuccess, started: "order-1"},
+		{name: "redelivered", route: "/events/pubsub/orders", token: "token", data: `{"order":{"id":"1"}}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionSuccess},
+		{name: "numeric key", route: "/events/pubsub/orders", token: "token", data: `{"order":{"id":2}}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionSuccess, started: "order-2"},
+		{name: "tenant", route: "/events/pubsub/acme-orders", token: "token", data: `{"id":"1"}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionSuccess, started: "acme~1"},
+		{name: "no key", route: "/events/pubsub/orders", token: "token", data: `{"order":{}}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionDrop},
+		{name: "not JSON", route: "/events/pubsub/orders", token: "token", data: `"text"`,
+			wantCode: http.StatusOK, wantStatus: subscriptionDrop},
+		// The key must not address an instance of another tenant.
+		{name: "key with tenant separator", route: "/events/pubsub/orders", token: "token", data: `{"order":{"id":"x~1"}}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionDrop},
+		{name: "tenant key with tenant separator", route: "/events/pubsub/acme-orders", token: "token", data: `{"id":"other~1"}`,
+			wantCode: http.StatusOK, wantStatus: subscriptionDrop},
+	} {
+		before := sidecar.IDs()
+		code, st := deliver(t, mux, tc.route, tc.token, tc.data)
+		if code != tc.wantCode || st != tc.wantStatus {
+			t.Errorf("%s: %d %q, want %d %q", tc.name, code, st, tc.wantCode, tc.wantStatus)
+		}
+		var want []string
+		if tc.started != "" {
+			want = []string{tc.started}
+		}
+		if got := sidecar.IDs()[len(before):]; !reflect.DeepEqual(got, want) && (len(got) > 0 || len(want) > 0) {
+			t.Errorf("%s: started %v, want %v", tc.name, got, want)
+		}
+	}
+
+	if in, _ := sidecar.Instance("order-1"); in.Input != `{"order":{"id":"1"}}` {
+		t.Errorf("order-1 input = %s, want the message data", in.Input)
+	}
+}
+
+func TestSubscriptionLookupErrors(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "OrderSagaWorkflow"),
+		WithSubscriptions([]config.Subscription{
+			{PubSub: "pubsub", Topic: "orders", Workflow: "OrderSagaWorkflow", InstanceIDField: "id"},
+		}),
+	)
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	// A sidecar answering NotFound for a missing instance is not an error.
+	sidecar.ReportNotFoundAsStatus(true)
+	if code, st := deliver(t, mux, "/events/pubsub/orders", "", `{"id":"1"}`); st != subscriptionSuccess {
+		t.Errorf("NotFound status: %d %q, want %q", code, st, subscriptionSuccess)
+	}
+	if _, ok := sidecar.Instance("1"); !ok {
+		t.Error("NotFound status: instance was not started")
+	}
+
+	// Other errors leave the message to be retried.
+	sidecar.Fail("GetInstance", status.Error(codes.Unavailable, "sidecar is down"))
+	if code, st := deliver(t, mux, "/events/pubsub/orders", "", `{"id":"2"}`); code != http.StatusInternalServerError || st != subscriptionRetry {
+		t.Errorf("Unavailable: %d %q, want 500 %q", code, st, subscriptionRetry)
+	}
+	if _, ok := sidecar.Instance("2"); ok {
+		t.Error("Unavailable: instance was started without checking for a duplicate")
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// TenantHeader selects the tenant of a request for callers that are not bound to one. Over
+// gRPC it is sent as x-tenant-id metadata.
+const TenantHeader = "X-Tenant-ID"
+
+// WithRequiredTenant rejects requests that do not resolve to a tenant, instead of letting
+// them act across all instances.
+func WithRequiredTenant(required bool) Option {
+	return func(o *options) {
+		o.requireTenant = required
+	}
+}
+
+// resolveTenant scopes ctx to the tenant of the request: the tenant the authenticated
+// caller is bound to, or else the requested one. Callers bound to a tenant cannot request
+// another one.
+func resolveTenant(ctx context.Context, requested string, required bool) (context.Context, error) {
+	t := requested
+	if id, ok := IdentityFromContext(ctx); ok && id.Tenant != "" {
+		if requested != "" && requested != id.Tenant {
+			return ctx, fmt.Errorf("%w: %s is bound to tenant %s", ErrPermissionDenied, id.Subject, id.Tenant)
+		}
+		t = id.Tenant
+	}
+
+	if t == "" {
+		if required {
+			return ctx, fmt.Errorf("%w: a tenant is required", ErrInvalidArgument)
+		}
+		return ctx, nil
+	}
+	if err := tenant.Validate(t); err != nil {
+		return ctx, fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("tenant.id", t))
+	return tenant.WithContext(ctx, t), nil
+}
+
+// scopeTenant wraps h so that it runs scoped to the tenant of the request.
+func scopeTenant(required bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := resolveTenant(r.Context(), r.Header.Get(TenantHeader), required)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireUnbound wraps h so that callers bound to a tenant cannot reach it.
+func requireUnbound(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := IdentityFromContext(r.Context()); ok && id.Tenant != "" {
+			writeError(w, fmt.Errorf("%w: %s is bound to tenant %s", ErrPermissionDenied, id.Subject, id.Tenant))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func grpcTenant(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-tenant-id"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func unaryTenantInterceptor(required bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := resolveTenant(ctx, grpcTenant(ctx), required)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamTenantInterceptor(required bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := resolveTenant(ss.Context(), grpcTenant(ss.Context()), required)
+		if err != nil {
+			return grpcError(err)
+		}
+		return handler(srv, &scopedStream{ServerStream: ss, ctx: ctx})
+	}
+}
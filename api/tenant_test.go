@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+// call sends a request with body to h as tenant, or as no tenant when tenant is "".
+func call(h http.Handler, method, path, tenant, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if tenant != "" {
+		req.Header.Set(TenantHeader, tenant)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTenantIsolation(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow", "OrderSagaWorkflow"))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	for _, tc := range []struct{ tenant, id string }{{"acme", "order-1"}, {"globex", "order-1"}, {"acme", "order-2"}} {
+		rec := call(mux, http.MethodPost, "/workflows", tc.tenant, `{"workflow":"OrderSagaWorkflow","instanceId":"`+tc.id+`"}`)
+		var resp StartResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusAccepted || resp.InstanceID != tc.id {
+			t.Fatalf("start %s as %s: %d %s", tc.id, tc.tenant, rec.Code, rec.Body)
+		}
+	}
+	if got, want := sidecar.IDs(), []string{"acme~order-1", "globex~order-1", "acme~order-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("instances %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		tenant string
+		want   []string
+	}{
+		{tenant: "acme", want: []string{"order-1", "order-2"}},
+		{tenant: "globex", want: []string{"order-1"}},
+		{tenant: "initech", want: []string{}},
+		// Requests without a tenant see the runtime IDs of every tenant.
+		{tenant: "", want: []string{"acme~order-1", "globex~order-1", "acme~order-2"}},
+	} {
+		var page InstancePage
+		rec := call(mux, http.MethodGet, "/workflows", tc.tenant, "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || !reflect.DeepEqual(page.InstanceIDs, tc.want) {
+			t.Errorf("list as %q: %d %s, want %v", tc.tenant, rec.Code, rec.Body, tc.want)
+		}
+	}
+
+	if rec := call(mux, http.MethodGet, "/workflows/order-2", "globex", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get acme's order-2 as globex: %d, want 404", rec.Code)
+	}
+	if rec := call(mux, http.MethodGet, "/workflows/order-2", "acme", ""); rec.Code != http.StatusOK {
+		t.Errorf("get order-2 as acme: %d %s", rec.Code, rec.Body)
+	}
+	if rec := call(mux, http.MethodPost, "/workflows/order-1/terminate", "globex", ""); rec.Code != http.StatusAccepted {
+		t.Errorf("terminate order-1 as globex: %d %s", rec.Code, rec.Body)
+	}
+	if in, _ := sidecar.Instance("acme~order-1"); in.Status.String() != "ORCHESTRATION_STATUS_RUNNING" {
+		t.Errorf("terminating globex's order-1 left acme's %s", in.Status)
+	}
+	if rec := call(mux, http.MethodGet, "/workflows/order-1", "not a tenant", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid tenant: %d, want 400", rec.Code)
+	}
+}
+
+func TestRaiseEventOnLastInstanceOfTenant(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	svc := NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"))
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, svc)
+
+	if rec := call(mux, http.MethodPost, "/workflow/event", "acme", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("raise before any start: %d, want 404", rec.Code)
+	}
+
+	// Tenants start their instances concurrently; each one's event goes to its own.
+	tenants := []string{"acme", "globex", "initech", "umbrella"}
+	var wg sync.WaitGroup
+	for _, tenant := range tenants {
+		wg.Go(func() {
+			if rec := call(mux, http.MethodPost, "/workflow", tenant, ""); rec.Code != http.StatusAccepted {
+				t.Errorf("start as %s: %d %s", tenant, rec.Code, rec.Body)
+			}
+		})
+	}
+	wg.Wait()
+	if rec := call(mux, http.MethodPost, "/workflow/event", "hooli", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("raise as a tenant that started nothing: %d, want 404", rec.Code)
+	}
+	for _, tenant := range tenants {
+		if rec := call(mux, http.MethodPost, "/workflow/event", tenant, ""); rec.Code != http.StatusAccepted {
+			t.Errorf("raise as %s: %d %s", tenant, rec.Code, rec.Body)
+		}
+	}
+
+	for _, id := range sidecar.IDs() {
+		in, _ := sidecar.Instance(id)
+		if len(in.Events) != 1 || in.Events[0].Name != "event" {
+			t.Errorf("%s received %v, want one event", id, in.Events)
+		}
+	}
+}
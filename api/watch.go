@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxWatchers     = 100
+	watchRetryAfter        = 5 * time.Second
+	watchPollInterval      = time.Second
+	watchHeartbeatInterval = 15 * time.Second
+)
+
+// watchers caps the number of concurrent watch streams, over HTTP and gRPC.
+type watchers struct {
+	slots chan struct{}
+}
+
+func newWatchers(max int) *watchers {
+	return &watchers{slots: make(chan struct{}, max)}
+}
+
+func (ws *watchers) tryAcquire() bool {
+	select {
+	case ws.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ws *watchers) release() {
+	<-ws.slots
+}
+
+// watchWorkflow streams the status of an instance as Server-Sent Events. A "status" event is
+// sent whenever the runtime or custom status changes and a final "completed" event once the
+// instance reaches a terminal state. Comment lines are sent as heartbeats so proxies keep
+// the connection open.
+func watchWorkflow(w http.ResponseWriter, r *http.Request, svc *Service) {
+	id := r.PathValue("id")
+	rc := http.NewResponseController(w)
+
+	// Headers are only written with the first event, so that errors found before it can
+	// still be reported with a status code.
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		// Streams outlive the server's WriteTimeout.
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Warnf("Cannot clear write deadline for watcher of %s: %v", id, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	err := svc.Watch(r.Context(), id, WatchHandler{
+		Send: func(event string, status WorkflowStatus) error {
+			b, err := json.Marshal(status)
+			if err != nil {
+				return err
+			}
+			start()
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+				return err
+			}
+			return rc.Flush()
+		},
+		Heartbeat: func() error {
+			start()
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		},
+	})
+	if err != nil && !started {
+		writeError(w, err)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+// sseStream reads the events of a Server-Sent Events response.
+type sseStream struct {
+	t    *testing.T
+	resp *http.Response
+	scan *bufio.Scanner
+}
+
+func watch(t *testing.T, srv *httptest.Server, id, tenant string) *sseStream {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/workflows/"+id+"/watch", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant != "" {
+		req.Header.Set(TenantHeader, tenant)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return &sseStream{t: t, resp: resp, scan: bufio.NewScanner(resp.Body)}
+}
+
+// next returns the name and status of the next event, skipping comments.
+func (s *sseStream) next() (string, WorkflowStatus) {
+	s.t.Helper()
+	var event string
+	var status WorkflowStatus
+	for s.scan.Scan() {
+		line := s.scan.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &status); err != nil {
+				s.t.Fatal(err)
+			}
+		case line == "" && event != "":
+			return event, status
+		}
+	}
+	s.t.Fatalf("stream ended: %v", s.scan.Err())
+	return "", status
+}
+
+func TestWatchWorkflow(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow")))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	running := daprtest.Instance{ID: "acme~order-1", Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING}
+	sidecar.Put(running)
+
+	s := watch(t, srv, "order-1", "acme")
+	if s.resp.StatusCode != http.StatusOK || s.resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("watch: %d %s", s.resp.StatusCode, s.resp.Header.Get("Content-Type"))
+	}
+	if event, st := s.next(); event != "status" || st.InstanceID != "order-1" || st.RuntimeStatus != "RUNNING" {
+		t.Fatalf("first event %s %+v, want the running status with the tenant-local ID", event, st)
+	}
+
+	// Changes to the custom status are streamed as they are polled.
+	running.CustomStatus = `{"step":2}`
+	sidecar.Put(running)
+	if event, st := s.next(); event != "status" || string(st.CustomStatus) != `{"step":2}` {
+		t.Fatalf("second event %s %+v, want the new custom status", event, st)
+	}
+
+	completed := running
+	completed.Status = protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED
+	completed.Output = `"done"`
+	sidecar.Put(completed)
+	if event, st := s.next(); event != "completed" || st.RuntimeStatus != "COMPLETED" || st.Output != `"done"` {
+		t.Fatalf("last event %s %+v, want the completed status", event, st)
+	}
+	if s.scan.Scan() {
+		t.Errorf("stream went on after completion with %q", s.scan.Text())
+	}
+
+	// Errors found before the first event get a status code.
+	for _, tc := range []struct{ id, tenant string }{{"missing", ""}, {"order-1", "globex"}} {
+		if s := watch(t, srv, tc.id, tc.tenant); s.resp.StatusCode != http.StatusNotFound {
+			t.Errorf("watch %s as %q: %d, want %d", tc.id, tc.tenant, s.resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestWatchWorkflowLimit(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	mux := http.NewServeMux()
+	RegisterRoutes(mux, NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow"), WithMaxWatchers(1)))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	sidecar.Put(daprtest.Instance{ID: "order-1", Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING})
+
+	first := watch(t, srv, "order-1", "")
+	first.next()
+
+	second := watch(t, srv, "order-1", "")
+	if second.resp.StatusCode != http.StatusServiceUnavailable || second.resp.Header.Get("Retry-After") != "5" {
+		t.Fatalf("second watcher: %d, Retry-After %q, want %d", second.resp.StatusCode, second.resp.Header.Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+
+	// A stream frees its slot once it ends.
+	_ = first.resp.Body.Close()
+	sidecar.Put(daprtest.Instance{ID: "order-1", Name: "SimpleWorkflow", Status: protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED})
+	for {
+		s := watch(t, srv, "order-1", "")
+		if s.resp.StatusCode == http.StatusOK {
+			if event, _ := s.next(); event != "completed" {
+				t.Errorf("event %s, want completed", event)
+			}
+			break
+		}
+		if s.resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("watch after closing the first stream: %d", s.resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
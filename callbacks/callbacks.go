@@ -0,0 +1,181 @@
+// Package callbacks signs completion webhooks and records their delivery attempts.
+package callbacks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/state"
+)
+
+// Headers set on every callback request.
+const (
+	HeaderInstanceID = "X-Webhook-Instance-Id"
+	HeaderTimestamp  = "X-Webhook-Timestamp"
+	// HeaderSignature is "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the body POSTed to a callback URL when a workflow finishes.
+type Payload struct {
+	InstanceID    string          `json:"instanceId"`
+	Workflow      string          `json:"workflow"`
+	RuntimeStatus string          `json:"runtimeStatus"`
+	Output        json.RawMessage `json:"output,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	CompletedAt   time.Time       `json:"completedAt"`
+}
+
+// Sign returns the HeaderSignature value for body sent at timestamp. Receivers recompute it
+// with the shared secret and should reject stale timestamps to prevent replays.
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Delivery is one attempt to call a callback URL.
+type Delivery struct {
+	InstanceID string    `json:"instanceId"`
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Succeeded  bool      `json:"succeeded"`
+}
+
+// DeliveryLog records callback delivery attempts.
+type DeliveryLog interface {
+	// Record appends d to the log of its instance, numbering it after the attempts recorded
+	// before.
+	Record(ctx context.Context, d Delivery) error
+	List(ctx context.Context, instanceID string) ([]Delivery, error)
+	// Delete removes the log of an instance, once the instance is purged.
+	Delete(ctx context.Context, instanceID string) error
+}
+
+// MemoryLog is a DeliveryLog kept in process memory. It only numbers and lists the attempts
+// made by this process.
+type MemoryLog struct {
+	mu         sync.Mutex
+	deliveries map[string][]Delivery
+}
+
+func NewMemoryLog() *MemoryLog {
+	return &MemoryLog{deliveries: make(map[string][]Delivery)}
+}
+
+func (l *MemoryLog) Record(_ context.Context, d Delivery) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d.Attempt = len(l.deliveries[d.InstanceID]) + 1
+	l.deliveries[d.InstanceID] = append(l.deliveries[d.InstanceID], d)
+	return nil
+}
+
+func (l *MemoryLog) List(_ context.Context, instanceID string) ([]Delivery, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Delivery(nil), l.deliveries[instanceID]...), nil
+}
+
+func (l *MemoryLog) Delete(_ context.Context, instanceID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.deliveries, instanceID)
+	return nil
+}
+
+// storeLogAttempts bounds the attempts to append to a log that other replicas write
+// meanwhile.
+const storeLogAttempts = 10
+
+// StoreLog is a DeliveryLog kept in a state store, one key per instance, so that every
+// replica sees the attempts of the others and the log survives restarts.
+type StoreLog struct {
+	Store state.Store
+}
+
+func (l *StoreLog) Record(ctx context.Context, d Delivery) error {
+	_, err := state.Update(ctx, l.Store, deliveriesKey(d.InstanceID), storeLogAttempts, func(item state.Item) (json.RawMessage, error) {
+		deliveries, err := decodeDeliveries(item)
+		if err != nil {
+			return nil, err
+		}
+		d.Attempt = len(deliveries) + 1
+		return json.Marshal(append(deliveries, d))
+	})
+	if err != nil {
+		return fmt.Errorf("record delivery for %s: %w", d.InstanceID, err)
+	}
+	return nil
+}
+
+func (l *StoreLog) List(ctx context.Context, instanceID string) ([]Delivery, error) {
+	item, err := l.Store.Get(ctx, deliveriesKey(instanceID))
+	if err != nil {
+		return nil, err
+	}
+	return decodeDeliveries(item)
+}
+
+func (l *StoreLog) Delete(ctx context.Context, instanceID string) error {
+	if err := l.Store.Delete(ctx, deliveriesKey(instanceID), ""); err != nil {
+		return fmt.Errorf("delete deliveries of %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+func deliveriesKey(instanceID string) string {
+	return "callbacks/deliveries/" + instanceID
+}
+
+func decodeDeliveries(item state.Item) ([]Delivery, error) {
+	if item.Value == nil {
+		return nil, nil
+	}
+	var deliveries []Delivery
+	if err := json.Unmarshal(item.Value, &deliveries); err != nil {
+		return nil, fmt.Errorf("decode deliveries %s: %w", item.Key, err)
+	}
+	return deliveries, nil
+}
+
+// Settings are the process-wide callback settings.
+type Settings struct {
+	Secret []byte
+	Log    DeliveryLog
+	// AllowPrivateNetworks lets callbacks reach loopback, private and other internal
+	// addresses, for receivers running next to the app.
+	AllowPrivateNetworks bool
+}
+
+var current atomic.Pointer[Settings]
+
+func init() {
+	Configure(Settings{})
+}
+
+// Configure installs the process-wide settings. A nil Log is replaced by a MemoryLog.
+func Configure(s Settings) {
+	if s.Log == nil {
+		s.Log = NewMemoryLog()
+	}
+	current.Store(&s)
+}
+
+// Current returns the installed settings.
+func Current() *Settings {
+	return current.Load()
+}
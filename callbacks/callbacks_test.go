@@ -0,0 +1,67 @@
+package callbacks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/state"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1767225600, 0)
+	got := Sign([]byte("secret"), at, []byte(`{"instanceId":"a"}`))
+	if !strings.HasPrefix(got, "sha256=") || len(got) != len("sha256=")+64 {
+		t.Fatalf("Sign = %q, want sha256= and a hex HMAC-SHA256", got)
+	}
+	if Sign([]byte("secret"), at, []byte(`{"instanceId":"b"}`)) == got {
+		t.Error("the signature does not cover the body")
+	}
+	if Sign([]byte("secret"), at.Add(time.Second), []byte(`{"instanceId":"a"}`)) == got {
+		t.Error("the signature does not cover the timestamp")
+	}
+	if Sign([]byte("other"), at, []byte(`{"instanceId":"a"}`)) == got {
+		t.Error("the signature does not depend on the secret")
+	}
+}
+
+func TestDeliveryLogs(t *testing.T) {
+	ctx := context.Background()
+	for name, log := range map[string]DeliveryLog{
+		"memory": NewMemoryLog(),
+		"store":  &StoreLog{Store: state.NewMemoryStore()},
+	} {
+		for _, d := range []Delivery{
+			{InstanceID: "a", StatusCode: 500},
+			{InstanceID: "b", Succeeded: true},
+			{InstanceID: "a", Succeeded: true},
+		} {
+			if err := log.Record(ctx, d); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		got, err := log.List(ctx, "a")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != 2 || got[0].Attempt != 1 || got[1].Attempt != 2 || !got[1].Succeeded {
+			t.Errorf("%s: deliveries of a %+v, want attempts 1 and 2", name, got)
+		}
+
+		if err := log.Delete(ctx, "a"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, err := log.List(ctx, "a"); err != nil || len(got) != 0 {
+			t.Errorf("%s: deliveries of a after Delete %+v, %v, want none", name, got, err)
+		}
+		if got, _ := log.List(ctx, "b"); len(got) != 1 {
+			t.Errorf("%s: deleting a changed b to %+v", name, got)
+		}
+		// Deleting twice, as when a purge is retried, is not an error.
+		if err := log.Delete(ctx, "a"); err != nil {
+			t.Errorf("%s: second Delete: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package callbacks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenURL is returned for callback URLs that are not http(s) or that address a
+// private, loopback or otherwise internal network.
+var ErrForbiddenURL = errors.New("callback URL is not allowed")
+
+// internalPrefixes are the ranges refused besides the loopback, private, link-local,
+// multicast and unspecified ones that netip classifies.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, which can reach any IPv4 address
+	netip.MustParsePrefix("fec0::/10"),    // site-local
+}
+
+// publicAddr reports whether ip is a public unicast address.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range internalPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that raw is an absolute http(s) URL and, unless private networks are
+// allowed, that its host is not an internal IP address. Host names are checked against the
+// addresses they resolve to when the callback is sent.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an absolute http(s) URL", ErrForbiddenURL, raw)
+	}
+	if Current().AllowPrivateNetworks {
+		return nil
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !publicAddr(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrForbiddenURL, u.Hostname())
+	}
+	return nil
+}
+
+// checkDial refuses connections to internal addresses unless private networks are allowed.
+// It runs on the resolved address, so host names that resolve to internal addresses and
+// redirects to them are refused too.
+func checkDial(_, address string, _ syscall.RawConn) error {
+	if Current().AllowPrivateNetworks {
+		return nil
+	}
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: cannot check address %s: %v", ErrForbiddenURL, address, err)
+	}
+	if !publicAddr(addr.Addr()) {
+		return fmt.Errorf("%w: %s is not a public address", ErrForbiddenURL, addr.Addr())
+	}
+	return nil
+}
+
+// NewTransport returns the transport callbacks are sent with. It connects directly, never
+// through a proxy from the environment, so that the address it checks is the one the
+// callback reaches.
+func NewTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkDial}
+	t.DialContext = dialer.DialContext
+	return t
+}
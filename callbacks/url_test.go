@@ -0,0 +1,101 @@
+package callbacks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+// allowPrivate installs settings that allow private networks or not, until the test ends.
+func allowPrivate(t *testing.T, allow bool) {
+	t.Helper()
+	prev := *Current()
+	t.Cleanup(func() { Configure(prev) })
+	s := prev
+	s.AllowPrivateNetworks = allow
+	Configure(s)
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false, // cloud metadata
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"0.1.2.3":              false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::1":                  false,
+		"::":                   false,
+		"fd00:ec2::254":        false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.0.0.1":      false,
+		"64:ff9b::a00:1":       false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, tc := range []struct {
+		url          string
+		allowPrivate bool
+		wantErr      bool
+	}{
+		{url: "https://example.com/hook"},
+		{url: "http://93.184.216.34:8080/hook"},
+		// Names are checked when they are dialed.
+		{url: "http://localhost/hook"},
+		{url: "http://127.0.0.1/hook", wantErr: true},
+		{url: "http://[::1]:8080/hook", wantErr: true},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{url: "http://127.0.0.1/hook", allowPrivate: true},
+		{url: "ftp://example.com/hook", wantErr: true},
+		{url: "ftp://example.com/hook", allowPrivate: true, wantErr: true},
+		{url: "/hook", wantErr: true},
+		{url: "://", wantErr: true},
+	} {
+		allowPrivate(t, tc.allowPrivate)
+		err := CheckURL(tc.url)
+		if (err != nil) != tc.wantErr || (err != nil && !errors.Is(err, ErrForbiddenURL)) {
+			t.Errorf("CheckURL(%q) with private networks %v = %v, want error %v", tc.url, tc.allowPrivate, err, tc.wantErr)
+		}
+	}
+}
+
+func TestTransportChecksResolvedAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	// localhost passes CheckURL, and only its resolved address is internal.
+	target := "http://localhost:" + u.Port() + "/hook"
+	client := &http.Client{Transport: NewTransport()}
+
+	allowPrivate(t, false)
+	if _, err := client.Get(target); !errors.Is(err, ErrForbiddenURL) {
+		t.Errorf("GET %s: %v, want %v", target, err, ErrForbiddenURL)
+	}
+
+	allowPrivate(t, true)
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s with private networks allowed: %v", target, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("GET %s: %s", target, resp.Status)
+	}
+}
@@ -0,0 +1,291 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+	"github.com/dapr/go-sdk/client"
+
+	"github.com/javier-aliaga/dapr-go-samples/api"
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// backend performs workflow operations, either through the HTTP API or the sidecar. IDs
+// are tenant-local in both cases.
+type backend interface {
+	Start(ctx context.Context, name, id string, input json.RawMessage) (string, error)
+	Get(ctx context.Context, id string) (api.WorkflowStatus, error)
+	List(ctx context.Context, pageSize uint32, token string) (api.InstancePage, error)
+	History(ctx context.Context, id string) ([]api.HistoryEvent, error)
+	Raise(ctx context.Context, id, event string, payload json.RawMessage) error
+	Terminate(ctx context.Context, id, reason string) error
+	Purge(ctx context.Context, id string) error
+	// Wait blocks until the instance finishes and returns its final status.
+	Wait(ctx context.Context, id string) (api.WorkflowStatus, error)
+}
+
+// finished reports whether a runtime status is final.
+func finished(runtimeStatus string) bool {
+	switch runtimeStatus {
+	case "COMPLETED", "FAILED", "TERMINATED", "CANCELED":
+		return true
+	}
+	return false
+}
+
+// httpBackend calls the HTTP API of a server.
+type httpBackend struct {
+	base   string
+	apiKey string
+	token  string
+	tenant string
+	client *http.Client
+	// poll is how often Wait fetches the status.
+	poll time.Duration
+}
+
+// do sends a request with a JSON body, when body is not nil, and decodes a JSON response
+// into out, when out is not nil.
+func (b *httpBackend) do(ctx context.Context, method, path string, body any, out any) error {
+	var r io.Reader
+	if body != nil {
+		raw, ok := body.(json.RawMessage)
+		if !ok {
+			var err error
+			if raw, err = json.Marshal(body); err != nil {
+				return err
+			}
+		}
+		r = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.apiKey != "" {
+		req.Header.Set("X-API-Key", b.apiKey)
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	if b.tenant != "" {
+		req.Header.Set(api.TenantHeader, b.tenant)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (b *httpBackend) Start(ctx context.Context, name, id string, input json.RawMessage) (string, error) {
+	var resp api.StartResponse
+	err := b.do(ctx, http.MethodPost, "/workflows", api.StartWorkflowRequest{
+		Workflow:   name,
+		InstanceID: id,
+		Input:      input,
+	}, &resp)
+	return resp.InstanceID, err
+}
+
+func (b *httpBackend) Get(ctx context.Context, id string) (api.WorkflowStatus, error) {
+	var st api.WorkflowStatus
+	err := b.do(ctx, http.MethodGet, "/workflows/"+url.PathEscape(id), nil, &st)
+	return st, err
+}
+
+func (b *httpBackend) List(ctx context.Context, pageSize uint32, token string) (api.InstancePage, error) {
+	q := url.Values{}
+	if pageSize > 0 {
+		q.Set("pageSize", fmt.Sprint(pageSize))
+	}
+	if token != "" {
+		q.Set("continuationToken", token)
+	}
+	path := "/workflows"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var page api.InstancePage
+	err := b.do(ctx, http.MethodGet, path, nil, &page)
+	return page, err
+}
+
+func (b *httpBackend) History(ctx context.Context, id string) ([]api.HistoryEvent, error) {
+	var events []api.HistoryEvent
+	err := b.do(ctx, http.MethodGet, "/workflows/"+url.PathEscape(id)+"/history", nil, &events)
+	return events, err
+}
+
+func (b *httpBackend) Raise(ctx context.Context, id, event string, payload json.RawMessage) error {
+	var body any
+	if len(payload) > 0 {
+		body = payload
+	}
+	return b.do(ctx, http.MethodPost, "/workflows/"+url.PathEscape(id)+"/events/"+url.PathEscape(event), body, nil)
+}
+
+func (b *httpBackend) Terminate(ctx context.Context, id, reason string) error {
+	return b.do(ctx, http.MethodPost, "/workflows/"+url.PathEscape(id)+"/terminate", api.TerminateRequest{Reason: reason}, nil)
+}
+
+func (b *httpBackend) Purge(ctx context.Context, id string) error {
+	return b.do(ctx, http.MethodPost, "/workflows/"+url.PathEscape(id)+"/purge", nil, nil)
+}
+
+// Wait polls the status rather than watching it, since watch streams are capped per server.
+func (b *httpBackend) Wait(ctx context.Context, id string) (api.WorkflowStatus, error) {
+	ticker := time.NewTicker(b.poll)
+	defer ticker.Stop()
+	for {
+		st, err := b.Get(ctx, id)
+		if err != nil || finished(st.RuntimeStatus) {
+			return st, err
+		}
+		select {
+		case <-ctx.Done():
+			return st, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// sidecarBackend talks to the Dapr sidecar directly, bypassing the API server and its
+// authentication. The sidecar address comes from DAPR_GRPC_ENDPOINT or DAPR_GRPC_PORT.
+type sidecarBackend struct {
+	client *workflow.Client
+	tenant string
+}
+
+func newSidecarBackend(t string) (*sidecarBackend, error) {
+	c, err := client.NewWorkflowClient()
+	if err != nil {
+		return nil, fmt.Errorf("connect to sidecar: %w", err)
+	}
+	return &sidecarBackend{client: c, tenant: t}, nil
+}
+
+func (b *sidecarBackend) instanceID(id string) string {
+	return tenant.InstanceID(b.tenant, id)
+}
+
+// notFound turns a missing instance into a readable error.
+func notFound(id string, err error) error {
+	if instances.IsNotFound(err) {
+		return fmt.Errorf("workflow %s not found", id)
+	}
+	return err
+}
+
+func (b *sidecarBackend) Start(ctx context.Context, name, id string, input json.RawMessage) (string, error) {
+	var opts []workflow.NewWorkflowOptions
+	if id != "" {
+		opts = append(opts, workflow.WithInstanceID(b.instanceID(id)))
+	}
+	if len(input) > 0 {
+		opts = append(opts, workflow.WithInput(input))
+	}
+	full, err := b.client.ScheduleWorkflow(ctx, name, opts...)
+	if err != nil {
+		return "", err
+	}
+	local, _ := tenant.LocalID(b.tenant, full)
+	return local, nil
+}
+
+func (b *sidecarBackend) Get(ctx context.Context, id string) (api.WorkflowStatus, error) {
+	meta, err := b.client.FetchWorkflowMetadata(ctx, b.instanceID(id), workflow.WithFetchPayloads(true))
+	if err != nil {
+		return api.WorkflowStatus{}, notFound(id, err)
+	}
+	st := api.NewWorkflowStatus(meta)
+	st.InstanceID = id
+	return st, nil
+}
+
+func (b *sidecarBackend) List(ctx context.Context, pageSize uint32, token string) (api.InstancePage, error) {
+	var opts []workflow.ListInstanceIDsOptions
+	if pageSize > 0 {
+		opts = append(opts, workflow.WithListInstanceIDsPageSize(pageSize))
+	}
+	if token != "" {
+		opts = append(opts, workflow.WithListInstanceIDsContinuationToken(token))
+	}
+	resp, err := b.client.ListInstanceIDs(ctx, opts...)
+	if err != nil {
+		return api.InstancePage{}, err
+	}
+
+	page := api.InstancePage{InstanceIDs: make([]string, 0, len(resp.InstanceIds))}
+	for _, full := range resp.InstanceIds {
+		if id, ok := tenant.LocalID(b.tenant, full); ok {
+			page.InstanceIDs = append(page.InstanceIDs, id)
+		}
+	}
+	if resp.ContinuationToken != nil {
+		page.ContinuationToken = *resp.ContinuationToken
+	}
+	return page, nil
+}
+
+func (b *sidecarBackend) History(ctx context.Context, id string) ([]api.HistoryEvent, error) {
+	resp, err := b.client.GetInstanceHistory(ctx, b.instanceID(id))
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+	return api.NewHistoryEvents(resp.Events), nil
+}
+
+func (b *sidecarBackend) Raise(ctx context.Context, id, event string, payload json.RawMessage) error {
+	var opts []workflow.RaiseEventOptions
+	if len(payload) > 0 {
+		opts = append(opts, workflow.WithEventPayload(payload))
+	}
+	return notFound(id, b.client.RaiseEvent(ctx, b.instanceID(id), event, opts...))
+}
+
+func (b *sidecarBackend) Terminate(ctx context.Context, id, reason string) error {
+	opts := []workflow.TerminateOptions{workflow.WithRecursiveTerminate(true)}
+	if reason != "" {
+		opts = append(opts, workflow.WithOutput(reason))
+	}
+	return notFound(id, b.client.TerminateWorkflow(ctx, b.instanceID(id), opts...))
+}
+
+func (b *sidecarBackend) Purge(ctx context.Context, id string) error {
+	return notFound(id, b.client.PurgeWorkflowState(ctx, b.instanceID(id), workflow.WithRecursivePurge(true)))
+}
+
+func (b *sidecarBackend) Wait(ctx context.Context, id string) (api.WorkflowStatus, error) {
+	meta, err := b.client.WaitForWorkflowCompletion(ctx, b.instanceID(id), workflow.WithFetchPayloads(true))
+	if err != nil {
+		return api.WorkflowStatus{}, notFound(id, err)
+	}
+	st := api.NewWorkflowStatus(meta)
+	st.InstanceID = id
+	return st, nil
+}
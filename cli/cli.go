@@ -0,0 +1,259 @@
+// Package cli implements the client subcommands of the binary: wf, to manage workflow
+// instances through the HTTP API or the Dapr sidecar, loadtest, to benchmark them, and
+// completion.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+// DefaultAddr is the HTTP API used unless --addr or WF_ADDR say otherwise.
+const DefaultAddr = "http://localhost:8080"
+
+// defaultTimeout bounds a wf command unless --timeout says otherwise.
+const defaultTimeout = 30 * time.Second
+
+// errUsage marks errors that should be followed by the command usage.
+var errUsage = errors.New("invalid arguments")
+
+// command is a wf subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// nargs is the number of positional arguments; -1 accepts any.
+	nargs int
+	// define registers the flags of the command and returns the function running it.
+	define func(fs *flag.FlagSet) func(ctx context.Context, c *conn, args []string) error
+}
+
+// conn is what a running wf command works with.
+type conn struct {
+	backend backend
+	out     io.Writer
+	stderr  io.Writer
+	json    bool
+}
+
+// print writes v as indented JSON, or calls table when the output is a table.
+func (c *conn) print(v any, table func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	table(c.out)
+	return nil
+}
+
+// Run runs the client command in args, such as wf get ID, and returns the exit code.
+// prog is the name of the binary, used in usage and completion scripts.
+func Run(ctx context.Context, prog string, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(prog, stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "wf":
+		return runWF(ctx, prog, args[1:], stdout, stderr)
+	case "loadtest":
+		return runLoadtest(ctx, prog, args[1:], stdout, stderr)
+	case "completion":
+		if len(args) != 2 {
+			fmt.Fprintf(stderr, "usage: %s completion bash|zsh|fish\n", prog)
+			return ExitUsage
+		}
+		if err := writeCompletion(stdout, prog, args[1]); err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitUsage
+		}
+		return ExitOK
+	case "help", "-h", "-help", "--help":
+		usage(prog, stdout)
+		return ExitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		usage(prog, stderr)
+		return ExitUsage
+	}
+}
+
+func usage(prog string, w io.Writer) {
+	fmt.Fprintf(w, "Usage:\n")
+	fmt.Fprintf(w, "  %s [server] [flags]        run the workflow app\n", prog)
+	fmt.Fprintf(w, "  %s wf <command> [flags]    manage workflow instances\n", prog)
+	fmt.Fprintf(w, "  %s loadtest [flags]        benchmark workflow throughput\n", prog)
+	fmt.Fprintf(w, "  %s completion bash|zsh|fish\n\n", prog)
+	wfUsage(prog, w)
+}
+
+func wfUsage(prog string, w io.Writer) {
+	fmt.Fprintf(w, "Workflow commands:\n")
+	for _, cmd := range wfCommands() {
+		fmt.Fprintf(w, "  %-36s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s wf <command> -h' for the flags of a command.\n", prog)
+}
+
+// commonFlags are accepted by every wf command.
+type commonFlags struct {
+	addr    string
+	sidecar bool
+	apiKey  string
+	token   string
+	tenant  string
+	output  string
+	timeout time.Duration
+	poll    time.Duration
+}
+
+// register defines the flags on fs; timeout is the default of --timeout, 0 for none.
+func (f *commonFlags) register(fs *flag.FlagSet, timeout time.Duration) {
+	fs.StringVar(&f.addr, "addr", envOr("WF_ADDR", DefaultAddr), "base URL of the HTTP API (env WF_ADDR)")
+	fs.BoolVar(&f.sidecar, "sidecar", false, "talk to the Dapr sidecar directly instead of the HTTP API")
+	fs.StringVar(&f.apiKey, "api-key", os.Getenv("WF_API_KEY"), "API key sent in X-API-Key (env WF_API_KEY)")
+	fs.StringVar(&f.token, "token", os.Getenv("WF_TOKEN"), "bearer token (env WF_TOKEN)")
+	fs.StringVar(&f.tenant, "tenant", os.Getenv("WF_TENANT"), "tenant to act for (env WF_TENANT)")
+	fs.StringVar(&f.output, "o", "table", "output format: table or json")
+	fs.StringVar(&f.output, "output", "table", "output format: table or json")
+	fs.DurationVar(&f.timeout, "timeout", timeout, "timeout of the whole command, 0 for none")
+	fs.DurationVar(&f.poll, "poll-interval", 250*time.Millisecond, "how often to fetch the status while waiting, over HTTP")
+}
+
+// backend returns the backend selected by the flags.
+func (f *commonFlags) backend() (backend, error) {
+	if f.sidecar {
+		return newSidecarBackend(f.tenant)
+	}
+	if f.poll <= 0 {
+		return nil, fmt.Errorf("%w: --poll-interval must be positive", errUsage)
+	}
+	return &httpBackend{
+		base:   strings.TrimRight(f.addr, "/"),
+		apiKey: f.apiKey,
+		token:  f.token,
+		tenant: f.tenant,
+		client: &http.Client{},
+		poll:   f.poll,
+	}, nil
+}
+
+// withTimeout bounds ctx by the --timeout flag, when set.
+func (f *commonFlags) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, f.timeout)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range wfCommands() {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func runWF(ctx context.Context, prog string, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		wfUsage(prog, stderr)
+		return ExitUsage
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown wf command %q\n\n", args[0])
+		wfUsage(prog, stderr)
+		return ExitUsage
+	}
+
+	fs := flag.NewFlagSet(prog+" wf "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var common commonFlags
+	common.register(fs, defaultTimeout)
+	run := cmd.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s wf %s [flags]\n\n%s.\n\nFlags:\n", prog, strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	pos, err := parseInterspersed(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		return ExitUsage
+	}
+	if cmd.nargs >= 0 && len(pos) != cmd.nargs {
+		fmt.Fprintf(stderr, "%s wf %s takes %d argument(s), got %d\n\n", prog, cmd.name, cmd.nargs, len(pos))
+		fs.Usage()
+		return ExitUsage
+	}
+	if common.output != "table" && common.output != "json" {
+		fmt.Fprintf(stderr, "unknown output format %q, want table or json\n", common.output)
+		return ExitUsage
+	}
+
+	b, err := common.backend()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		if errors.Is(err, errUsage) {
+			return ExitUsage
+		}
+		return ExitError
+	}
+
+	ctx, cancel := common.withTimeout(ctx)
+	defer cancel()
+	c := &conn{backend: b, out: stdout, stderr: stderr, json: common.output == "json"}
+	if err := run(ctx, c, pos); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return ExitUsage
+		}
+		return ExitError
+	}
+	return ExitOK
+}
+
+// parseInterspersed parses flags placed before, between or after the positional
+// arguments, which the flag package alone stops at, and returns the positional ones.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/javier-aliaga/dapr-go-samples/api"
+	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		pos  []string
+		data string
+	}{
+		{args: []string{"order-1", "approval"}, pos: []string{"order-1", "approval"}},
+		{args: []string{"--data", "true", "order-1", "approval"}, pos: []string{"order-1", "approval"}, data: "true"},
+		{args: []string{"order-1", "--data", "true", "approval"}, pos: []string{"order-1", "approval"}, data: "true"},
+		{args: []string{"order-1", "approval", "-data=true"}, pos: []string{"order-1", "approval"}, data: "true"},
+		// Everything after -- is positional.
+		{args: []string{"order-1", "--", "--data"}, pos: []string{"order-1", "--data"}},
+	} {
+		fs := flag.NewFlagSet("raise", flag.ContinueOnError)
+		data := fs.String("data", "", "")
+		pos, err := parseInterspersed(fs, tc.args)
+		if err != nil || !reflect.DeepEqual(pos, tc.pos) || *data != tc.data {
+			t.Errorf("%q: %q with --data %q, %v; want %q with %q", tc.args, pos, *data, err, tc.pos, tc.data)
+		}
+	}
+
+	fs := flag.NewFlagSet("raise", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := parseInterspersed(fs, []string{"order-1", "--unknown"}); err == nil {
+		t.Error("an unknown flag after an argument was accepted")
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		code int
+		// stderr is a substring of the expected error output.
+		stderr string
+	}{
+		{args: nil, code: ExitUsage, stderr: "Usage:"},
+		{args: []string{"help"}, code: ExitOK},
+		{args: []string{"deploy"}, code: ExitUsage, stderr: `unknown command "deploy"`},
+		{args: []string{"wf"}, code: ExitUsage, stderr: "Workflow commands:"},
+		{args: []string{"wf", "restart"}, code: ExitUsage, stderr: `unknown wf command "restart"`},
+		{args: []string{"wf", "get", "-h"}, code: ExitOK, stderr: "Usage: wfctl wf get <id>"},
+		{args: []string{"wf", "get"}, code: ExitUsage, stderr: "takes 1 argument(s), got 0"},
+		{args: []string{"wf", "raise", "order-1"}, code: ExitUsage, stderr: "takes 2 argument(s), got 1"},
+		{args: []string{"wf", "list", "extra"}, code: ExitUsage, stderr: "takes 0 argument(s), got 1"},
+		{args: []string{"wf", "get", "order-1", "--bogus"}, code: ExitUsage, stderr: "flag provided but not defined: -bogus"},
+		{args: []string{"wf", "get", "order-1", "-o", "yaml"}, code: ExitUsage, stderr: `unknown output format "yaml"`},
+		{args: []string{"wf", "get", "order-1", "--poll-interval", "0"}, code: ExitUsage, stderr: "--poll-interval must be positive"},
+		// Invalid input is caught before anything is sent.
+		{args: []string{"wf", "start", "SimpleWorkflow", "--input", "{"}, code: ExitUsage, stderr: "input is not valid JSON"},
+		{args: []string{"wf", "start", "SimpleWorkflow", "--input", "{}", "--input-file", "in.json"}, code: ExitUsage, stderr: "not both"},
+		{args: []string{"completion"}, code: ExitUsage, stderr: "usage: wfctl completion"},
+		{args: []string{"completion", "powershell"}, code: ExitUsage},
+		{args: []string{"loadtest", "extra"}, code: ExitUsage, stderr: "takes no arguments"},
+		{args: []string{"loadtest", "--concurrency", "0"}, code: ExitUsage, stderr: "--concurrency must be at least 1"},
+		{args: []string{"loadtest", "--count", "0", "--duration", "0"}, code: ExitUsage, stderr: "set --count, --duration or both"},
+	} {
+		var stdout, stderr bytes.Buffer
+		code := Run(context.Background(), "wfctl", tc.args, &stdout, &stderr)
+		if code != tc.code || !strings.Contains(stderr.String(), tc.stderr) {
+			t.Errorf("%q: exit %d with %q, want %d with %q", tc.args, code, stderr.String(), tc.code, tc.stderr)
+		}
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "input.json")
+	if err := os.WriteFile(file, []byte(`{"n":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		inline, file string
+		want         string
+		usage        bool
+	}{
+		{},
+		{inline: `{"n":2}`, want: `{"n":2}`},
+		{file: file, want: `{"n":1}`},
+		{inline: "not json", usage: true},
+		{inline: `{}`, file: file, usage: true},
+	} {
+		got, err := readInput(tc.inline, tc.file)
+		if errors.Is(err, errUsage) != tc.usage || (err == nil && string(got) != tc.want) {
+			t.Errorf("inline %q, file %q: %s, %v", tc.inline, tc.file, got, err)
+		}
+	}
+	if _, err := readInput("", filepath.Join(t.TempDir(), "missing.json")); err == nil || errors.Is(err, errUsage) {
+		t.Errorf("missing file: %v, want a read error", err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	if err := notFound("order-1", status.Error(codes.NotFound, "no such instance")); err == nil || err.Error() != "workflow order-1 not found" {
+		t.Errorf("not found: %v", err)
+	}
+	other := errors.New("sidecar unavailable")
+	if err := notFound("order-1", other); err != other {
+		t.Errorf("other error: %v, want it unchanged", err)
+	}
+	if err := notFound("order-1", nil); err != nil {
+		t.Errorf("no error: %v", err)
+	}
+}
+
+func TestRunWF(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, api.NewService(dapr.NewWorkflowRuntime(sidecar.Client(), "SimpleWorkflow")))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := Run(context.Background(), "wfctl", append([]string{"wf"}, args...), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	// Flags go before, between or after the arguments.
+	if code, out, errOut := run("start", "--addr", srv.URL, "SimpleWorkflow", "--id", "order-1", "--tenant", "acme", `--input={"n":1}`); code != ExitOK || out != "order-1\n" {
+		t.Fatalf("start: exit %d, %q %q", code, out, errOut)
+	}
+	if in, ok := sidecar.Instance("acme~order-1"); !ok || in.Input != `{"n":1}` {
+		t.Fatalf("started %+v, want the input scoped to acme", in)
+	}
+
+	code, out, errOut := run("get", "order-1", "--addr", srv.URL, "--tenant", "acme", "-o", "json")
+	if code != ExitOK || !strings.Contains(out, `"instanceId": "order-1"`) || !strings.Contains(out, `"runtimeStatus": "RUNNING"`) {
+		t.Errorf("get: exit %d, %q %q", code, out, errOut)
+	}
+
+	if code, out, errOut := run("raise", "--addr", srv.URL, "--tenant", "acme", "order-1", "approval", "--data", "true"); code != ExitOK || out != "workflow order-1 event approval raised\n" {
+		t.Errorf("raise: exit %d, %q %q", code, out, errOut)
+	}
+	if code, _, errOut := run("terminate", "order-1", "--addr", srv.URL, "--tenant", "acme", "--reason", "cancelled"); code != ExitOK {
+		t.Errorf("terminate: exit %d, %q", code, errOut)
+	}
+	if in, _ := sidecar.Instance("acme~order-1"); in.Status != protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED {
+		t.Errorf("instance is %s after terminate", in.Status)
+	}
+
+	// Errors from the API fail the command without the usage.
+	code, _, errOut = run("get", "missing", "--addr", srv.URL)
+	if code != ExitError || !strings.HasPrefix(errOut, "error:") || strings.Contains(errOut, "Usage:") {
+		t.Errorf("get a missing instance: exit %d, %q", code, errOut)
+	}
+	if code, _, errOut := run("get", "order-1", "--addr", srv.URL, "--tenant", "globex"); code != ExitError {
+		t.Errorf("get as another tenant: exit %d, %q", code, errOut)
+	}
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/api"
+)
+
+// listDetailWorkers bounds the status lookups of wf list -l.
+const listDetailWorkers = 8
+
+// wfCommands returns the wf subcommands in the order they are listed in the usage.
+func wfCommands() []*command {
+	return []*command{
+		{
+			name: "start", args: "<workflow>", summary: "Start a workflow instance", nargs: 1,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				id := fs.String("id", "", "instance ID; generated when empty")
+				input := fs.String("input", "", "JSON input")
+				inputFile := fs.String("input-file", "", "file holding the JSON input, - for stdin")
+				return func(ctx context.Context, c *conn, args []string) error {
+					in, err := readInput(*input, *inputFile)
+					if err != nil {
+						return err
+					}
+					instanceID, err := c.backend.Start(ctx, args[0], *id, in)
+					if err != nil {
+						return err
+					}
+					return c.print(api.StartResponse{InstanceID: instanceID}, func(w io.Writer) {
+						fmt.Fprintln(w, instanceID)
+					})
+				}
+			},
+		},
+		{
+			name: "get", args: "<id>", summary: "Show the status of an instance", nargs: 1,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				return func(ctx context.Context, c *conn, args []string) error {
+					st, err := c.backend.Get(ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return c.print(st, func(w io.Writer) { statusTable(w, st) })
+				}
+			},
+		},
+		{
+			name: "list", summary: "List instance IDs", nargs: 0,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				pageSize := fs.Uint("page-size", 100, "instances per page")
+				token := fs.String("continue", "", "continuation token of the previous page")
+				all := fs.Bool("all", false, "fetch every page")
+				long := fs.Bool("l", false, "also show the workflow, status and times of every instance")
+				return func(ctx context.Context, c *conn, _ []string) error {
+					page, err := listPages(ctx, c.backend, uint32(*pageSize), *token, *all)
+					if err != nil {
+						return err
+					}
+					if !*long {
+						return c.print(page, func(w io.Writer) {
+							tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+							fmt.Fprintln(tw, "INSTANCE ID")
+							for _, id := range page.InstanceIDs {
+								fmt.Fprintln(tw, id)
+							}
+							tw.Flush()
+							c.moreHint(page)
+						})
+					}
+
+					statuses := fetchStatuses(ctx, c.backend, page.InstanceIDs)
+					return c.print(struct {
+						Instances         []api.WorkflowStatus `json:"instances"`
+						ContinuationToken string               `json:"continuationToken,omitempty"`
+					}{statuses, page.ContinuationToken}, func(w io.Writer) {
+						tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+						fmt.Fprintln(tw, "INSTANCE ID\tWORKFLOW\tSTATUS\tCREATED\tUPDATED")
+						for _, st := range statuses {
+							fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", st.InstanceID, st.Name, st.RuntimeStatus,
+								formatTime(st.CreatedAt), formatTime(st.LastUpdatedAt))
+						}
+						tw.Flush()
+						c.moreHint(page)
+					})
+				}
+			},
+		},
+		{
+			name: "history", args: "<id>", summary: "Show the history events of an instance", nargs: 1,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				return func(ctx context.Context, c *conn, args []string) error {
+					events, err := c.backend.History(ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return c.print(events, func(w io.Writer) {
+						tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+						fmt.Fprintln(tw, "ID\tTIME\tTYPE\tNAME")
+						for _, e := range events {
+							fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", e.EventID, e.Timestamp.Local().Format(time.RFC3339), e.Type, e.Name)
+						}
+						tw.Flush()
+					})
+				}
+			},
+		},
+		{
+			name: "raise", args: "<id> <event>", summary: "Raise an event on an instance", nargs: 2,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				data := fs.String("data", "", "JSON event payload")
+				dataFile := fs.String("data-file", "", "file holding the JSON event payload, - for stdin")
+				return func(ctx context.Context, c *conn, args []string) error {
+					payload, err := readInput(*data, *dataFile)
+					if err != nil {
+						return err
+					}
+					if err := c.backend.Raise(ctx, args[0], args[1], payload); err != nil {
+						return err
+					}
+					return c.printResult(args[0], "event "+args[1]+" raised")
+				}
+			},
+		},
+		{
+			name: "terminate", args: "<id>", summary: "Terminate an instance and its children", nargs: 1,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				reason := fs.String("reason", "", "reason, recorded as the output of the instance")
+				return func(ctx context.Context, c *conn, args []string) error {
+					if err := c.backend.Terminate(ctx, args[0], *reason); err != nil {
+						return err
+					}
+					return c.printResult(args[0], "terminated")
+				}
+			},
+		},
+		{
+			name: "purge", args: "<id>", summary: "Delete the state of a finished instance and its children", nargs: 1,
+			define: func(fs *flag.FlagSet) func(context.Context, *conn, []string) error {
+				return func(ctx context.Context, c *conn, args []string) error {
+					if err := c.backend.Purge(ctx, args[0]); err != nil {
+						return err
+					}
+					return c.printResult(args[0], "purged")
+				}
+			},
+		},
+	}
+}
+
+// printResult reports the outcome of a command that returns nothing.
+func (c *conn) printResult(id, result string) error {
+	return c.print(map[string]string{"instanceId": id, "result": result}, func(w io.Writer) {
+		fmt.Fprintf(w, "workflow %s %s\n", id, result)
+	})
+}
+
+// readInput returns the JSON given inline or in a file, or nil when neither is set.
+func readInput(inline, file string) (json.RawMessage, error) {
+	var b []byte
+	switch {
+	case inline != "" && file != "":
+		return nil, fmt.Errorf("%w: give the JSON inline or in a file, not both", errUsage)
+	case inline != "":
+		b = []byte(inline)
+	case file == "-":
+		var err error
+		if b, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, err
+		}
+	case file != "":
+		var err error
+		if b, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+	if !json.Valid(b) {
+		return nil, fmt.Errorf("%w: input is not valid JSON", errUsage)
+	}
+	return b, nil
+}
+
+// listPages returns one page, or every page from token on when all is set.
+func listPages(ctx context.Context, b backend, pageSize uint32, token string, all bool) (api.InstancePage, error) {
+	var out api.InstancePage
+	for {
+		page, err := b.List(ctx, pageSize, token)
+		if err != nil {
+			return api.InstancePage{}, err
+		}
+		out.InstanceIDs = append(out.InstanceIDs, page.InstanceIDs...)
+		out.ContinuationToken = page.ContinuationToken
+		if !all || page.ContinuationToken == "" {
+			return out, nil
+		}
+		token = page.ContinuationToken
+	}
+}
+
+// fetchStatuses returns the status of every instance, in order. Instances that cannot be
+// fetched, for instance because they were purged meanwhile, only carry their ID.
+func fetchStatuses(ctx context.Context, b backend, ids []string) []api.WorkflowStatus {
+	out := make([]api.WorkflowStatus, len(ids))
+	sem := make(chan struct{}, listDetailWorkers)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			st, err := b.Get(ctx, id)
+			if err != nil {
+				st = api.WorkflowStatus{InstanceID: id, RuntimeStatus: "?"}
+			}
+			out[i] = st
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// moreHint tells how to get the next page, on stderr so table output stays parseable.
+func (c *conn) moreHint(page api.InstancePage) {
+	if page.ContinuationToken != "" {
+		fmt.Fprintf(c.stderr, "more instances: --continue %s, or --all\n", page.ContinuationToken)
+	}
+}
+
+func statusTable(w io.Writer, st api.WorkflowStatus) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	rows := [][2]string{
+		{"INSTANCE ID", st.InstanceID},
+		{"WORKFLOW", st.Name},
+		{"STATUS", st.RuntimeStatus},
+		{"CREATED", formatTime(st.CreatedAt)},
+		{"UPDATED", formatTime(st.LastUpdatedAt)},
+		{"CUSTOM STATUS", string(st.CustomStatus)},
+		{"INPUT", st.Input},
+		{"OUTPUT", st.Output},
+		{"FAILURE", st.Failure},
+	}
+	for _, r := range rows {
+		if r[1] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", r[0], r[1])
+		}
+	}
+	tw.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
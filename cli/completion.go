@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// topCommands are the first-level commands of the binary.
+var topCommands = []string{"server", "wf", "loadtest", "completion", "help"}
+
+// serverFlags are the flags of the server command, defined in package main.
+var serverFlags = []string{"--config", "--role"}
+
+// wfFlags returns the flags of every wf command keyed by command name.
+func wfFlags() map[string][]string {
+	out := make(map[string][]string)
+	for _, cmd := range wfCommands() {
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		var common commonFlags
+		common.register(fs, defaultTimeout)
+		cmd.define(fs)
+		out[cmd.name] = flagNames(fs)
+	}
+	return out
+}
+
+func loadtestFlagNames() []string {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	var common commonFlags
+	common.register(fs, 0)
+	var f loadtestFlags
+	f.register(fs)
+	return flagNames(fs)
+}
+
+// flagNames returns the flags defined on fs as -x or --name, sorted.
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			names = append(names, "-"+f.Name)
+		} else {
+			names = append(names, "--"+f.Name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+func wfNames() []string {
+	var names []string
+	for _, cmd := range wfCommands() {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// writeCompletion writes the completion script for shell.
+func writeCompletion(w io.Writer, prog, shell string) error {
+	prog = filepath.Base(prog)
+	switch shell {
+	case "bash":
+		writeBash(w, prog)
+	case "zsh":
+		// zsh runs the bash script through bashcompinit.
+		fmt.Fprintf(w, "autoload -U +X bashcompinit && bashcompinit\n")
+		writeBash(w, prog)
+	case "fish":
+		writeFish(w, prog)
+	default:
+		return fmt.Errorf("unknown shell %q, want bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+func writeBash(w io.Writer, prog string) {
+	fn := "_" + nonIdent.ReplaceAllString(prog, "_") + "_complete"
+	flags := wfFlags()
+
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "  local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "  if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(topCommands, " "))
+	fmt.Fprintf(w, "  fi\n")
+	fmt.Fprintf(w, "  case \"${COMP_WORDS[1]}\" in\n")
+	fmt.Fprintf(w, "  server)\n")
+	fmt.Fprintf(w, "    case \"$prev\" in --role|-role) COMPREPLY=($(compgen -W \"all orchestrator worker\" -- \"$cur\")); return;; esac\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\"));;\n", strings.Join(serverFlags, " "))
+	fmt.Fprintf(w, "  loadtest)\n")
+	fmt.Fprintf(w, "    case \"$prev\" in -o|--o|-output|--output) COMPREPLY=($(compgen -W \"table json\" -- \"$cur\")); return;; esac\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\"));;\n", strings.Join(loadtestFlagNames(), " "))
+	fmt.Fprintf(w, "  completion)\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W \"bash zsh fish\" -- \"$cur\"));;\n")
+	fmt.Fprintf(w, "  wf)\n")
+	fmt.Fprintf(w, "    if [[ $COMP_CWORD -eq 2 ]]; then\n")
+	fmt.Fprintf(w, "      COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(wfNames(), " "))
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    case \"$prev\" in -o|--o|-output|--output) COMPREPLY=($(compgen -W \"table json\" -- \"$cur\")); return;; esac\n")
+	fmt.Fprintf(w, "    [[ \"$cur\" == -* ]] || return\n")
+	fmt.Fprintf(w, "    case \"${COMP_WORDS[2]}\" in\n")
+	for _, name := range wfNames() {
+		fmt.Fprintf(w, "    %s) COMPREPLY=($(compgen -W %q -- \"$cur\"));;\n", name, strings.Join(flags[name], " "))
+	}
+	fmt.Fprintf(w, "    esac;;\n")
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", fn, prog)
+}
+
+func writeFish(w io.Writer, prog string) {
+	flags := wfFlags()
+	top := strings.Join(topCommands, " ")
+
+	fmt.Fprintf(w, "complete -c %s -f\n", prog)
+	fmt.Fprintf(w, "complete -c %s -n 'not __fish_seen_subcommand_from %s' -a '%s'\n", prog, top, top)
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'\n", prog)
+	for _, f := range serverFlags {
+		fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from server' -l %s\n", prog, strings.TrimPrefix(f, "--"))
+	}
+	for _, f := range loadtestFlagNames() {
+		if long, ok := strings.CutPrefix(f, "--"); ok && long != "output" {
+			fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from loadtest' -l %s\n", prog, long)
+		}
+	}
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from loadtest' -l output -s o -x -a 'table json'\n", prog)
+	names := strings.Join(wfNames(), " ")
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from wf; and not __fish_seen_subcommand_from %s' -a '%s'\n",
+		prog, names, names)
+	for _, name := range wfNames() {
+		for _, f := range flags[name] {
+			if f == "-o" || f == "--output" {
+				continue
+			}
+			if long, ok := strings.CutPrefix(f, "--"); ok {
+				fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -l %s\n", prog, name, long)
+			} else {
+				fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -s %s\n", prog, name, f[1:])
+			}
+		}
+	}
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from wf' -l output -s o -x -a 'table json'\n", prog)
+}
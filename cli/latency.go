@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencies records durations and summarizes them. Samples are kept exactly, so percentiles
+// are exact rather than bucketed.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func (l *latencies) record(d time.Duration) {
+	l.mu.Lock()
+	l.samples = append(l.samples, d)
+	l.mu.Unlock()
+}
+
+// sorted returns a sorted copy of the samples.
+func (l *latencies) sorted() []time.Duration {
+	l.mu.Lock()
+	out := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// latencySummary describes a latency distribution, in milliseconds.
+type latencySummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"minMs"`
+	Mean  float64 `json:"meanMs"`
+	P50   float64 `json:"p50Ms"`
+	P90   float64 `json:"p90Ms"`
+	P95   float64 `json:"p95Ms"`
+	P99   float64 `json:"p99Ms"`
+	P999  float64 `json:"p999Ms"`
+	Max   float64 `json:"maxMs"`
+}
+
+func (l *latencies) summary() latencySummary {
+	s := l.sorted()
+	if len(s) == 0 {
+		return latencySummary{}
+	}
+	mean, _ := meanStdDev(s)
+	return latencySummary{
+		Count: len(s),
+		Min:   ms(s[0]),
+		Mean:  mean,
+		P50:   ms(percentile(s, 50)),
+		P90:   ms(percentile(s, 90)),
+		P95:   ms(percentile(s, 95)),
+		P99:   ms(percentile(s, 99)),
+		P999:  ms(percentile(s, 99.9)),
+		Max:   ms(s[len(s)-1]),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile returns the smallest sample that at least p percent of the sorted samples
+// are less than or equal to.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	n := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if n < 1 {
+		n = 1
+	}
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[n-1]
+}
+
+// meanStdDev returns the mean and standard deviation of the samples, in milliseconds.
+func meanStdDev(s []time.Duration) (float64, float64) {
+	var sum float64
+	for _, d := range s {
+		sum += ms(d)
+	}
+	mean := sum / float64(len(s))
+	var sq float64
+	for _, d := range s {
+		sq += (ms(d) - mean) * (ms(d) - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(s)))
+}
+
+// hdrTicksPerHalfDistance is the resolution of the percentile distribution, as in HdrHistogram.
+const hdrTicksPerHalfDistance = 5
+
+// writeHgrm writes the samples in the percentile distribution format of HdrHistogram
+// (.hgrm), in milliseconds, which HdrHistogram plotters read.
+func (l *latencies) writeHgrm(w io.Writer) error {
+	s := l.sorted()
+	if _, err := fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"); err != nil {
+		return err
+	}
+	if len(s) == 0 {
+		return nil
+	}
+
+	// Like HdrHistogram, report ever finer percentile steps towards the tail: the step
+	// halves every time the remaining distance to 100% does.
+	for p := 0.0; p < 100; {
+		v := percentile(s, p)
+		total := sort.Search(len(s), func(i int) bool { return s[i] > v })
+		if total == len(s) {
+			break
+		}
+		fmt.Fprintf(w, "%12.3f %2.12f %10d %14.2f\n", ms(v), p/100, total, 1/(1-p/100))
+		ticks := hdrTicksPerHalfDistance * math.Pow(2, math.Floor(math.Log2(100/(100-p)))+1)
+		p += 100 / ticks
+	}
+	fmt.Fprintf(w, "%12.3f %2.12f %10d\n", ms(s[len(s)-1]), 1.0, len(s))
+
+	mean, stdDev := meanStdDev(s)
+	fmt.Fprintf(w, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean, stdDev)
+	_, err := fmt.Fprintf(w, "#[Max     = %12.3f, Total count    = %12d]\n", ms(s[len(s)-1]), len(s))
+	return err
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var s []time.Duration
+	for i := 1; i <= 10; i++ {
+		s = append(s, time.Duration(i)*time.Millisecond)
+	}
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Millisecond},
+		{p: 10, want: time.Millisecond},
+		{p: 11, want: 2 * time.Millisecond},
+		{p: 50, want: 5 * time.Millisecond},
+		{p: 90, want: 9 * time.Millisecond},
+		{p: 90.1, want: 10 * time.Millisecond},
+		{p: 99.9, want: 10 * time.Millisecond},
+		{p: 100, want: 10 * time.Millisecond},
+	} {
+		if got := percentile(s, tc.p); got != tc.want {
+			t.Errorf("p%v: %s, want %s", tc.p, got, tc.want)
+		}
+	}
+	if got := percentile([]time.Duration{time.Second}, 99); got != time.Second {
+		t.Errorf("p99 of one sample: %s", got)
+	}
+}
+
+func TestLatencySummary(t *testing.T) {
+	var l latencies
+	if s := l.summary(); s != (latencySummary{}) {
+		t.Errorf("no samples: %+v", s)
+	}
+	// Samples are sorted before they are summarized.
+	for _, d := range []time.Duration{4, 1, 3, 2} {
+		l.record(d * time.Millisecond)
+	}
+	want := latencySummary{Count: 4, Min: 1, Mean: 2.5, P50: 2, P90: 4, P95: 4, P99: 4, P999: 4, Max: 4}
+	if s := l.summary(); s != want {
+		t.Errorf("summary %+v, want %+v", s, want)
+	}
+}
+
+func TestWriteHgrm(t *testing.T) {
+	var l latencies
+	for _, d := range []time.Duration{3, 1, 4, 2} {
+		l.record(d * time.Millisecond)
+	}
+	var b bytes.Buffer
+	if err := l.writeHgrm(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `       Value     Percentile TotalCount 1/(1-Percentile)
+
+       1.000 0.000000000000          1           1.00
+       1.000 0.100000000000          1           1.11
+       1.000 0.200000000000          1           1.25
+       2.000 0.300000000000          2           1.43
+       2.000 0.400000000000          2           1.67
+       2.000 0.500000000000          2           2.00
+       3.000 0.550000000000          3           2.22
+       3.000 0.600000000000          3           2.50
+       3.000 0.650000000000          3           2.86
+       3.000 0.700000000000          3           3.33
+       3.000 0.750000000000          3           4.00
+       4.000 1.000000000000          4
+#[Mean    =        2.500, StdDeviation   =        1.118]
+#[Max     =        4.000, Total count    =            4]
+`
+	if b.String() != want {
+		t.Errorf("hgrm:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	// The steps get finer towards the tail, and the percentiles only grow.
+	l = latencies{}
+	for i := 1; i <= 10000; i++ {
+		l.record(time.Duration(i) * time.Microsecond)
+	}
+	b.Reset()
+	if err := l.writeHgrm(&b); err != nil {
+		t.Fatal(err)
+	}
+	var value, pct, steps []float64
+	for _, row := range strings.Split(b.String(), "\n")[2:] {
+		var v, p float64
+		var total int
+		if n, _ := fmt.Sscanf(row, "%f %f %d", &v, &p, &total); n != 3 {
+			break
+		}
+		value, pct = append(value, v), append(pct, p)
+	}
+	if len(pct) < 3 {
+		t.Fatalf("%d rows:\n%s", len(pct), b.String())
+	}
+	for i := 1; i < len(pct); i++ {
+		if value[i] < value[i-1] || pct[i] <= pct[i-1] {
+			t.Fatalf("row %d: %v at %v after %v at %v", i, value[i], pct[i], value[i-1], pct[i-1])
+		}
+		steps = append(steps, pct[i]-pct[i-1])
+	}
+	if n := len(pct); value[n-1] != 10 || pct[n-1] != 1 || steps[len(steps)-2] >= steps[0] {
+		t.Errorf("%d rows ending at %v, want finer steps towards the maximum at percentile 1", n, value[n-1])
+	}
+
+	var empty bytes.Buffer
+	if err := (&latencies{}).writeHgrm(&empty); err != nil || strings.Count(empty.String(), "\n") != 2 {
+		t.Errorf("no samples: %q, %v, want the header alone", empty.String(), err)
+	}
+}
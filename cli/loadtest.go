@@ -0,0 +1,511 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxSampleErrors bounds the distinct error messages kept in a load test report.
+const maxSampleErrors = 10
+
+// loadtestFlags are the flags of the loadtest command.
+type loadtestFlags struct {
+	workflow    string
+	input       string
+	inputFile   string
+	rate        float64
+	concurrency int
+	count       int
+	duration    time.Duration
+	event       string
+	eventData   string
+	eventDelay  time.Duration
+	waitTimeout time.Duration
+	idPrefix    string
+	purge       bool
+	report      string
+	hdr         string
+	progress    time.Duration
+}
+
+func (f *loadtestFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.workflow, "workflow", "SimpleWorkflow", "workflow to start")
+	fs.StringVar(&f.input, "input", "", "JSON input of every instance")
+	fs.StringVar(&f.inputFile, "input-file", "", "file holding the JSON input, - for stdin")
+	fs.Float64Var(&f.rate, "rate", 0, "starts per second, 0 to start as fast as --concurrency allows")
+	fs.IntVar(&f.concurrency, "concurrency", 10, "instances in flight at most, from start to completion")
+	fs.IntVar(&f.count, "count", 0, "instances to start, 0 for no limit")
+	fs.DurationVar(&f.duration, "duration", 30*time.Second, "how long to keep starting instances, 0 for no limit")
+	fs.StringVar(&f.event, "event", "event", "event raised on every instance, empty to raise none")
+	fs.StringVar(&f.eventData, "event-data", "", "JSON payload of the event")
+	fs.DurationVar(&f.eventDelay, "event-delay", 0, "delay between the start of an instance and its event")
+	fs.DurationVar(&f.waitTimeout, "wait-timeout", 5*time.Minute, "how long to wait for an instance to finish")
+	fs.StringVar(&f.idPrefix, "id-prefix", "", "instance ID prefix; loadtest-<run>- when empty")
+	fs.BoolVar(&f.purge, "purge", false, "purge every instance once it finished")
+	fs.StringVar(&f.report, "report", "", "file to write the JSON report to")
+	fs.StringVar(&f.hdr, "hdr", "", "write <prefix>-schedule.hgrm, -event.hgrm and -completion.hgrm HdrHistogram files")
+	fs.DurationVar(&f.progress, "progress", 5*time.Second, "how often to print progress on stderr, 0 for never")
+}
+
+// loadtestReport is the outcome of a load test.
+type loadtestReport struct {
+	Workflow    string    `json:"workflow"`
+	IDPrefix    string    `json:"idPrefix"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	Rate        float64   `json:"rate,omitempty"`
+	Concurrency int       `json:"concurrency"`
+	// Interrupted is set when the test was stopped before all instances finished.
+	Interrupted bool `json:"interrupted,omitempty"`
+
+	// Attempted counts the starts tried, Scheduled the ones that succeeded and Completed
+	// the instances that finished as COMPLETED.
+	Attempted int `json:"attempted"`
+	Scheduled int `json:"scheduled"`
+	Completed int `json:"completed"`
+	// Failed counts the attempted instances that did not complete, other than the ones
+	// canceled by an interrupt or --timeout.
+	Failed    int     `json:"failed"`
+	Canceled  int     `json:"canceled,omitempty"`
+	ErrorRate float64 `json:"errorRate"`
+	// Errors counts failures by phase: schedule, event, wait, timeout and purge.
+	Errors map[string]int `json:"errors"`
+	// Statuses counts the final runtime statuses.
+	Statuses     map[string]int `json:"statuses"`
+	SampleErrors []string       `json:"sampleErrors,omitempty"`
+
+	// StartRate is the achieved starts per second up to the last one, CompletionRate the
+	// completions per second over the whole test.
+	StartRate      float64 `json:"startRate"`
+	CompletionRate float64 `json:"completionRate"`
+
+	// Latency holds the schedule, event and completion latencies. Completion is measured
+	// from the start request; over HTTP it is accurate to --poll-interval.
+	Latency map[string]latencySummary `json:"latency"`
+}
+
+// loadtest is a running load test.
+type loadtest struct {
+	flags     loadtestFlags
+	b         backend
+	input     json.RawMessage
+	eventData json.RawMessage
+
+	schedule   latencies
+	event      latencies
+	completion latencies
+
+	attempted, scheduled, completed, inFlight atomic.Int64
+
+	mu       sync.Mutex
+	failed   int
+	canceled int
+	// lastScheduled is when the last start succeeded, for the start rate.
+	lastScheduled time.Time
+	errors        map[string]int
+	statuses      map[string]int
+	samples       []string
+}
+
+func runLoadtest(ctx context.Context, prog string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(prog+" loadtest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var common commonFlags
+	common.register(fs, 0)
+	var f loadtestFlags
+	f.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s loadtest [flags]\n\n", prog)
+		fmt.Fprintf(stderr, "Start workflow instances at a target rate or concurrency, raise their event and\n")
+		fmt.Fprintf(stderr, "wait for them to finish, then report latencies and errors.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "%s loadtest takes no arguments\n\n", prog)
+		fs.Usage()
+		return ExitUsage
+	}
+
+	lt := &loadtest{flags: f, errors: make(map[string]int), statuses: make(map[string]int)}
+	b, err := common.backend()
+	if err == nil {
+		lt.b = b
+		err = lt.validate(common.output)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return ExitUsage
+		}
+		return ExitError
+	}
+
+	ctx, cancel := common.withTimeout(ctx)
+	defer cancel()
+	// An interrupt stops the test early, and still reports what was measured.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	report := lt.run(ctx, stderr)
+
+	if err := lt.write(report); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+	c := &conn{out: stdout, stderr: stderr, json: common.output == "json"}
+	if err := c.print(report, func(w io.Writer) { reportTable(w, report) }); err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return ExitError
+	}
+	if report.Failed > 0 || report.Interrupted {
+		return ExitError
+	}
+	return ExitOK
+}
+
+func (lt *loadtest) validate(output string) error {
+	f := &lt.flags
+	switch {
+	case output != "table" && output != "json":
+		return fmt.Errorf("%w: unknown output format %q, want table or json", errUsage, output)
+	case f.workflow == "":
+		return fmt.Errorf("%w: --workflow is required", errUsage)
+	case f.concurrency < 1:
+		return fmt.Errorf("%w: --concurrency must be at least 1", errUsage)
+	case f.rate < 0 || f.count < 0 || f.duration < 0 || f.eventDelay < 0:
+		return fmt.Errorf("%w: --rate, --count, --duration and --event-delay cannot be negative", errUsage)
+	case f.count == 0 && f.duration == 0:
+		return fmt.Errorf("%w: set --count, --duration or both", errUsage)
+	case f.waitTimeout <= 0:
+		return fmt.Errorf("%w: --wait-timeout must be positive", errUsage)
+	}
+
+	var err error
+	if lt.input, err = readInput(f.input, f.inputFile); err != nil {
+		return err
+	}
+	if lt.eventData, err = readInput(f.eventData, ""); err != nil {
+		return err
+	}
+	if f.idPrefix == "" {
+		f.idPrefix = "loadtest-" + uuid.NewString()[:8] + "-"
+	}
+	return nil
+}
+
+// run starts instances until --count or --duration is reached, or ctx is done, and waits
+// for the ones started to finish.
+func (lt *loadtest) run(ctx context.Context, stderr io.Writer) loadtestReport {
+	f := lt.flags
+	startedAt := time.Now()
+
+	// Starting stops on its own deadline, while the instances started may run past it.
+	startCtx, stopStarting := context.WithCancel(ctx)
+	defer stopStarting()
+	if f.duration > 0 {
+		startCtx, stopStarting = context.WithTimeout(ctx, f.duration)
+		defer stopStarting()
+	}
+
+	if f.progress > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go lt.printProgress(stderr, done)
+	}
+
+	var tick <-chan time.Time
+	if f.rate > 0 {
+		// Ticks are dropped while --concurrency instances are in flight, so the achieved
+		// rate shows in the report rather than as a burst afterwards.
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / f.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	sem := make(chan struct{}, f.concurrency)
+	var wg sync.WaitGroup
+loop:
+	for i := 0; f.count == 0 || i < f.count; i++ {
+		if tick != nil && i > 0 {
+			select {
+			case <-tick:
+			case <-startCtx.Done():
+				break loop
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-startCtx.Done():
+			break loop
+		}
+		if startCtx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		lt.inFlight.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer lt.inFlight.Add(-1)
+			lt.instance(ctx, fmt.Sprintf("%s%06d", f.idPrefix, i))
+		}()
+	}
+	wg.Wait()
+	finishedAt := time.Now()
+
+	return lt.report(startedAt, finishedAt, ctx.Err() != nil)
+}
+
+// instance drives one instance through start, event and completion.
+func (lt *loadtest) instance(ctx context.Context, id string) {
+	f := lt.flags
+	lt.attempted.Add(1)
+
+	begin := time.Now()
+	if _, err := lt.b.Start(ctx, f.workflow, id, lt.input); err != nil {
+		lt.fail(ctx, "schedule", err)
+		return
+	}
+	lt.schedule.record(time.Since(begin))
+	lt.scheduled.Add(1)
+	lt.mu.Lock()
+	lt.lastScheduled = time.Now()
+	lt.mu.Unlock()
+
+	if f.event != "" {
+		select {
+		case <-time.After(f.eventDelay - time.Since(begin)):
+		case <-ctx.Done():
+			lt.fail(ctx, "event", ctx.Err())
+			return
+		}
+		raised := time.Now()
+		if err := lt.b.Raise(ctx, id, f.event, lt.eventData); err != nil {
+			lt.fail(ctx, "event", err)
+			return
+		}
+		lt.event.record(time.Since(raised))
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, f.waitTimeout)
+	st, err := lt.b.Wait(waitCtx, id)
+	cancel()
+	switch {
+	case err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded):
+		lt.fail(ctx, "timeout", fmt.Errorf("workflow %s did not finish within %s", id, f.waitTimeout))
+		return
+	case err != nil:
+		lt.fail(ctx, "wait", err)
+		return
+	}
+	lt.completion.record(time.Since(begin))
+
+	lt.mu.Lock()
+	lt.statuses[st.RuntimeStatus]++
+	if st.RuntimeStatus != "COMPLETED" {
+		lt.failed++
+		lt.sample(fmt.Sprintf("workflow %s: %s %s", id, st.RuntimeStatus, st.Failure))
+	}
+	lt.mu.Unlock()
+	if st.RuntimeStatus == "COMPLETED" {
+		lt.completed.Add(1)
+	}
+
+	if f.purge {
+		if err := lt.b.Purge(ctx, id); err != nil {
+			lt.mu.Lock()
+			lt.errors["purge"]++
+			lt.sample("purge: " + err.Error())
+			lt.mu.Unlock()
+		}
+	}
+}
+
+// fail records an instance that did not finish because of err in phase. Instances cut
+// short by an interrupt are not counted as errors of the system under test.
+func (lt *loadtest) fail(ctx context.Context, phase string, err error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if ctx.Err() != nil {
+		lt.canceled++
+		return
+	}
+	lt.failed++
+	lt.errors[phase]++
+	lt.sample(phase + ": " + err.Error())
+}
+
+// sample keeps the first distinct error messages. The caller holds lt.mu.
+func (lt *loadtest) sample(msg string) {
+	msg = strings.TrimSpace(msg)
+	if len(lt.samples) >= maxSampleErrors {
+		return
+	}
+	for _, s := range lt.samples {
+		if s == msg {
+			return
+		}
+	}
+	lt.samples = append(lt.samples, msg)
+}
+
+func (lt *loadtest) printProgress(w io.Writer, done <-chan struct{}) {
+	ticker := time.NewTicker(lt.flags.progress)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		lt.mu.Lock()
+		failed := lt.failed
+		lt.mu.Unlock()
+		fmt.Fprintf(w, "attempted %d, scheduled %d, completed %d, failed %d, in flight %d\n",
+			lt.attempted.Load(), lt.scheduled.Load(), lt.completed.Load(), failed, lt.inFlight.Load())
+	}
+}
+
+func (lt *loadtest) report(startedAt, finishedAt time.Time, interrupted bool) loadtestReport {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	r := loadtestReport{
+		Workflow:     lt.flags.workflow,
+		IDPrefix:     lt.flags.idPrefix,
+		StartedAt:    startedAt.UTC(),
+		FinishedAt:   finishedAt.UTC(),
+		Rate:         lt.flags.rate,
+		Concurrency:  lt.flags.concurrency,
+		Interrupted:  interrupted,
+		Attempted:    int(lt.attempted.Load()),
+		Scheduled:    int(lt.scheduled.Load()),
+		Completed:    int(lt.completed.Load()),
+		Failed:       lt.failed,
+		Canceled:     lt.canceled,
+		Errors:       lt.errors,
+		Statuses:     lt.statuses,
+		SampleErrors: lt.samples,
+		Latency: map[string]latencySummary{
+			"schedule":   lt.schedule.summary(),
+			"event":      lt.event.summary(),
+			"completion": lt.completion.summary(),
+		},
+	}
+	if n := r.Attempted - r.Canceled; n > 0 {
+		r.ErrorRate = float64(r.Failed) / float64(n)
+	}
+	if s := lt.lastScheduled.Sub(startedAt).Seconds(); s > 0 {
+		r.StartRate = float64(r.Scheduled) / s
+	}
+	if s := finishedAt.Sub(startedAt).Seconds(); s > 0 {
+		r.CompletionRate = float64(r.Completed) / s
+	}
+	return r
+}
+
+// write writes the JSON report and the HdrHistogram files the flags ask for.
+func (lt *loadtest) write(r loadtestReport) error {
+	if lt.flags.report != "" {
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(lt.flags.report, append(b, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	if lt.flags.hdr == "" {
+		return nil
+	}
+	for name, l := range map[string]*latencies{
+		"schedule":   &lt.schedule,
+		"event":      &lt.event,
+		"completion": &lt.completion,
+	} {
+		if err := writeHgrmFile(lt.flags.hdr+"-"+name+".hgrm", l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHgrmFile(path string, l *latencies) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := l.writeHgrm(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func reportTable(w io.Writer, r loadtestReport) {
+	elapsed := r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)
+	fmt.Fprintf(w, "%s: %d attempted, %d scheduled, %d completed, %d failed (%.2f%%) in %s\n",
+		r.Workflow, r.Attempted, r.Scheduled, r.Completed, r.Failed, r.ErrorRate*100, elapsed)
+	fmt.Fprintf(w, "throughput: %.1f starts/s, %.1f completions/s\n", r.StartRate, r.CompletionRate)
+	if r.Interrupted {
+		fmt.Fprintf(w, "interrupted before every instance finished, %d canceled\n", r.Canceled)
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "LATENCY (ms)\tCOUNT\tMIN\tMEAN\tP50\tP90\tP95\tP99\tP99.9\tMAX\t")
+	for _, name := range []string{"schedule", "event", "completion"} {
+		s := r.Latency[name]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			name, s.Count, s.Min, s.Mean, s.P50, s.P90, s.P95, s.P99, s.P999, s.Max)
+	}
+	tw.Flush()
+
+	if len(r.Errors) > 0 || len(r.Statuses) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, k := range sortedKeys(r.Statuses) {
+			fmt.Fprintf(tw, "status %s:\t%d\n", k, r.Statuses[k])
+		}
+		for _, k := range sortedKeys(r.Errors) {
+			fmt.Fprintf(tw, "%s errors:\t%d\n", k, r.Errors[k])
+		}
+		tw.Flush()
+	}
+	for _, s := range r.SampleErrors {
+		fmt.Fprintln(w, "  "+s)
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,406 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// Roles select which part of the sample an instance of the binary runs.
+const (
+	// RoleAll runs workflows and activities in the same app.
+	RoleAll = "all"
+	// RoleOrchestrator runs the workflows and any activity that is not routed elsewhere.
+	RoleOrchestrator = "orchestrator"
+	// RoleWorker runs activities only, for orchestrators in other apps to call.
+	RoleWorker = "worker"
+)
+
+// Config is the application configuration, loaded from a YAML file.
+type Config struct {
+	Role          string         `yaml:"role"`
+	Routes        Routes         `yaml:"routes"`
+	Subscriptions []Subscription `yaml:"subscriptions"`
+	Lifecycle     Lifecycle      `yaml:"lifecycle"`
+	API           API            `yaml:"api"`
+	Auth          Auth           `yaml:"auth"`
+	Tenancy       Tenancy        `yaml:"tenancy"`
+	Admission     Admission      `yaml:"admission"`
+	Retention     Retention      `yaml:"retention"`
+	Watchdog      Watchdog       `yaml:"watchdog"`
+	Definitions   Definitions    `yaml:"definitions"`
+	State         State          `yaml:"state"`
+	Locks         Locks          `yaml:"locks"`
+	Callbacks     Callbacks      `yaml:"callbacks"`
+}
+
+// Callbacks configures completion callbacks.
+type Callbacks struct {
+	// Store is the Dapr state store component that keeps the delivery log, so that it is
+	// shared by replicas and survives restarts. Defaults to the store of the state
+	// activities; without either, each app keeps the attempts it made in memory.
+	Store string `yaml:"store"`
+	// AllowPrivateNetworks lets callback URLs address loopback, private and other internal
+	// networks. Only enable it when the callers of the API are trusted.
+	AllowPrivateNetworks bool `yaml:"allowPrivateNetworks"`
+}
+
+// Locks configures the lock activities.
+type Locks struct {
+	// Store is the Dapr lock component the lock activities use. They fail when it is empty.
+	Store string `yaml:"store"`
+}
+
+// State configures the state activities.
+type State struct {
+	// Store is the Dapr state store component the state activities use. They fail when it
+	// is empty.
+	Store string `yaml:"store"`
+}
+
+// Definitions configures the workflows defined in YAML or JSON files rather than in Go.
+type Definitions struct {
+	// Dir holds one definition per .yaml, .yml or .json file. No definitions are loaded
+	// when it is empty.
+	Dir string `yaml:"dir"`
+}
+
+// Actions the watchdog can take on stuck instances.
+const (
+	// WatchdogTerminate terminates the instance and its children.
+	WatchdogTerminate = "terminate"
+	// WatchdogRerun terminates the instance and reruns it as a new instance from its last
+	// scheduled activity or child workflow.
+	WatchdogRerun = "rerun"
+)
+
+// Watchdog reports running instances that have not made progress within their SLA. It is
+// disabled unless Interval and at least one SLA are set.
+type Watchdog struct {
+	// Interval is how often running instances are scanned.
+	Interval time.Duration `yaml:"interval"`
+	// Default applies to workflows without their own SLA.
+	Default WatchdogSLA `yaml:"default"`
+	// Workflows overrides Default, as a whole, per workflow name.
+	Workflows map[string]WatchdogSLA `yaml:"workflows"`
+	// MaxActionsPerScan bounds the actions taken by one scan. Defaults to 10.
+	MaxActionsPerScan int `yaml:"maxActionsPerScan"`
+}
+
+// WatchdogSLA is how long a running instance may go without an update before it is
+// reported as stuck. Zero never reports it.
+type WatchdogSLA struct {
+	SLA time.Duration `yaml:"sla"`
+	// Action is taken on stuck instances: terminate, rerun, or nothing when empty. The
+	// watchdog only sees when an instance was last updated, so an instance waiting on a
+	// long timer or event looks stuck too. Actions are therefore only allowed on the SLA of
+	// a named workflow, set knowing how long it can wait, and never on the default.
+	Action string `yaml:"action"`
+}
+
+// Enabled reports whether the watchdog has anything to check.
+func (w Watchdog) Enabled() bool {
+	if w.Interval <= 0 {
+		return false
+	}
+	if w.Default.SLA > 0 {
+		return true
+	}
+	for _, s := range w.Workflows {
+		if s.SLA > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks durations and actions.
+func (w Watchdog) Validate() error {
+	if w.Interval < 0 || w.MaxActionsPerScan < 0 {
+		return fmt.Errorf("interval and maxActionsPerScan cannot be negative")
+	}
+	if w.Default.Action != "" {
+		return fmt.Errorf("default cannot have an action; set actions on the workflows they apply to")
+	}
+	slas := map[string]WatchdogSLA{"default": w.Default}
+	for name, s := range w.Workflows {
+		slas["workflow "+name] = s
+	}
+	for name, s := range slas {
+		if s.SLA < 0 {
+			return fmt.Errorf("%s sla cannot be negative", name)
+		}
+		switch s.Action {
+		case "":
+		case WatchdogTerminate, WatchdogRerun:
+			if s.SLA == 0 {
+				return fmt.Errorf("%s has action %q but no sla", name, s.Action)
+			}
+		default:
+			return fmt.Errorf("%s has unknown action %q", name, s.Action)
+		}
+	}
+	return nil
+}
+
+// Retention purges instances some time after they finish. It is disabled unless Interval
+// and at least one retention period are set.
+type Retention struct {
+	// Interval is how often instances are checked.
+	Interval time.Duration `yaml:"interval"`
+	// Default applies to workflows without their own policy.
+	Default RetentionPolicy `yaml:"default"`
+	// Workflows overrides Default, as a whole, per workflow name.
+	Workflows map[string]RetentionPolicy `yaml:"workflows"`
+	// DryRun only reports the instances that would be purged.
+	DryRun bool `yaml:"dryRun"`
+}
+
+// RetentionPolicy is how long instances are kept after they finish, per terminal status.
+// Zero keeps them forever.
+type RetentionPolicy struct {
+	Completed  time.Duration `yaml:"completed"`
+	Failed     time.Duration `yaml:"failed"`
+	Terminated time.Duration `yaml:"terminated"`
+}
+
+// Enabled reports whether retention has anything to purge.
+func (r Retention) Enabled() bool {
+	if r.Interval <= 0 {
+		return false
+	}
+	if r.Default != (RetentionPolicy{}) {
+		return true
+	}
+	for _, p := range r.Workflows {
+		if p != (RetentionPolicy{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that no duration is negative.
+func (r Retention) Validate() error {
+	if r.Interval < 0 {
+		return fmt.Errorf("interval cannot be negative")
+	}
+	policies := map[string]RetentionPolicy{"default": r.Default}
+	for name, p := range r.Workflows {
+		policies["workflow "+name] = p
+	}
+	for name, p := range policies {
+		if p.Completed < 0 || p.Failed < 0 || p.Terminated < 0 {
+			return fmt.Errorf("%s policy cannot have negative periods", name)
+		}
+	}
+	return nil
+}
+
+// Admission limits how fast workflows can be started through the API. Zero values disable
+// a limit. The limits can also be changed at runtime through the admin API.
+type Admission struct {
+	Global    RateLimit `yaml:"global" json:"global"`
+	PerTenant RateLimit `yaml:"perTenant" json:"perTenant"`
+	// PerCaller applies to each authenticated caller, such as an API key.
+	PerCaller RateLimit `yaml:"perCaller" json:"perCaller"`
+	// MaxInFlight caps the instances started by this replica that have not completed yet.
+	MaxInFlight int `yaml:"maxInFlight" json:"maxInFlight"`
+}
+
+// RateLimit is a token bucket refilled at Rate tokens per second up to Burst tokens.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+}
+
+// Validate checks that the limits are not negative and that every rate has a burst.
+func (a Admission) Validate() error {
+	for name, l := range map[string]RateLimit{"global": a.Global, "perTenant": a.PerTenant, "perCaller": a.PerCaller} {
+		if l.Rate < 0 || l.Burst < 0 {
+			return fmt.Errorf("%s rate limit cannot be negative", name)
+		}
+		if l.Rate > 0 && l.Burst == 0 {
+			return fmt.Errorf("%s rate limit needs a burst", name)
+		}
+	}
+	if a.MaxInFlight < 0 {
+		return fmt.Errorf("maxInFlight cannot be negative")
+	}
+	return nil
+}
+
+// Tenancy configures how API requests are scoped to tenants.
+type Tenancy struct {
+	// Required rejects API requests that resolve to no tenant. Otherwise such requests act
+	// across all instances.
+	Required bool `yaml:"required"`
+}
+
+// Auth configures how API callers are authenticated. Authentication is disabled when
+// neither API keys nor a JWKS file are configured.
+type Auth struct {
+	APIKeys []APIKey `yaml:"apiKeys"`
+	JWT     JWT      `yaml:"jwt"`
+}
+
+// APIKey is a static key accepted in the X-API-Key header. Only the SHA-256 of the key is
+// kept in the configuration.
+type APIKey struct {
+	// Name identifies the caller in logs and traces.
+	Name string `yaml:"name"`
+	// SHA256 is the hex-encoded SHA-256 of the key.
+	SHA256 string `yaml:"sha256"`
+	// Role is viewer, operator or admin.
+	Role string `yaml:"role"`
+	// Tenant binds the key to a tenant. Keys without one may pick any tenant.
+	Tenant string `yaml:"tenant"`
+}
+
+// JWT configures validation of bearer tokens.
+type JWT struct {
+	// JWKSFile is a local JSON Web Key Set holding the keys tokens are signed with.
+	JWKSFile string `yaml:"jwksFile"`
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// RolesClaim names the claim listing the caller's roles. Defaults to "roles".
+	RolesClaim string `yaml:"rolesClaim"`
+	// TenantClaim names the claim binding the caller to a tenant. Defaults to "tenant".
+	TenantClaim string `yaml:"tenantClaim"`
+}
+
+// API configures the HTTP and gRPC APIs.
+type API struct {
+	// MaxWatchers caps concurrent workflow watch streams. Zero uses the built-in default.
+	MaxWatchers int `yaml:"maxWatchers"`
+	// GRPCAddr is the listen address of the gRPC API. The gRPC API is disabled when empty.
+	GRPCAddr string `yaml:"grpcAddr"`
+}
+
+// DefaultGRPCAddr is the listen address of the gRPC API unless configured otherwise.
+const DefaultGRPCAddr = ":50051"
+
+// Lifecycle selects where workflow lifecycle CloudEvents are published. Publishing is
+// disabled when PubSub is empty.
+type Lifecycle struct {
+	PubSub string `yaml:"pubsub"`
+	Topic  string `yaml:"topic"`
+}
+
+// Routes maps activity and child workflow names to the Dapr app ID that hosts them.
+// Names that are not listed run in the calling app.
+type Routes struct {
+	Activities map[string]string `yaml:"activities"`
+	Workflows  map[string]string `yaml:"workflows"`
+}
+
+// Subscription starts a workflow for every message published to a pub/sub topic.
+type Subscription struct {
+	PubSub   string `yaml:"pubsub"`
+	Topic    string `yaml:"topic"`
+	Workflow string `yaml:"workflow"`
+	// InstanceIDField is a dot-separated path into the message data whose value becomes the
+	// instance ID, so redelivered messages do not start a second instance.
+	InstanceIDField string `yaml:"instanceIdField"`
+	// InstanceIDPrefix is prepended to the value found at InstanceIDField. It cannot contain
+	// tenant.Separator, and messages whose value does are dropped.
+	InstanceIDPrefix string `yaml:"instanceIdPrefix"`
+	// Tenant scopes the started instances to a tenant. It is required when tenancy is.
+	Tenant string `yaml:"tenant"`
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	return &Config{Role: RoleAll, API: API{GRPCAddr: DefaultGRPCAddr}}
+}
+
+// Load reads the configuration at path. An empty path returns Default().
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration for unknown roles and empty route targets.
+func (c *Config) Validate() error {
+	switch c.Role {
+	case RoleAll, RoleOrchestrator, RoleWorker:
+	default:
+		return fmt.Errorf("unknown role %q", c.Role)
+	}
+	for name, appID := range c.Routes.Activities {
+		if appID == "" {
+			return fmt.Errorf("activity route %s has no app ID", name)
+		}
+	}
+	for name, appID := range c.Routes.Workflows {
+		if appID == "" {
+			return fmt.Errorf("workflow route %s has no app ID", name)
+		}
+	}
+	if c.Lifecycle.PubSub != "" && c.Lifecycle.Topic == "" {
+		return fmt.Errorf("lifecycle publishing needs a topic")
+	}
+	for i, key := range c.Auth.APIKeys {
+		if key.Name == "" || key.Role == "" {
+			return fmt.Errorf("api key %d needs a name and a role", i)
+		}
+		if b, err := hex.DecodeString(key.SHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("api key %s needs a hex-encoded sha256", key.Name)
+		}
+	}
+	if err := c.Admission.Validate(); err != nil {
+		return fmt.Errorf("admission: %w", err)
+	}
+	if err := c.Retention.Validate(); err != nil {
+		return fmt.Errorf("retention: %w", err)
+	}
+	if err := c.Watchdog.Validate(); err != nil {
+		return fmt.Errorf("watchdog: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i, sub := range c.Subscriptions {
+		if sub.PubSub == "" || sub.Topic == "" || sub.Workflow == "" || sub.InstanceIDField == "" {
+			return fmt.Errorf("subscription %d needs pubsub, topic, workflow and instanceIdField", i)
+		}
+		key := sub.PubSub + "/" + sub.Topic
+		if sub.Tenant == "" && c.Tenancy.Required {
+			return fmt.Errorf("subscription for %s needs a tenant, since tenancy is required", key)
+		}
+		if sub.Tenant != "" {
+			if err := tenant.Validate(sub.Tenant); err != nil {
+				return fmt.Errorf("subscription for %s: %w", key, err)
+			}
+		}
+		if strings.Contains(sub.InstanceIDPrefix, tenant.Separator) {
+			return fmt.Errorf("subscription for %s: instanceIdPrefix cannot contain %q", key, tenant.Separator)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate subscription for %s", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSubscriptions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sub     Subscription
+		tenancy bool
+		wantErr string
+	}{
+		{name: "valid", sub: Subscription{PubSub: "pubsub", Topic: "orders", Workflow: "W", InstanceIDField: "id", InstanceIDPrefix: "order-"}},
+		{name: "no field", sub: Subscription{PubSub: "pubsub", Topic: "orders", Workflow: "W"}, wantErr: "needs pubsub"},
+		{name: "prefix with tenant separator", sub: Subscription{PubSub: "pubsub", Topic: "orders", Workflow: "W", InstanceIDField: "id", InstanceIDPrefix: "acme~"},
+			wantErr: "instanceIdPrefix cannot contain"},
+		{name: "tenancy without tenant", sub: Subscription{PubSub: "pubsub", Topic: "orders", Workflow: "W", InstanceIDField: "id"}, tenancy: true,
+			wantErr: "needs a tenant"},
+	} {
+		cfg := Default()
+		cfg.Tenancy.Required = tc.tenancy
+		cfg.Subscriptions = []Subscription{tc.sub}
+		err := cfg.Validate()
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+// Package daprtest runs a fake Dapr sidecar for tests of the code that manages workflow
+// instances through the durabletask client. It keeps instances in memory and never runs
+// them: tests put instances in the state they need and check what the code under test
+// did to them.
+package daprtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// Instance is a workflow instance kept by the Sidecar.
+type Instance struct {
+	ID           string
+	Name         string
+	Status       protos.OrchestrationStatus
+	Input        string
+	Output       string
+	CustomStatus string
+	// Failure is the error message of a failed instance.
+	Failure   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// History is what GetInstanceHistory returns for the instance.
+	History []*protos.HistoryEvent
+	// Events lists the events raised on the instance, in order.
+	Events []Event
+}
+
+// Event is an event raised on an instance.
+type Event struct {
+	Name string
+	Data string
+}
+
+// Sidecar implements the workflow management calls of the Dapr sidecar over instances kept
+// in memory.
+type Sidecar struct {
+	protos.UnimplementedTaskHubSidecarServiceServer
+
+	client *workflow.Client
+
+	mu        sync.Mutex
+	instances map[string]*Instance
+	// order holds the instance IDs in creation order, the order they are listed in.
+	order    []string
+	calls    []string
+	failures map[string]error
+	// notFoundStatus reports missing instances to GetInstance as a NotFound status.
+	notFoundStatus bool
+	now            func() time.Time
+}
+
+// Start runs a Sidecar until the test ends.
+func Start(t testing.TB) *Sidecar {
+	t.Helper()
+	s := &Sidecar{
+		instances: make(map[string]*Instance),
+		failures:  make(map[string]error),
+		now:       time.Now,
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	protos.RegisterTaskHubSidecarServiceServer(srv, s)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///"+lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	s.client = workflow.NewClient(conn)
+	return s
+}
+
+// Client returns a durabletask client of the sidecar.
+func (s *Sidecar) Client() *workflow.Client {
+	return s.client
+}
+
+// SetClock makes the sidecar timestamp the instances it creates and changes with now.
+func (s *Sidecar) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Put adds in, or replaces the instance with its ID.
+func (s *Sidecar) Put(in Instance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.instances[in.ID]; !ok {
+		s.order = append(s.order, in.ID)
+	}
+	s.instances[in.ID] = &in
+}
+
+// Instance returns the instance id, if it exists.
+func (s *Sidecar) Instance(id string) (Instance, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.instances[id]
+	if !ok {
+		return Instance{}, false
+	}
+	return *in, true
+}
+
+// IDs returns the IDs of the instances, in creation order.
+func (s *Sidecar) IDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.order...)
+}
+
+// Calls returns the calls that changed instances, in order, as "<method> <instance ID>".
+func (s *Sidecar) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// Fail makes every call of the named method, such as "GetInstance", fail with err. A nil
+// err restores the method.
+func (s *Sidecar) Fail(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.failures, method)
+		return
+	}
+	s.failures[method] = err
+}
+
+// ReportNotFoundAsStatus makes GetInstance fail with a NotFound status for missing
+// instances, as some sidecar versions do, instead of answering that they do not exist.
+func (s *Sidecar) ReportNotFoundAsStatus(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notFoundStatus = on
+}
+
+// call records a call of method on id, and returns the error method is set to fail with.
+// s.mu must be held.
+func (s *Sidecar) call(method, id string) error {
+	if err := s.failures[method]; err != nil {
+		return err
+	}
+	s.calls = append(s.calls, method+" "+id)
+	return nil
+}
+
+// lookup returns instance id, or a NotFound status. s.mu must be held.
+func (s *Sidecar) lookup(id string) (*Instance, error) {
+	in, ok := s.instances[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "instance %s not found", id)
+	}
+	return in, nil
+}
+
+func (s *Sidecar) Hello(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Sidecar) StartInstance(_ context.Context, req *protos.CreateInstanceRequest) (*protos.CreateInstanceResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.call("StartInstance", req.InstanceId); err != nil {
+		return nil, err
+	}
+	if _, ok := s.instances[req.InstanceId]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "instance %s already exists", req.InstanceId)
+	}
+	now := s.now()
+	s.instances[req.InstanceId] = &Instance{
+		ID:        req.InstanceId,
+		Name:      req.Name,
+		Status:    protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING,
+		Input:     req.GetInput().GetValue(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.order = append(s.order, req.InstanceId)
+	return &protos.CreateInstanceResponse{InstanceId: req.InstanceId}, nil
+}
+
+func (s *Sidecar) GetInstance(_ context.Context, req *protos.GetInstanceRequest) (*protos.GetInstanceResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failures["GetInstance"]; err != nil {
+		return nil, err
+	}
+	return s.getInstance(req)
+}
+
+// getInstance answers req. s.mu must be held.
+func (s *Sidecar) getInstance(req *protos.GetInstanceRequest) (*protos.GetInstanceResponse, error) {
+	in, ok := s.instances[req.InstanceId]
+	if !ok {
+		if s.notFoundStatus {
+			return nil, status.Errorf(codes.NotFound, "instance %s not found", req.InstanceId)
+		}
+		return &protos.GetInstanceResponse{Exists: false}, nil
+	}
+	st := &protos.OrchestrationState{
+		InstanceId:           in.ID,
+		Name:                 in.Name,
+		OrchestrationStatus:  in.Status,
+		CreatedTimestamp:     timestamppb.New(in.CreatedAt),
+		LastUpdatedTimestamp: timestamppb.New(in.UpdatedAt),
+	}
+	if in.CustomStatus != "" {
+		st.CustomStatus = wrapperspb.String(in.CustomStatus)
+	}
+	if req.GetInputsAndOutputs {
+		if in.Input != "" {
+			st.Input = wrapperspb.String(in.Input)
+		}
+		if in.Output != "" {
+			st.Output = wrapperspb.String(in.Output)
+		}
+	}
+	if in.Failure != "" {
+		st.FailureDetails = &protos.TaskFailureDetails{ErrorType: "error", ErrorMessage: in.Failure}
+	}
+	return &protos.GetInstanceResponse{Exists: true, OrchestrationState: st}, nil
+}
+
+// WaitForInstanceCompletion returns once the instance finished or ctx is done.
+func (s *Sidecar) WaitForInstanceCompletion(ctx context.Context, req *protos.GetInstanceRequest) (*protos.GetInstanceResponse, error) {
+	for {
+		s.mu.Lock()
+		in, ok := s.instances[req.InstanceId]
+		if !ok || finished(in.Status) {
+			resp, err := s.getInstance(req)
+			s.mu.Unlock()
+			return resp, err
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (s *Sidecar) TerminateInstance(_ context.Context, req *protos.TerminateRequest) (*protos.TerminateResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.call("TerminateInstance", req.InstanceId); err != nil {
+		return nil, err
+	}
+	in, err := s.lookup(req.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	if !finished(in.Status) {
+		in.Status = protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED
+		in.Output = req.GetOutput().GetValue()
+		in.UpdatedAt = s.now()
+	}
+	return &protos.TerminateResponse{}, nil
+}
+
+func (s *Sidecar) SuspendInstance(_ context.Context, req *protos.SuspendRequest) (*protos.SuspendResponse, error) {
+	return &protos.SuspendResponse{}, s.setStatus("SuspendInstance", req.InstanceId,
+		protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING, protos.OrchestrationStatus_ORCHESTRATION_STATUS_SUSPENDED)
+}
+
+func (s *Sidecar) ResumeInstance(_ context.Context, req *protos.ResumeRequest) (*protos.ResumeResponse, error) {
+	return &protos.ResumeResponse{}, s.setStatus("ResumeInstance", req.InstanceId,
+		protos.OrchestrationStatus_ORCHESTRATION_STATUS_SUSPENDED, protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING)
+}
+
+// setStatus moves instance id from status from to status to, and leaves it as is in any
+// other status.
+func (s *Sidecar) setStatus(method, id string, from, to protos.OrchestrationStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.call(method, id); err != nil {
+		return err
+	}
+	in, err := s.lookup(id)
+	if err != nil {
+		return err
+	}
+	if in.Status == from {
+		in.Status = to
+		in.UpdatedAt = s.now()
+	}
+	return nil
+}
+
+func (s *Sidecar) RaiseEvent(_ context.Context, req *protos.RaiseEventRequest) (*protos.RaiseEventResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.call("RaiseEvent", req.InstanceId); err != nil {
+		return nil, err
+	}
+	in, err := s.lookup(req.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	in.Events = append(in.Events, Event{Name: req.Name, Data: req.GetInput().GetValue()})
+	return &protos.RaiseEventResponse{}, nil
+}
+
+// PurgeInstances deletes a finished instance, or any instance when forced.
+func (s *Sidecar) PurgeInstances(_ context.Context, req *protos.PurgeInstancesRequest) (*protos.PurgeInstancesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := req.GetInstanceId()
+	if err := s.call("PurgeInstances", id); err != nil {
+		return nil, err
+	}
+	in, ok := s.instances[id]
+	if !ok {
+		return &protos.PurgeInstancesResponse{DeletedInstanceCount: 0}, nil
+	}
+	if !finished(in.Status) && !req.GetForce() {
+		return nil, status.Errorf(codes.FailedPrecondition, "instance %s is %s", id, in.Status)
+	}
+	s.remove(id)
+	return &protos.PurgeInstancesResponse{DeletedInstanceCount: 1}, nil
+}
+
+// remove deletes instance id. s.mu must be held.
+func (s *Sidecar) remove(id string) {
+	delete(s.instances, id)
+	for i, o := range s.order {
+		if o == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// ListInstanceIDs lists the instances in creation order. The continuation token is the
+// position of the next page.
+func (s *Sidecar) ListInstanceIDs(_ context.Context, req *protos.ListInstanceIDsRequest) (*protos.ListInstanceIDsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failures["ListInstanceIDs"]; err != nil {
+		return nil, err
+	}
+	start := 0
+	if req.ContinuationToken != nil {
+		var err error
+		if start, err = strconv.Atoi(req.GetContinuationToken()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid continuation token %q", req.GetContinuationToken())
+		}
+	}
+	size := int(req.GetPageSize())
+	if size == 0 {
+		size = len(s.order)
+	}
+	end := min(start+size, len(s.order))
+	resp := &protos.ListInstanceIDsResponse{InstanceIds: append([]string{}, s.order[min(start, end):end]...)}
+	if end < len(s.order) {
+		token := strconv.Itoa(end)
+		resp.ContinuationToken = &token
+	}
+	return resp, nil
+}
+
+func (s *Sidecar) GetInstanceHistory(_ context.Context, req *protos.GetInstanceHistoryRequest) (*protos.GetInstanceHistoryResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failures["GetInstanceHistory"]; err != nil {
+		return nil, err
+	}
+	in, err := s.lookup(req.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	return &protos.GetInstanceHistoryResponse{Events: in.History}, nil
+}
+
+// RerunWorkflowFromEvent starts a new instance of a finished one, with the same name and
+// input.
+func (s *Sidecar) RerunWorkflowFromEvent(_ context.Context, req *protos.RerunWorkflowFromEventRequest) (*protos.RerunWorkflowFromEventResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.call("RerunWorkflowFromEvent", fmt.Sprintf("%s %d", req.SourceInstanceID, req.EventID)); err != nil {
+		return nil, err
+	}
+	src, err := s.lookup(req.SourceInstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if !finished(src.Status) {
+		return nil, status.Errorf(codes.FailedPrecondition, "instance %s is %s", src.ID, src.Status)
+	}
+	id := req.GetNewInstanceID()
+	if id == "" {
+		id = uuid.NewString()
+	}
+	if _, ok := s.instances[id]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "instance %s already exists", id)
+	}
+	now := s.now()
+	s.instances[id] = &Instance{
+		ID:        id,
+		Name:      src.Name,
+		Status:    protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING,
+		Input:     src.Input,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.order = append(s.order, id)
+	return &protos.RerunWorkflowFromEventResponse{NewInstanceID: id}, nil
+}
+
+func finished(st protos.OrchestrationStatus) bool {
+	switch st {
+	case protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED,
+		protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED,
+		protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED:
+		return true
+	}
+	return false
+}
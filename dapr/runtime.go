@@ -3,15 +3,25 @@ package dapr
 import (
 	"context"
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 
+	"github.com/dapr/durabletask-go/api/helpers"
+	"github.com/dapr/durabletask-go/api/protos"
 	"github.com/dapr/durabletask-go/workflow"
 	"github.com/dapr/go-sdk/client"
 
 	"github.com/dapr/kit/logger"
 
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/lifecycle"
+	"github.com/javier-aliaga/dapr-go-samples/lock"
+	"github.com/javier-aliaga/dapr-go-samples/state"
 	"github.com/javier-aliaga/dapr-go-samples/workflows"
 )
 
@@ -19,55 +29,343 @@ var log = logger.NewLogger("dapr.runtime")
 
 type WorkflowRuntime struct {
 	client  *workflow.Client
+	dapr    client.Client
 	runtime *workflow.Registry
+	// workflows are the names registered with the runtime, in registration order.
+	workflows []string
+}
+
+// NewWorkflowRuntime returns a runtime that manages the instances of the named workflows
+// through client, without registering or running any itself. The API can serve it, for
+// example in front of a daprtest.Sidecar.
+func NewWorkflowRuntime(client *workflow.Client, workflows ...string) *WorkflowRuntime {
+	return &WorkflowRuntime{client: client, workflows: workflows}
 }
 
 func (w *WorkflowRuntime) Client() *workflow.Client {
 	return w.client
 }
 
-// StartWorkflowRuntime bootstraps the Dapr Workflow runtime and registers workflows.
-func StartWorkflowRuntime(ctx context.Context) (*WorkflowRuntime, error) {
-	r := workflow.NewRegistry()
+// Workflows returns the names of the workflows this app hosts. It is empty for workers.
+func (w *WorkflowRuntime) Workflows() []string {
+	return w.workflows
+}
 
-	// Register your workflows and activities
-	err := r.AddWorkflowN("SimpleWorkflow", workflows.SimpleWorkflow)
-	if err != nil {
-		return nil, fmt.Errorf("register workflow: %w", err)
+// DaprClient returns the Dapr API client sharing the workflow client's connection.
+func (w *WorkflowRuntime) DaprClient() client.Client {
+	return w.dapr
+}
+
+type hostedWorkflow struct {
+	name     string
+	workflow workflow.Workflow
+	eternal  bool
+	child    bool
+}
+
+// registeredWorkflows are the workflows hosted by orchestrator apps.
+// Workflows that continue as new set eternal, which opts them out of lifecycle events and
+// completion callbacks. Workflows only started by other workflows set child, which opts
+// them out of lifecycle events: they are part of their parent's run.
+var registeredWorkflows = []hostedWorkflow{
+	{name: "SimpleWorkflow", workflow: workflows.SimpleWorkflow},
+	{name: "ChildWorkflow", workflow: workflows.ChildWorkflow, child: true},
+	{name: "BatchWorkflow", workflow: workflows.BatchWorkflow},
+	{name: "OrderSagaWorkflow", workflow: workflows.OrderSagaWorkflow},
+	{name: "ApprovalWorkflow", workflow: workflows.ApprovalWorkflow},
+	{name: "MonitorWorkflow", workflow: workflows.MonitorWorkflow, eternal: true},
+}
+
+// registeredActivities are the activities hosted by worker apps, and by orchestrator apps
+// unless they are routed elsewhere.
+var registeredActivities = []workflow.Activity{
+	workflows.Activity1,
+	workflows.Activity2,
+	workflows.Activity3,
+	workflows.ProcessItem,
+	workflows.ReserveInventory,
+	workflows.ReleaseInventory,
+	workflows.ChargePayment,
+	workflows.RefundPayment,
+	workflows.ShipOrder,
+	workflows.SendApprovalReminder,
+	workflows.CheckHealth,
+	workflows.PublishLifecycleEvent,
+	workflows.DeliverCallback,
+	workflows.HTTPRequest,
+	workflows.GetState,
+	workflows.GetBulkState,
+	workflows.SaveState,
+	workflows.DeleteState,
+	workflows.UpdateState,
+	workflows.AcquireLock,
+	workflows.RenewLock,
+	workflows.ReleaseLock,
+}
+
+// callbackSecretEnv names the environment variable holding the HMAC secret used to sign
+// completion callbacks.
+const callbackSecretEnv = "CALLBACK_HMAC_SECRET"
+
+// defaultActivityTimeout bounds activities that have no entry in activityTimeouts.
+const defaultActivityTimeout = 5 * time.Minute
+
+// activityTimeouts overrides defaultActivityTimeout per registered activity name.
+var activityTimeouts = map[string]time.Duration{
+	"CheckHealth": 10 * time.Second,
+}
+
+// Option configures StartWorkflowRuntime.
+type Option func(*options)
+
+type options struct {
+	config        *config.Config
+	interceptors  []workflows.ActivityInterceptor
+	lifecycleSink lifecycle.Sink
+	stateStore    state.Store
+	lockStore     lock.Store
+}
+
+// WithLifecycleSink publishes workflow lifecycle events to sink instead of the pub/sub
+// topic from the config, for example a lifecycle.MemorySink that captures them.
+func WithLifecycleSink(sink lifecycle.Sink) Option {
+	return func(o *options) {
+		o.lifecycleSink = sink
 	}
-	err = r.AddWorkflowN("ChildWorkflow", workflows.ChildWorkflow)
-	if err != nil {
-		return nil, fmt.Errorf("register workflow: %w", err)
+}
+
+// WithStateStore backs the state activities with store instead of the Dapr state store
+// from the config, for example a state.MemoryStore for local runs.
+func WithStateStore(store state.Store) Option {
+	return func(o *options) {
+		o.stateStore = store
 	}
-	err = r.AddActivity(workflows.Activity1)
-	if err != nil {
-		return nil, fmt.Errorf("register activity: %w", err)
+}
+
+// WithLockStore backs the lock activities with store instead of the Dapr lock component
+// from the config, for example a lock.MemoryStore for local runs.
+func WithLockStore(store lock.Store) Option {
+	return func(o *options) {
+		o.lockStore = store
 	}
-	err = r.AddActivity(workflows.Activity2)
-	if err != nil {
-		return nil, fmt.Errorf("register activity: %w", err)
+}
+
+// WithConfig selects the role and routing table of the runtime. Without it the runtime
+// runs every workflow and activity locally.
+func WithConfig(cfg *config.Config) Option {
+	return func(o *options) {
+		o.config = cfg
 	}
-	err = r.AddActivity(workflows.Activity3)
-	if err != nil {
-		return nil, fmt.Errorf("register activity: %w", err)
+}
+
+// WithActivityInterceptors adds interceptors around every registered activity. They run
+// inside the built-in logging, metrics, tracing, timeout and recovery interceptors, in the
+// order given.
+func WithActivityInterceptors(interceptors ...workflows.ActivityInterceptor) Option {
+	return func(o *options) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// registry wraps workflow.Registry so that every activity added through AddActivity is
+// wrapped by the interceptor chain.
+type registry struct {
+	*workflow.Registry
+	intercept workflows.ActivityInterceptor
+}
+
+func (r *registry) AddActivity(a workflow.Activity) error {
+	return r.AddActivityN(helpers.GetTaskFunctionName(a), a)
+}
+
+func (r *registry) AddActivityN(name string, a workflow.Activity) error {
+	return r.Registry.AddActivityN(name, r.intercept(name, a))
+}
+
+// routeActions applies the routing table to the actions the worker sends to the sidecar
+// after each orchestration step, so that workflows calling ctx.CallActivity or
+// ctx.CallChildWorkflow directly are routed too.
+func routeActions(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if resp, ok := req.(*protos.OrchestratorResponse); ok && method == protos.TaskHubSidecarService_CompleteOrchestratorTask_FullMethodName {
+		workflows.RouteActions(resp.GetActions())
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// StartWorkflowRuntime bootstraps the Dapr Workflow runtime and registers workflows.
+func StartWorkflowRuntime(ctx context.Context, opts ...Option) (*WorkflowRuntime, error) {
+	o := options{config: config.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Custom interceptors sit inside RecoverInterceptor so their panics are caught too.
+	interceptors := append(workflows.DefaultActivityInterceptors(defaultActivityTimeout, activityTimeouts), o.interceptors...)
+
+	r := &registry{
+		Registry:  workflow.NewRegistry(),
+		intercept: workflows.ChainActivityInterceptors(interceptors...),
 	}
 
-	wClient, err := client.NewWorkflowClient(grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	wClient, err := client.NewWorkflowClient(
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(routeActions),
+	)
 	//wClient, err := client.NewWorkflowClient()
 	if err != nil {
 		return nil, fmt.Errorf("create workflow client: %w", err)
 	}
 
+	// NewClient returns the connection NewWorkflowClient just created.
+	daprClient, err := client.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("create dapr client: %w", err)
+	}
+
+	switch lc := o.config.Lifecycle; {
+	case o.lifecycleSink != nil:
+		lifecycle.SetSink(o.lifecycleSink)
+	case lc.PubSub != "":
+		lifecycle.SetSink(&lifecycle.DaprSink{Client: daprClient, PubSub: lc.PubSub, Topic: lc.Topic})
+	}
+
+	switch name := o.config.State.Store; {
+	case o.stateStore != nil:
+		state.SetStore(o.stateStore)
+	case name != "":
+		state.SetStore(&state.DaprStore{Client: daprClient, Name: name})
+	}
+
+	switch name := o.config.Locks.Store; {
+	case o.lockStore != nil:
+		lock.SetStore(o.lockStore)
+	case name != "":
+		lock.SetStore(&lock.DaprStore{Client: daprClient, Name: name})
+	}
+
+	deliveries := o.config.Callbacks.Store
+	if deliveries == "" {
+		deliveries = o.config.State.Store
+	}
+	var deliveryLog callbacks.DeliveryLog
+	if deliveries != "" {
+		deliveryLog = &callbacks.StoreLog{Store: &state.DaprStore{Client: daprClient, Name: deliveries}}
+	}
+	callbacks.Configure(callbacks.Settings{
+		Secret:               []byte(os.Getenv(callbackSecretEnv)),
+		Log:                  deliveryLog,
+		AllowPrivateNetworks: o.config.Callbacks.AllowPrivateNetworks,
+	})
+	if os.Getenv(callbackSecretEnv) == "" {
+		log.Warnf("%s is not set, completion callbacks will not be signed", callbackSecretEnv)
+	}
+
+	workflows.SetRoutes(workflows.Routes{
+		Activities: o.config.Routes.Activities,
+		Workflows:  o.config.Routes.Workflows,
+	})
+
+	// Register your workflows and activities
+	var names []string
+	if o.config.Role != config.RoleWorker {
+		defs, err := loadDefinitions(o.config)
+		if err != nil {
+			return nil, err
+		}
+		hosted := slices.Clone(registeredWorkflows)
+		for _, def := range defs {
+			hosted = append(hosted, hostedWorkflow{name: def.Name, workflow: workflows.Interpret(def)})
+			log.Infof("Loaded workflow %s from %s (sha256 %s)", def.Name, def.File, def.Hash[:12])
+		}
+
+		for _, wf := range hosted {
+			fn := wf.workflow
+			if !wf.eternal {
+				fn = workflows.WithCompletionCallback(fn)
+				// Wrapping does not depend on lifecycle.Enabled(): the config may differ
+				// between the run that scheduled the events and the one that replays them.
+				if !wf.child {
+					fn = workflows.WithLifecycleEvents(fn)
+				}
+			}
+			if err := r.AddWorkflowN(wf.name, fn); err != nil {
+				return nil, fmt.Errorf("register workflow: %w", err)
+			}
+			names = append(names, wf.name)
+		}
+	}
+	for _, a := range registeredActivities {
+		name := helpers.GetTaskFunctionName(a)
+		// An orchestrator leaves routed activities to the app that owns them.
+		if o.config.Role == config.RoleOrchestrator && workflows.ActivityAppID(name) != "" {
+			continue
+		}
+		if err := r.AddActivityN(name, a); err != nil {
+			return nil, fmt.Errorf("register activity: %w", err)
+		}
+	}
+	log.Infof("Registered workflow runtime with role %s", o.config.Role)
+
 	// Start runtime in background
 	go func() {
-		if err := wClient.StartWorker(ctx, r); err != nil {
+		if err := wClient.StartWorker(ctx, r.Registry); err != nil {
 			// In a real app, propagate or log more robustly
 			log.Info("workflow runtime stopped: %v\n", err)
 		}
 	}()
 
 	return &WorkflowRuntime{
-		client:  wClient,
-		runtime: r,
+		client:    wClient,
+		dapr:      daprClient,
+		runtime:   r.Registry,
+		workflows: names,
 	}, nil
-}
\ No newline at end of file
+}
+
+// loadDefinitions loads the declarative workflows of cfg and checks that every activity
+// and child workflow they call is hosted here or routed to another app.
+func loadDefinitions(cfg *config.Config) ([]*workflows.Definition, error) {
+	if cfg.Definitions.Dir == "" {
+		return nil, nil
+	}
+	defs, err := workflows.LoadDefinitions(cfg.Definitions.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	activities := make(map[string]bool)
+	for _, a := range registeredActivities {
+		activities[helpers.GetTaskFunctionName(a)] = true
+	}
+	for name := range cfg.Routes.Activities {
+		activities[name] = true
+	}
+	wfs := make(map[string]bool)
+	for _, wf := range registeredWorkflows {
+		wfs[wf.name] = true
+	}
+	for name := range cfg.Routes.Workflows {
+		wfs[name] = true
+	}
+	for _, def := range defs {
+		if wfs[def.Name] {
+			return nil, fmt.Errorf("definition %s: workflow %s is already registered or routed", def.File, def.Name)
+		}
+		wfs[def.Name] = true
+	}
+
+	for _, def := range defs {
+		calledActivities, calledWorkflows := def.References()
+		for _, name := range calledActivities {
+			if !activities[name] {
+				return nil, fmt.Errorf("definition %s: unknown activity %s", def.File, name)
+			}
+		}
+		for _, name := range calledWorkflows {
+			if !wfs[name] {
+				return nil, fmt.Errorf("definition %s: unknown workflow %s", def.File, name)
+			}
+		}
+	}
+	return defs, nil
+}
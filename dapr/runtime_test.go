@@ -0,0 +1,47 @@
+package dapr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"google.golang.org/grpc"
+
+	"github.com/javier-aliaga/dapr-go-samples/workflows"
+)
+
+func TestRouteActions(t *testing.T) {
+	workflows.SetRoutes(workflows.Routes{Activities: map[string]string{"ChargePayment": "payments"}})
+	t.Cleanup(func() { workflows.SetRoutes(workflows.Routes{}) })
+
+	newResponse := func() *protos.OrchestratorResponse {
+		return &protos.OrchestratorResponse{Actions: []*protos.OrchestratorAction{{
+			Router: &protos.TaskRouter{SourceAppID: "orders"},
+			OrchestratorActionType: &protos.OrchestratorAction_ScheduleTask{
+				ScheduleTask: &protos.ScheduleTaskAction{Name: "ChargePayment"},
+			},
+		}}}
+	}
+	for _, tc := range []struct {
+		method string
+		want   string
+	}{
+		{method: protos.TaskHubSidecarService_CompleteOrchestratorTask_FullMethodName, want: "payments"},
+		// Only orchestrator responses are routed.
+		{method: protos.TaskHubSidecarService_CompleteActivityTask_FullMethodName},
+	} {
+		req := newResponse()
+		invoked := false
+		err := routeActions(context.Background(), tc.method, req, nil, nil,
+			func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+				invoked = true
+				return nil
+			})
+		if err != nil || !invoked {
+			t.Fatalf("%s: %v, invoked %v", tc.method, err, invoked)
+		}
+		if got := req.GetActions()[0].GetRouter().GetTargetAppID(); got != tc.want {
+			t.Errorf("%s: routed to %q, want %q", tc.method, got, tc.want)
+		}
+	}
+}
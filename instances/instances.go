@@ -0,0 +1,50 @@
+// Package instances holds helpers for the components that walk every workflow instance
+// through the durabletask client, such as retention and the watchdog.
+package instances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	dtapi "github.com/dapr/durabletask-go/api"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// ForEach calls f with the ID of every instance reachable through client, listing them
+// pageSize at a time, and stops at the first error f returns. Instances created or purged
+// while paging can shift later pages, so a walk may miss or repeat some of them.
+func ForEach(ctx context.Context, client *workflow.Client, pageSize uint32, f func(id string) error) error {
+	var token *string
+	for {
+		opts := []workflow.ListInstanceIDsOptions{workflow.WithListInstanceIDsPageSize(pageSize)}
+		if token != nil {
+			opts = append(opts, workflow.WithListInstanceIDsContinuationToken(*token))
+		}
+		resp, err := client.ListInstanceIDs(ctx, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to list workflows: %w", err)
+		}
+		for _, id := range resp.InstanceIds {
+			if err := f(id); err != nil {
+				return err
+			}
+		}
+		if resp.ContinuationToken == nil || *resp.ContinuationToken == "" {
+			return nil
+		}
+		token = resp.ContinuationToken
+	}
+}
+
+// IsNotFound reports whether err means the instance does not exist, either as reported by
+// the durabletask client or as a NotFound status from the sidecar.
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, dtapi.ErrInstanceNotFound) || status.Code(err) == codes.NotFound
+}
@@ -0,0 +1,72 @@
+package instances
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	dtapi "github.com/dapr/durabletask-go/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+func TestForEach(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	var want []string
+	for i := range 7 {
+		id := fmt.Sprintf("order-%d", i)
+		sidecar.Put(daprtest.Instance{ID: id})
+		want = append(want, id)
+	}
+
+	// Every page is walked, including the last, partial one.
+	var got []string
+	err := ForEach(context.Background(), sidecar.Client(), 3, func(id string) error {
+		got = append(got, id)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %v, %v, want %v", got, err, want)
+	}
+
+	// The walk stops at the first error of f.
+	stop := errors.New("stop")
+	got = nil
+	err = ForEach(context.Background(), sidecar.Client(), 3, func(id string) error {
+		got = append(got, id)
+		if len(got) == 4 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || len(got) != 4 {
+		t.Errorf("walked %v, %v, want to stop at the 4th instance", got, err)
+	}
+
+	sidecar.Fail("ListInstanceIDs", status.Error(codes.Unavailable, "state store down"))
+	if err := ForEach(context.Background(), sidecar.Client(), 3, func(string) error { return nil }); status.Code(errors.Unwrap(err)) != codes.Unavailable {
+		t.Errorf("listing failed: %v, want the listing error", err)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil},
+		{err: dtapi.ErrInstanceNotFound, want: true},
+		{err: fmt.Errorf("fetch: %w", dtapi.ErrInstanceNotFound), want: true},
+		{err: status.Error(codes.NotFound, "instance not found"), want: true},
+		{err: status.Error(codes.Unavailable, "sidecar down")},
+		{err: errors.New("not found")},
+	} {
+		if got := IsNotFound(tc.err); got != tc.want {
+			t.Errorf("IsNotFound(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
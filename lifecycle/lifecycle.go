@@ -0,0 +1,141 @@
+// Package lifecycle publishes workflow lifecycle notifications as CloudEvents.
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/dapr/go-sdk/client"
+)
+
+// CloudEvent types emitted for workflow instances.
+const (
+	TypeStarted     = "workflow.started"
+	TypeCompleted   = "workflow.completed"
+	TypeFailed      = "workflow.failed"
+	TypeEventRaised = "workflow.event_raised"
+)
+
+// Source is the CloudEvent source of every lifecycle event.
+const Source = "dapr-go-samples/workflows"
+
+// Data is the payload of a lifecycle event.
+type Data struct {
+	InstanceID string `json:"instanceId"`
+	Workflow   string `json:"workflow,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Event      string `json:"event,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Event is a CloudEvents 1.0 envelope in structured JSON mode.
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	TraceParent     string    `json:"traceparent,omitempty"`
+	TraceState      string    `json:"tracestate,omitempty"`
+	Data            Data      `json:"data"`
+}
+
+// New returns an event of the given type whose subject is the instance ID.
+func New(typ string, data Data) Event {
+	return Event{
+		SpecVersion:     "1.0",
+		ID:              uuid.NewString(),
+		Source:          Source,
+		Type:            typ,
+		Subject:         data.InstanceID,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// WithTraceContext copies the W3C trace context of ctx onto the event.
+func (e Event) WithTraceContext(ctx context.Context) Event {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	e.TraceParent = carrier.Get("traceparent")
+	e.TraceState = carrier.Get("tracestate")
+	return e
+}
+
+// Sink delivers lifecycle events.
+type Sink interface {
+	Publish(ctx context.Context, ev Event) error
+}
+
+// DaprSink publishes events to a Dapr pub/sub topic. The event is sent as a structured
+// CloudEvent so the sidecar forwards it without wrapping it in a second envelope.
+type DaprSink struct {
+	Client client.Client
+	PubSub string
+	Topic  string
+}
+
+func (s *DaprSink) Publish(ctx context.Context, ev Event) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("encode lifecycle event: %w", err)
+	}
+	return s.Client.PublishEvent(ctx, s.PubSub, s.Topic, b,
+		client.PublishEventWithContentType("application/cloudevents+json"))
+}
+
+// MemorySink keeps published events in memory so they can be inspected.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *MemorySink) Publish(_ context.Context, ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ev)
+	return nil
+}
+
+// Events returns a copy of the events published so far.
+func (s *MemorySink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+type sinkHolder struct {
+	sink Sink
+}
+
+var current atomic.Pointer[sinkHolder]
+
+// SetSink installs the process-wide sink. A nil sink disables publishing.
+func SetSink(s Sink) {
+	current.Store(&sinkHolder{sink: s})
+}
+
+// Enabled reports whether a sink is installed.
+func Enabled() bool {
+	h := current.Load()
+	return h != nil && h.sink != nil
+}
+
+// Publish sends ev to the installed sink. It is a no-op when publishing is disabled.
+func Publish(ctx context.Context, ev Event) error {
+	h := current.Load()
+	if h == nil || h.sink == nil {
+		return nil
+	}
+	return h.sink.Publish(ctx, ev)
+}
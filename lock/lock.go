@@ -0,0 +1,158 @@
+// Package lock provides leases on named resources, so that workflow instances can exclude
+// each other from critical sections. Leases expire unless renewed, so a lock whose owner
+// went away is not held forever.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dapr/go-sdk/client"
+)
+
+// ErrNotHeld is returned when renewing or releasing a lock that the owner does not hold,
+// because its lease expired or it never acquired it.
+var ErrNotHeld = errors.New("lock is not held by this owner")
+
+// ErrRenewUnsupported is returned by Renew when the store cannot extend a lease. The TTL
+// given to TryLock must then cover the whole critical section.
+var ErrRenewUnsupported = errors.New("lock store cannot renew leases")
+
+// ErrNotConfigured is returned by Current when no store is installed.
+var ErrNotConfigured = errors.New("no lock store is configured")
+
+// Store grants leases on resources to owners.
+type Store interface {
+	// TryLock acquires resource for owner for ttl. It reports false, without waiting, when
+	// another owner holds it. Stores that know who holds a lock extend a lease owner already
+	// holds to ttl, so that an acquire retried after its result was lost still succeeds;
+	// with the others, the retry waits for that lease to expire.
+	TryLock(ctx context.Context, resource, owner string, ttl time.Duration) (bool, error)
+	// Renew extends the lease of owner on resource to ttl from now, or returns
+	// ErrRenewUnsupported.
+	Renew(ctx context.Context, resource, owner string, ttl time.Duration) error
+	Unlock(ctx context.Context, resource, owner string) error
+}
+
+// DaprStore leases resources through a Dapr lock component.
+type DaprStore struct {
+	Client client.Client
+	Name   string
+}
+
+// TryLock acquires the lock. Dapr does not tell who holds a lock, so a lock owner already
+// holds is reported taken.
+func (s *DaprStore) TryLock(ctx context.Context, resource, owner string, ttl time.Duration) (bool, error) {
+	resp, err := s.Client.TryLockAlpha1(ctx, s.Name, &client.LockRequest{
+		ResourceID:      resource,
+		LockOwner:       owner,
+		ExpiryInSeconds: seconds(ttl),
+	})
+	if err != nil {
+		return false, fmt.Errorf("lock %s: %w", resource, err)
+	}
+	return resp.Success, nil
+}
+
+// Renew returns ErrRenewUnsupported: Dapr cannot extend a lease, and releasing and taking
+// the lock again would let another owner in between.
+func (s *DaprStore) Renew(_ context.Context, resource, _ string, _ time.Duration) error {
+	return fmt.Errorf("renew lock %s: %w", resource, ErrRenewUnsupported)
+}
+
+func (s *DaprStore) Unlock(ctx context.Context, resource, owner string) error {
+	resp, err := s.Client.UnlockAlpha1(ctx, s.Name, &client.UnlockRequest{ResourceID: resource, LockOwner: owner})
+	if err != nil {
+		return fmt.Errorf("unlock %s: %w", resource, err)
+	}
+	switch resp.Status {
+	case "SUCCESS":
+		return nil
+	case "LOCK_DOES_NOT_EXIST", "LOCK_BELONGS_TO_OTHERS":
+		return fmt.Errorf("unlock %s: %w", resource, ErrNotHeld)
+	}
+	return fmt.Errorf("unlock %s: %s", resource, resp.Status)
+}
+
+// seconds rounds ttl up to whole seconds, the resolution of Dapr leases.
+func seconds(ttl time.Duration) int32 {
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+// MemoryStore keeps leases in memory, for local runs and tests.
+type MemoryStore struct {
+	mu     sync.Mutex
+	leases map[string]lease
+	now    func() time.Time
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemoryStore returns a MemoryStore without leases.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{leases: make(map[string]lease), now: time.Now}
+}
+
+func (s *MemoryStore) TryLock(_ context.Context, resource, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if l, ok := s.leases[resource]; ok && l.owner != owner && now.Before(l.expires) {
+		return false, nil
+	}
+	s.leases[resource] = lease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) Renew(_ context.Context, resource, owner string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if !s.held(resource, owner, now) {
+		return fmt.Errorf("renew lock %s: %w", resource, ErrNotHeld)
+	}
+	s.leases[resource] = lease{owner: owner, expires: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Unlock(_ context.Context, resource, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.held(resource, owner, s.now()) {
+		return fmt.Errorf("unlock %s: %w", resource, ErrNotHeld)
+	}
+	delete(s.leases, resource)
+	return nil
+}
+
+func (s *MemoryStore) held(resource, owner string, now time.Time) bool {
+	l, ok := s.leases[resource]
+	return ok && l.owner == owner && now.Before(l.expires)
+}
+
+type storeHolder struct {
+	store Store
+}
+
+var current atomic.Pointer[storeHolder]
+
+// SetStore installs the process-wide store. A nil store disables the lock activities.
+func SetStore(s Store) {
+	current.Store(&storeHolder{store: s})
+}
+
+// Current returns the installed store, or ErrNotConfigured.
+func Current() (Store, error) {
+	h := current.Load()
+	if h == nil || h.store == nil {
+		return nil, ErrNotConfigured
+	}
+	return h.store, nil
+}
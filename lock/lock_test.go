@@ -0,0 +1,190 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dapr/go-sdk/client"
+)
+
+// newTestStore returns a MemoryStore whose clock only moves with the returned advance.
+func newTestStore() (*MemoryStore, func(time.Duration)) {
+	s := NewMemoryStore()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+func tryLock(t *testing.T, s Store, owner string, ttl time.Duration) bool {
+	t.Helper()
+	ok, err := s.TryLock(context.Background(), "res", owner, ttl)
+	if err != nil {
+		t.Fatalf("TryLock(%s): %v", owner, err)
+	}
+	return ok
+}
+
+func TestMemoryStoreTryLock(t *testing.T) {
+	s, advance := newTestStore()
+
+	if !tryLock(t, s, "a", 10*time.Second) {
+		t.Fatal("a did not get a free lock")
+	}
+	if tryLock(t, s, "b", 10*time.Second) {
+		t.Error("b got the lock held by a")
+	}
+
+	// The owner gets its own lock again, with a fresh lease.
+	advance(8 * time.Second)
+	if !tryLock(t, s, "a", 10*time.Second) {
+		t.Error("a did not get the lock it holds")
+	}
+	advance(8 * time.Second)
+	if tryLock(t, s, "b", 10*time.Second) {
+		t.Error("b got the lock within the lease a took again")
+	}
+
+	// Leases expire.
+	advance(2 * time.Second)
+	if !tryLock(t, s, "b", 10*time.Second) {
+		t.Error("b did not get the lock after the lease of a expired")
+	}
+	if tryLock(t, s, "a", 10*time.Second) {
+		t.Error("a got the lock b took over")
+	}
+}
+
+func TestMemoryStoreRenew(t *testing.T) {
+	ctx := context.Background()
+	s, advance := newTestStore()
+	tryLock(t, s, "a", 10*time.Second)
+
+	if err := s.Renew(ctx, "res", "b", 10*time.Second); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("renew by another owner: %v, want ErrNotHeld", err)
+	}
+
+	advance(8 * time.Second)
+	if err := s.Renew(ctx, "res", "a", 10*time.Second); err != nil {
+		t.Fatalf("renew by the owner: %v", err)
+	}
+	advance(8 * time.Second)
+	if tryLock(t, s, "b", 10*time.Second) {
+		t.Error("b got the lock within the renewed lease")
+	}
+
+	advance(2 * time.Second)
+	if err := s.Renew(ctx, "res", "a", 10*time.Second); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("renew after the lease expired: %v, want ErrNotHeld", err)
+	}
+	if err := s.Renew(ctx, "other", "a", 10*time.Second); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("renew of a lock never taken: %v, want ErrNotHeld", err)
+	}
+}
+
+func TestMemoryStoreUnlock(t *testing.T) {
+	ctx := context.Background()
+	s, advance := newTestStore()
+	tryLock(t, s, "a", 10*time.Second)
+
+	if err := s.Unlock(ctx, "res", "b"); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("unlock by another owner: %v, want ErrNotHeld", err)
+	}
+	if err := s.Unlock(ctx, "res", "a"); err != nil {
+		t.Fatalf("unlock by the owner: %v", err)
+	}
+	if err := s.Unlock(ctx, "res", "a"); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("second unlock: %v, want ErrNotHeld", err)
+	}
+	if !tryLock(t, s, "b", 10*time.Second) {
+		t.Fatal("b did not get the released lock")
+	}
+
+	advance(10 * time.Second)
+	if err := s.Unlock(ctx, "res", "b"); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("unlock after the lease expired: %v, want ErrNotHeld", err)
+	}
+}
+
+// fakeLockClient is a Dapr lock component that records the calls it gets.
+type fakeLockClient struct {
+	client.Client
+	owners map[string]string
+	calls  []string
+}
+
+func (c *fakeLockClient) TryLockAlpha1(_ context.Context, _ string, req *client.LockRequest) (*client.LockResponse, error) {
+	c.calls = append(c.calls, "TryLock "+req.LockOwner)
+	if _, ok := c.owners[req.ResourceID]; ok {
+		return &client.LockResponse{}, nil
+	}
+	c.owners[req.ResourceID] = req.LockOwner
+	return &client.LockResponse{Success: true}, nil
+}
+
+func (c *fakeLockClient) UnlockAlpha1(_ context.Context, _ string, req *client.UnlockRequest) (*client.UnlockResponse, error) {
+	c.calls = append(c.calls, "Unlock "+req.LockOwner)
+	switch owner, ok := c.owners[req.ResourceID]; {
+	case !ok:
+		return &client.UnlockResponse{Status: "LOCK_DOES_NOT_EXIST"}, nil
+	case owner != req.LockOwner:
+		return &client.UnlockResponse{Status: "LOCK_BELONGS_TO_OTHERS"}, nil
+	}
+	delete(c.owners, req.ResourceID)
+	return &client.UnlockResponse{Status: "SUCCESS"}, nil
+}
+
+// TestDaprStore checks that the lock is never released on behalf of an owner that did not
+// ask to, which would let another owner in.
+func TestDaprStore(t *testing.T) {
+	ctx := context.Background()
+	c := &fakeLockClient{owners: make(map[string]string)}
+	s := &DaprStore{Client: c, Name: "lockstore"}
+
+	if !tryLock(t, s, "a", time.Minute) {
+		t.Fatal("a did not get a free lock")
+	}
+	if tryLock(t, s, "b", time.Minute) {
+		t.Error("b got the lock held by a")
+	}
+	// Dapr does not tell who holds a lock, so a sees it taken too.
+	if tryLock(t, s, "a", time.Minute) {
+		t.Error("a got the lock it holds again")
+	}
+	if err := s.Renew(ctx, "res", "a", time.Minute); !errors.Is(err, ErrRenewUnsupported) {
+		t.Errorf("renew: %v, want ErrRenewUnsupported", err)
+	}
+	if want := []string{"TryLock a", "TryLock b", "TryLock a"}; !reflect.DeepEqual(c.calls, want) {
+		t.Errorf("calls %v, want %v", c.calls, want)
+	}
+	if c.owners["res"] != "a" {
+		t.Fatalf("lock is held by %q, want a", c.owners["res"])
+	}
+
+	if err := s.Unlock(ctx, "res", "b"); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("unlock by another owner: %v, want ErrNotHeld", err)
+	}
+	if err := s.Unlock(ctx, "res", "a"); err != nil {
+		t.Fatalf("unlock by the owner: %v", err)
+	}
+	if err := s.Unlock(ctx, "res", "a"); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("second unlock: %v, want ErrNotHeld", err)
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	for _, tc := range []struct {
+		ttl  time.Duration
+		want int32
+	}{
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+	} {
+		if got := seconds(tc.ttl); got != tc.want {
+			t.Errorf("seconds(%s) = %d, want %d", tc.ttl, got, tc.want)
+		}
+	}
+}
@@ -2,24 +2,66 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+
 	"github.com/javier-aliaga/dapr-go-samples/api"
+	"github.com/javier-aliaga/dapr-go-samples/cli"
+	"github.com/javier-aliaga/dapr-go-samples/config"
 	"github.com/javier-aliaga/dapr-go-samples/dapr"
+	"github.com/javier-aliaga/dapr-go-samples/retention"
 	"github.com/javier-aliaga/dapr-go-samples/telemetry"
+	"github.com/javier-aliaga/dapr-go-samples/watchdog"
 )
 
 func main() {
+	args := os.Args[1:]
+	// Without a command, or with flags only, the binary runs the app as it always did.
+	switch {
+	case len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help":
+		serve(args)
+	case args[0] == "server":
+		serve(args[1:])
+	default:
+		os.Exit(cli.Run(context.Background(), filepath.Base(os.Args[0]), args, os.Stdout, os.Stderr))
+	}
+}
+
+// serve runs the workflow app: the workflow runtime and the HTTP and gRPC APIs. Workers
+// serve the liveness probe only.
+func serve(args []string) {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to the YAML configuration file")
+	role := fs.String("role", "", "role of this app: all, orchestrator or worker (overrides the config file)")
+	_ = fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	if *role != "" {
+		cfg.Role = *role
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("invalid role: %v", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Start Dapr Workflow runtime (separate goroutine)
-	workflowRuntime, err := dapr.StartWorkflowRuntime(ctx)
+	workflowRuntime, err := dapr.StartWorkflowRuntime(ctx, dapr.WithConfig(cfg))
 	if err != nil {
 		log.Fatalf("failed to start workflow runtime: %v", err)
 	}
@@ -33,9 +75,21 @@ func main() {
 		_ = shutdownFn(ctx)
 	}()
 
-	// Setup HTTP routes
 	mux := http.NewServeMux()
-	api.RegisterRoutes(mux, workflowRuntime)
+	var grpcSrv *grpc.Server
+	if cfg.Role == config.RoleWorker {
+		// Workers only run activities for orchestrators in other apps, so they serve the
+		// probe and none of the API.
+		api.RegisterHealth(mux)
+	} else {
+		svc, err := newService(ctx, cfg, workflowRuntime)
+		if err != nil {
+			log.Fatalf("failed to configure the API: %v", err)
+		}
+		api.RegisterRoutes(mux, svc)
+		// The gRPC API serves the same operations as the HTTP one
+		grpcSrv = api.NewGRPCServer(svc)
+	}
 
 	srv := &http.Server{
 		Addr:         ":8080",
@@ -52,6 +106,19 @@ func main() {
 		}
 	}()
 
+	if grpcSrv != nil && cfg.API.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", cfg.API.GRPCAddr)
+		if err != nil {
+			log.Fatalf("failed to listen on %s: %v", cfg.API.GRPCAddr, err)
+		}
+		go func() {
+			log.Printf("gRPC server listening on %s", cfg.API.GRPCAddr)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatalf("grpc server error: %v", err)
+			}
+		}()
+	}
+
 	// Wait for signal
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -64,4 +131,59 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("server shutdown error: %v", err)
 	}
+
+	if grpcSrv == nil {
+		return
+	}
+	// Watch streams only end with their workflow, so do not wait for them past the deadline.
+	stopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		grpcSrv.Stop()
+	}
+}
+
+// newService configures the API of an orchestrator, along with the retention manager and
+// the watchdog it reports on. Retention and the watchdog run next to the orchestrator, so
+// split deployments do not act twice.
+func newService(ctx context.Context, cfg *config.Config, workflowRuntime *dapr.WorkflowRuntime) (*api.Service, error) {
+	authn, err := api.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("configure authentication: %w", err)
+	}
+	if authn == nil {
+		log.Println("authentication is not configured, the API is open to anyone who can reach it")
+	}
+
+	appToken := os.Getenv(api.AppTokenEnv)
+	if appToken == "" && len(cfg.Subscriptions) > 0 {
+		log.Printf("%s is not set, the subscription routes accept messages from anyone who can reach them", api.AppTokenEnv)
+	}
+
+	var retentionMgr *retention.Manager
+	if cfg.Retention.Enabled() {
+		retentionMgr = retention.NewManager(workflowRuntime.Client(), cfg.Retention)
+		go retentionMgr.Run(ctx)
+	}
+	var stuckWatchdog *watchdog.Watchdog
+	if cfg.Watchdog.Enabled() {
+		stuckWatchdog = watchdog.New(workflowRuntime.Client(), cfg.Watchdog)
+		go stuckWatchdog.Run(ctx)
+	}
+
+	return api.NewService(workflowRuntime,
+		api.WithMaxWatchers(cfg.API.MaxWatchers),
+		api.WithAuthenticator(authn),
+		api.WithRequiredTenant(cfg.Tenancy.Required),
+		api.WithLimits(cfg.Admission),
+		api.WithRetention(retentionMgr),
+		api.WithWatchdog(stuckWatchdog),
+		api.WithSubscriptions(cfg.Subscriptions),
+		api.WithAppToken(appToken),
+	), nil
 }
\ No newline at end of file
@@ -0,0 +1,252 @@
+// Package retention purges workflow instances some time after they finish.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/dapr/kit/logger"
+
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+)
+
+var log = logger.NewLogger("retention")
+
+const (
+	pageSize = 500
+	// workers bounds the instances checked and purged at once.
+	workers = 8
+	// maxReportErrors bounds the errors kept in a report.
+	maxReportErrors = 20
+)
+
+// Report describes one retention run.
+type Report struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DryRun     bool      `json:"dryRun,omitempty"`
+	// Scanned counts the instances listed.
+	Scanned int `json:"scanned"`
+	// Purged counts the instances purged, or that would have been in a dry run, by
+	// workflow name and runtime status.
+	Purged map[string]map[string]int `json:"purged"`
+	Failed int                       `json:"failed"`
+	Errors []string                  `json:"errors,omitempty"`
+	// Error is why the run stopped early, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Total returns the number of instances purged.
+func (r Report) Total() int {
+	n := 0
+	for _, byStatus := range r.Purged {
+		for _, c := range byStatus {
+			n += c
+		}
+	}
+	return n
+}
+
+// Manager periodically purges the instances that finished longer ago than their
+// retention policy allows.
+type Manager struct {
+	client *workflow.Client
+	cfg    config.Retention
+	now    func() time.Time
+
+	mu   sync.Mutex
+	last *Report
+
+	runs     metric.Int64Counter
+	purged   metric.Int64Counter
+	failures metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// NewManager returns a manager applying cfg to the instances reachable through client.
+func NewManager(client *workflow.Client, cfg config.Retention) *Manager {
+	m := &Manager{client: client, cfg: cfg, now: time.Now}
+
+	meter := otel.Meter("retention")
+	m.runs, _ = meter.Int64Counter("retention.runs",
+		metric.WithDescription("Retention runs by outcome"))
+	m.purged, _ = meter.Int64Counter("retention.purged",
+		metric.WithDescription("Instances purged by workflow and runtime status"))
+	m.failures, _ = meter.Int64Counter("retention.failures",
+		metric.WithDescription("Instances that could not be checked or purged"))
+	m.duration, _ = meter.Float64Histogram("retention.run.duration",
+		metric.WithDescription("Duration of retention runs"),
+		metric.WithUnit("s"))
+	return m
+}
+
+// Run purges instances every cfg.Interval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	log.Infof("Retention enabled: every %s, default %+v, %d workflow policies, dry run %t",
+		m.cfg.Interval, m.cfg.Default, len(m.cfg.Workflows), m.cfg.DryRun)
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		m.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastReport returns the report of the latest run, if any.
+func (m *Manager) LastReport() (Report, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return Report{}, false
+	}
+	return *m.last, true
+}
+
+// retentionFor returns how long an instance of the named workflow is kept in the given
+// runtime status, or 0 if it is kept forever.
+func (m *Manager) retentionFor(name, status string) time.Duration {
+	p, ok := m.cfg.Workflows[name]
+	if !ok {
+		p = m.cfg.Default
+	}
+	switch status {
+	case "COMPLETED":
+		return p.Completed
+	case "FAILED":
+		return p.Failed
+	case "TERMINATED":
+		return p.Terminated
+	default:
+		return 0
+	}
+}
+
+// RunOnce checks every instance once and returns the report of the run.
+func (m *Manager) RunOnce(ctx context.Context) Report {
+	r := Report{StartedAt: m.now().UTC(), DryRun: m.cfg.DryRun, Purged: make(map[string]map[string]int)}
+	var mu sync.Mutex
+	fail := func(id string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.Failed++
+		if len(r.Errors) < maxReportErrors {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", id, err))
+		}
+		m.failures.Add(ctx, 1)
+	}
+
+	ids := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				name, status, purged, err := m.apply(ctx, id)
+				if err != nil {
+					fail(id, err)
+					continue
+				}
+				if !purged {
+					continue
+				}
+				mu.Lock()
+				if r.Purged[name] == nil {
+					r.Purged[name] = make(map[string]int)
+				}
+				r.Purged[name][status]++
+				mu.Unlock()
+				if !m.cfg.DryRun {
+					m.purged.Add(ctx, 1, metric.WithAttributes(
+						attribute.String("workflow", name),
+						attribute.String("status", status),
+					))
+				}
+			}
+		}()
+	}
+
+	// Purging while paging can shift later pages; instances skipped that way are picked up
+	// by the next run.
+	err := instances.ForEach(ctx, m.client, pageSize, func(id string) error {
+		r.Scanned++
+		select {
+		case ids <- id:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(ids)
+	wg.Wait()
+
+	r.FinishedAt = m.now().UTC()
+	outcome := "success"
+	if err != nil {
+		r.Error = err.Error()
+		outcome = "error"
+	}
+	m.runs.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	m.duration.Record(ctx, r.FinishedAt.Sub(r.StartedAt).Seconds())
+
+	if err != nil {
+		log.Errorf("Retention run stopped after %d instances: %v", r.Scanned, err)
+	}
+	log.Infof("Retention run: scanned %d, purged %d, failed %d (dry run: %t) %v",
+		r.Scanned, r.Total(), r.Failed, r.DryRun, r.Purged)
+
+	m.mu.Lock()
+	m.last = &r
+	m.mu.Unlock()
+	return r
+}
+
+// apply purges the instance when it finished longer ago than its policy allows. Instances
+// that no longer exist, for instance children purged with their parent, are skipped.
+func (m *Manager) apply(ctx context.Context, id string) (name, status string, purged bool, err error) {
+	meta, err := m.client.FetchWorkflowMetadata(ctx, id)
+	if err != nil {
+		if instances.IsNotFound(err) {
+			return "", "", false, nil
+		}
+		return "", "", false, err
+	}
+	if !workflow.WorkflowMetadataIsComplete(meta) || meta.LastUpdatedAt == nil {
+		return "", "", false, nil
+	}
+
+	name, status = meta.Name, meta.String()
+	keep := m.retentionFor(name, status)
+	if keep == 0 || m.now().Sub(meta.LastUpdatedAt.AsTime()) < keep {
+		return name, status, false, nil
+	}
+	if m.cfg.DryRun {
+		return name, status, true, nil
+	}
+
+	if err := m.client.PurgeWorkflowState(ctx, id, workflow.WithRecursivePurge(true)); err != nil {
+		if instances.IsNotFound(err) {
+			return name, status, false, nil
+		}
+		return name, status, false, fmt.Errorf("purge: %w", err)
+	}
+	if err := callbacks.Current().Log.Delete(ctx, id); err != nil {
+		log.Warnf("Purged workflow %s but kept its callback deliveries: %v", id, err)
+	}
+	return name, status, true, nil
+}
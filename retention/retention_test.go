@@ -0,0 +1,117 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+const (
+	completed  = protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED
+	failed     = protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED
+	terminated = protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED
+	running    = protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING
+)
+
+func TestRunOnce(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := config.Retention{
+		Interval: time.Minute,
+		Default:  config.RetentionPolicy{Completed: time.Hour, Terminated: time.Hour},
+		Workflows: map[string]config.RetentionPolicy{
+			"BatchWorkflow": {Completed: 10 * time.Minute},
+		},
+	}
+	instances := []daprtest.Instance{
+		{ID: "old", Name: "SimpleWorkflow", Status: completed, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "recent", Name: "SimpleWorkflow", Status: completed, UpdatedAt: now.Add(-30 * time.Minute)},
+		// Failed instances have no default retention and are kept forever.
+		{ID: "failed", Name: "SimpleWorkflow", Status: failed, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "terminated", Name: "SimpleWorkflow", Status: terminated, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "running", Name: "SimpleWorkflow", Status: running, UpdatedAt: now.Add(-48 * time.Hour)},
+		// The workflow policy replaces the default as a whole.
+		{ID: "batch", Name: "BatchWorkflow", Status: completed, UpdatedAt: now.Add(-30 * time.Minute)},
+		{ID: "batch-terminated", Name: "BatchWorkflow", Status: terminated, UpdatedAt: now.Add(-48 * time.Hour)},
+	}
+	wantPurged := map[string]map[string]int{
+		"SimpleWorkflow": {"COMPLETED": 1, "TERMINATED": 1},
+		"BatchWorkflow":  {"COMPLETED": 1},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		sidecar := daprtest.Start(t)
+		for _, in := range instances {
+			sidecar.Put(in)
+		}
+		prev := *callbacks.Current()
+		t.Cleanup(func() { callbacks.Configure(prev) })
+		deliveries := callbacks.NewMemoryLog()
+		callbacks.Configure(callbacks.Settings{Log: deliveries})
+		for _, in := range instances {
+			_ = deliveries.Record(context.Background(), callbacks.Delivery{InstanceID: in.ID})
+		}
+
+		cfg.DryRun = dryRun
+		m := NewManager(sidecar.Client(), cfg)
+		m.now = func() time.Time { return now }
+		r := m.RunOnce(context.Background())
+
+		if r.Scanned != len(instances) || r.Failed != 0 || r.DryRun != dryRun || !reflect.DeepEqual(r.Purged, wantPurged) {
+			t.Errorf("dry run %v: report %+v, want %d scanned and purged %v", dryRun, r, len(instances), wantPurged)
+		}
+		if last, ok := m.LastReport(); !ok || !reflect.DeepEqual(last, r) {
+			t.Errorf("dry run %v: last report %+v, want the report of the run", dryRun, last)
+		}
+
+		want := []string{"old", "recent", "failed", "terminated", "running", "batch", "batch-terminated"}
+		if !dryRun {
+			want = []string{"recent", "failed", "running", "batch-terminated"}
+		}
+		if got := sidecar.IDs(); !reflect.DeepEqual(got, want) {
+			t.Errorf("dry run %v: instances left %v, want %v", dryRun, got, want)
+		}
+		// The delivery log goes with the instance.
+		for _, in := range instances {
+			logged, _ := deliveries.List(context.Background(), in.ID)
+			if kept := slices.Contains(want, in.ID); kept != (len(logged) == 1) {
+				t.Errorf("dry run %v: deliveries of %s %+v, want them kept %v", dryRun, in.ID, logged, kept)
+			}
+		}
+	}
+}
+
+func TestRunOnceErrors(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := config.Retention{Interval: time.Minute, Default: config.RetentionPolicy{Completed: time.Hour}}
+	sidecar := daprtest.Start(t)
+	for _, id := range []string{"a", "b"} {
+		sidecar.Put(daprtest.Instance{ID: id, Name: "SimpleWorkflow", Status: completed, UpdatedAt: now.Add(-2 * time.Hour)})
+	}
+	m := NewManager(sidecar.Client(), cfg)
+	m.now = func() time.Time { return now }
+
+	// Instances that cannot be purged are reported, and the run goes on.
+	sidecar.Fail("PurgeInstances", errors.New("state store down"))
+	r := m.RunOnce(context.Background())
+	if r.Scanned != 2 || r.Failed != 2 || len(r.Errors) != 2 || r.Total() != 0 || r.Error != "" {
+		t.Errorf("purge failures: report %+v", r)
+	}
+
+	// A listing that fails stops the run.
+	sidecar.Fail("ListInstanceIDs", errors.New("state store down"))
+	if r := m.RunOnce(context.Background()); r.Error == "" || r.Scanned != 0 {
+		t.Errorf("listing failure: report %+v", r)
+	}
+	if len(sidecar.IDs()) != 2 {
+		t.Errorf("instances left %v, want both", sidecar.IDs())
+	}
+}
@@ -0,0 +1,262 @@
+// Package state keeps business data of workflows in a key/value store. Values are JSON and
+// versioned by ETags, so that concurrent writers do not overwrite each other unknowingly.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/dapr/go-sdk/client"
+)
+
+// ErrETagMismatch is returned when the ETag given to a write is not the stored one: another
+// writer changed the value since it was read.
+var ErrETagMismatch = errors.New("etag mismatch")
+
+// ErrNotConfigured is returned by Current when no store is installed.
+var ErrNotConfigured = errors.New("no state store is configured")
+
+// Item is a stored value. A key that does not exist has no Value and no ETag.
+type Item struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value,omitempty"`
+	ETag  string          `json:"etag,omitempty"`
+}
+
+// Store is a key/value store with ETags. Writes given an empty ETag overwrite whatever is
+// stored; writes given an ETag fail with ErrETagMismatch unless it is the stored one.
+type Store interface {
+	Get(ctx context.Context, key string) (Item, error)
+	// GetBulk returns the items of keys, in order.
+	GetBulk(ctx context.Context, keys []string) ([]Item, error)
+	Save(ctx context.Context, item Item) error
+	// Create saves item only if its key does not exist, and fails with ErrETagMismatch
+	// otherwise. The ETag of item is ignored.
+	Create(ctx context.Context, item Item) error
+	Delete(ctx context.Context, key, etag string) error
+}
+
+// bulkParallelism is how many keys the sidecar reads at once for GetBulk.
+const bulkParallelism = 10
+
+// DaprStore keeps values in a Dapr state store component.
+type DaprStore struct {
+	Client client.Client
+	Name   string
+}
+
+func (s *DaprStore) Get(ctx context.Context, key string) (Item, error) {
+	item, err := s.Client.GetState(ctx, s.Name, key, nil)
+	if err != nil {
+		return Item{}, fmt.Errorf("get state %s: %w", key, err)
+	}
+	return Item{Key: key, Value: value(item.Value), ETag: item.Etag}, nil
+}
+
+func (s *DaprStore) GetBulk(ctx context.Context, keys []string) ([]Item, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	found, err := s.Client.GetBulkState(ctx, s.Name, keys, nil, bulkParallelism)
+	if err != nil {
+		return nil, fmt.Errorf("get bulk state: %w", err)
+	}
+	// The sidecar does not keep the order of keys.
+	byKey := make(map[string]*client.BulkStateItem, len(found))
+	for _, item := range found {
+		if item.Error != "" {
+			return nil, fmt.Errorf("get state %s: %s", item.Key, item.Error)
+		}
+		byKey[item.Key] = item
+	}
+	items := make([]Item, len(keys))
+	for i, key := range keys {
+		items[i] = Item{Key: key}
+		if item, ok := byKey[key]; ok {
+			items[i].Value, items[i].ETag = value(item.Value), item.Etag
+		}
+	}
+	return items, nil
+}
+
+func (s *DaprStore) Save(ctx context.Context, item Item) error {
+	return s.save(ctx, item.Key, item.Value, item.ETag)
+}
+
+// Create saves item with first-write concurrency and no ETag, which state stores only
+// accept for keys that do not exist.
+func (s *DaprStore) Create(ctx context.Context, item Item) error {
+	return s.save(ctx, item.Key, item.Value, "",
+		client.WithConcurrency(client.StateConcurrencyFirstWrite),
+		client.WithConsistency(client.StateConsistencyStrong))
+}
+
+func (s *DaprStore) save(ctx context.Context, key string, value []byte, etag string, opts ...client.StateOption) error {
+	err := s.Client.SaveStateWithETag(ctx, s.Name, key, value, etag, nil, opts...)
+	if err != nil {
+		return fmt.Errorf("save state %s: %w", key, daprError(err))
+	}
+	return nil
+}
+
+func (s *DaprStore) Delete(ctx context.Context, key, etag string) error {
+	var err error
+	if etag == "" {
+		err = s.Client.DeleteState(ctx, s.Name, key, nil)
+	} else {
+		err = s.Client.DeleteStateWithETag(ctx, s.Name, key, &client.ETag{Value: etag}, nil, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("delete state %s: %w", key, daprError(err))
+	}
+	return nil
+}
+
+// value returns the stored bytes as a JSON value, or nil when the key does not exist.
+func value(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	return json.RawMessage(b)
+}
+
+// daprError maps the status the sidecar returns for stale ETags to ErrETagMismatch.
+func daprError(err error) error {
+	if status.Code(err) == codes.Aborted {
+		return fmt.Errorf("%w: %v", ErrETagMismatch, err)
+	}
+	return err
+}
+
+// MemoryStore keeps values in memory, for local runs and tests. ETags count the writes of
+// a key.
+type MemoryStore struct {
+	mu      sync.Mutex
+	items   map[string]Item
+	version int
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]Item)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key), nil
+}
+
+func (s *MemoryStore) GetBulk(_ context.Context, keys []string) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]Item, len(keys))
+	for i, key := range keys {
+		items[i] = s.get(key)
+	}
+	return items, nil
+}
+
+func (s *MemoryStore) get(key string) Item {
+	if item, ok := s.items[key]; ok {
+		item.Value = append(json.RawMessage(nil), item.Value...)
+		return item
+	}
+	return Item{Key: key}
+}
+
+func (s *MemoryStore) Save(_ context.Context, item Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item.ETag != "" && s.items[item.Key].ETag != item.ETag {
+		return fmt.Errorf("save state %s: %w", item.Key, ErrETagMismatch)
+	}
+	s.put(item)
+	return nil
+}
+
+func (s *MemoryStore) Create(_ context.Context, item Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[item.Key]; ok {
+		return fmt.Errorf("save state %s: %w", item.Key, ErrETagMismatch)
+	}
+	s.put(item)
+	return nil
+}
+
+func (s *MemoryStore) put(item Item) {
+	s.version++
+	s.items[item.Key] = Item{
+		Key:   item.Key,
+		Value: append(json.RawMessage(nil), item.Value...),
+		ETag:  strconv.Itoa(s.version),
+	}
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if etag != "" && s.items[key].ETag != etag {
+		return fmt.Errorf("delete state %s: %w", key, ErrETagMismatch)
+	}
+	delete(s.items, key)
+	return nil
+}
+
+// Update reads key, applies fn to it and saves the result with the ETag it read, or creates
+// the key when it did not exist. When another writer saved or created the key in between,
+// it starts over, up to attempts times in all. It returns the value it saved.
+func Update(ctx context.Context, s Store, key string, attempts int, fn func(Item) (json.RawMessage, error)) (json.RawMessage, error) {
+	var err error
+	for range max(attempts, 1) {
+		var item Item
+		if item, err = s.Get(ctx, key); err != nil {
+			return nil, err
+		}
+		var v json.RawMessage
+		if v, err = fn(item); err != nil {
+			return nil, err
+		}
+		if item.Value == nil && item.ETag == "" {
+			err = s.Create(ctx, Item{Key: key, Value: v})
+		} else {
+			err = s.Save(ctx, Item{Key: key, Value: v, ETag: item.ETag})
+		}
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, ErrETagMismatch) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("gave up after %d attempts: %w", max(attempts, 1), err)
+}
+
+type storeHolder struct {
+	store Store
+}
+
+var current atomic.Pointer[storeHolder]
+
+// SetStore installs the process-wide store. A nil store disables the state activities.
+func SetStore(s Store) {
+	current.Store(&storeHolder{store: s})
+}
+
+// Current returns the installed store, or ErrNotConfigured.
+func Current() (Store, error) {
+	h := current.Load()
+	if h == nil || h.store == nil {
+		return nil, ErrNotConfigured
+	}
+	return h.store, nil
+}
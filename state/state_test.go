@@ -0,0 +1,226 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	item, err := s.Get(ctx, "k")
+	if err != nil || item.Value != nil || item.ETag != "" {
+		t.Fatalf("missing key = %+v, %v; want no value and no etag", item, err)
+	}
+
+	if err := s.Save(ctx, Item{Key: "k", Value: json.RawMessage(`1`)}); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.Get(ctx, "k")
+	if string(first.Value) != "1" || first.ETag == "" {
+		t.Fatalf("saved item = %+v", first)
+	}
+	// Values are copies: changing one does not change the store.
+	first.Value[0] = '9'
+	if again, _ := s.Get(ctx, "k"); string(again.Value) != "1" {
+		t.Errorf("stored value changed to %s through a read", again.Value)
+	}
+
+	if err := s.Save(ctx, Item{Key: "k", Value: json.RawMessage(`2`), ETag: first.ETag}); err != nil {
+		t.Fatalf("save with the stored etag: %v", err)
+	}
+	second, _ := s.Get(ctx, "k")
+	if second.ETag == first.ETag {
+		t.Errorf("etag %s did not change on save", second.ETag)
+	}
+	if err := s.Save(ctx, Item{Key: "k", Value: json.RawMessage(`3`), ETag: first.ETag}); !errors.Is(err, ErrETagMismatch) {
+		t.Errorf("save with a stale etag: %v, want ErrETagMismatch", err)
+	}
+	if err := s.Save(ctx, Item{Key: "k", Value: json.RawMessage(`4`)}); err != nil {
+		t.Errorf("save without an etag: %v", err)
+	}
+
+	if err := s.Create(ctx, Item{Key: "k", Value: json.RawMessage(`5`)}); !errors.Is(err, ErrETagMismatch) {
+		t.Errorf("create of an existing key: %v, want ErrETagMismatch", err)
+	}
+	if err := s.Create(ctx, Item{Key: "new", Value: json.RawMessage(`6`), ETag: "ignored"}); err != nil {
+		t.Errorf("create of a new key: %v", err)
+	}
+
+	items, err := s.GetBulk(ctx, []string{"new", "missing", "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Key+"="+string(item.Value))
+	}
+	if want := "new=6 missing= k=4"; strings.Join(got, " ") != want {
+		t.Errorf("GetBulk = %v, want %s", got, want)
+	}
+
+	current, _ := s.Get(ctx, "k")
+	if err := s.Delete(ctx, "k", first.ETag); !errors.Is(err, ErrETagMismatch) {
+		t.Errorf("delete with a stale etag: %v, want ErrETagMismatch", err)
+	}
+	if err := s.Delete(ctx, "k", current.ETag); err != nil {
+		t.Errorf("delete with the stored etag: %v", err)
+	}
+	if err := s.Delete(ctx, "new", ""); err != nil {
+		t.Errorf("delete without an etag: %v", err)
+	}
+	if items, _ := s.GetBulk(ctx, []string{"k", "new"}); items[0].Value != nil || items[1].Value != nil {
+		t.Errorf("deleted keys still hold %+v", items)
+	}
+}
+
+// racingStore lets another writer write the key right before each of the first writes of
+// the store.
+type racingStore struct {
+	*MemoryStore
+	races int
+	other func()
+}
+
+func (s *racingStore) race() {
+	if s.races > 0 {
+		s.races--
+		s.other()
+	}
+}
+
+func (s *racingStore) Save(ctx context.Context, item Item) error {
+	s.race()
+	return s.MemoryStore.Save(ctx, item)
+}
+
+func (s *racingStore) Create(ctx context.Context, item Item) error {
+	s.race()
+	return s.MemoryStore.Create(ctx, item)
+}
+
+// increment is an Update function adding one to a counter.
+func increment(item Item) (json.RawMessage, error) {
+	n := 0
+	if item.Value != nil {
+		if err := json.Unmarshal(item.Value, &n); err != nil {
+			return nil, err
+		}
+	}
+	return json.RawMessage(strconv.Itoa(n + 1)), nil
+}
+
+func TestUpdateRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name    string
+		initial string
+	}{
+		// The other writer creates the key between the read that found none and the create,
+		// then saves it between the next read and save.
+		{name: "new key"},
+		// The other writer saves the key between each read and save.
+		{name: "existing key", initial: "10"},
+	} {
+		mem := NewMemoryStore()
+		base := 0
+		if tc.initial != "" {
+			_ = mem.Save(ctx, Item{Key: "n", Value: json.RawMessage(tc.initial)})
+			base, _ = strconv.Atoi(tc.initial)
+		}
+		s := &racingStore{MemoryStore: mem, races: 2}
+		s.other = func() {
+			if _, err := Update(ctx, mem, "n", 1, increment); err != nil {
+				t.Errorf("%s: other writer: %v", tc.name, err)
+			}
+		}
+
+		calls := 0
+		v, err := Update(ctx, s, "n", 3, func(item Item) (json.RawMessage, error) {
+			calls++
+			return increment(item)
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		// Two increments by the other writer, then the one of the update.
+		want := base + 3
+		if string(v) != strconv.Itoa(want) {
+			t.Errorf("%s: saved %s, want %d", tc.name, v, want)
+		}
+		if stored, _ := mem.Get(ctx, "n"); string(stored.Value) != string(v) {
+			t.Errorf("%s: stored %s, want %s", tc.name, stored.Value, v)
+		}
+		if calls != 3 {
+			t.Errorf("%s: fn called %d times, want 3", tc.name, calls)
+		}
+	}
+}
+
+func TestUpdateGivesUp(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryStore()
+	s := &racingStore{MemoryStore: mem, races: 10}
+	s.other = func() { _ = mem.Save(ctx, Item{Key: "n", Value: json.RawMessage(`0`)}) }
+
+	calls := 0
+	_, err := Update(ctx, s, "n", 3, func(item Item) (json.RawMessage, error) {
+		calls++
+		return increment(item)
+	})
+	if !errors.Is(err, ErrETagMismatch) || !strings.Contains(err.Error(), "gave up after 3 attempts") {
+		t.Errorf("error = %v, want giving up on ErrETagMismatch", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+}
+
+func TestUpdateDoesNotRetryOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	boom := errors.New("boom")
+
+	calls := 0
+	_, err := Update(ctx, s, "n", 5, func(Item) (json.RawMessage, error) {
+		calls++
+		return nil, boom
+	})
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Errorf("error = %v after %d calls, want boom after 1", err, calls)
+	}
+	if item, _ := s.Get(ctx, "n"); item.Value != nil {
+		t.Errorf("saved %s after fn failed", item.Value)
+	}
+}
+
+// TestUpdateConcurrentCreates checks that updates racing to create a key do not lose
+// each other's writes.
+func TestUpdateConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	const writers = 20
+
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Update(ctx, s, "n", writers, increment); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if item, _ := s.Get(ctx, "n"); string(item.Value) != strconv.Itoa(writers) {
+		t.Errorf("counter = %s, want %d", item.Value, writers)
+	}
+}
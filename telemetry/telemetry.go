@@ -2,11 +2,14 @@ package telemetry
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
@@ -37,8 +40,26 @@ func Init(ctx context.Context, serviceName string) (shutdown func(context.Contex
 		sdktrace.WithResource(res),
 	)
 
+	metricExp, err := otlpmetrichttp.New(ctx,
+		otlpmetrichttp.WithTimeout(5*time.Second),
+		otlpmetrichttp.WithEndpoint("otel-collector-opentelemetry-collector.opentelemetry.svc.cluster.local:4318"),
+		otlpmetrichttp.WithInsecure(),
+	)
+	if err != nil {
+		return nil, errors.Join(err, tp.Shutdown(ctx))
+	}
+
+	// Instruments created through otel.Meter before Init are forwarded to this provider.
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp)),
+		sdkmetric.WithResource(res),
+	)
+
 	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
 	otel.SetTextMapPropagator(propagation.TraceContext{})
 
-	return tp.Shutdown, nil
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}, nil
 }
\ No newline at end of file
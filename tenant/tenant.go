@@ -0,0 +1,88 @@
+// Package tenant scopes workflow instances to tenants. A tenant's instances carry the
+// tenant ID as a prefix of their instance ID, so the instance ID alone tells which tenant
+// it belongs to, including for child workflows whose IDs derive from their parent's.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// Separator joins the tenant ID and the tenant-local instance ID.
+const Separator = "~"
+
+// BaggageKey is the W3C baggage member carrying the tenant ID.
+const BaggageKey = "tenant"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Validate checks that id can be used as a tenant ID.
+func Validate(id string) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("invalid tenant ID %q: use up to 64 letters, digits, '-' or '_'", id)
+	}
+	return nil
+}
+
+// InstanceID returns the instance ID of the tenant-local id. An empty tenant leaves id as is.
+func InstanceID(tenant, id string) string {
+	if tenant == "" {
+		return id
+	}
+	return tenant + Separator + id
+}
+
+// LocalID returns the tenant-local part of instanceID and whether instanceID belongs to
+// tenant. Every instance belongs to the empty tenant.
+func LocalID(tenant, instanceID string) (string, bool) {
+	if tenant == "" {
+		return instanceID, true
+	}
+	return strings.CutPrefix(instanceID, tenant+Separator)
+}
+
+// FromInstanceID returns the tenant an instance ID is scoped to, or "" if it is not scoped.
+func FromInstanceID(instanceID string) string {
+	t, _, ok := strings.Cut(instanceID, Separator)
+	if !ok || Validate(t) != nil {
+		return ""
+	}
+	return t
+}
+
+type contextKey struct{}
+
+// WithContext returns a context scoped to tenant. The tenant is also added to the
+// context's baggage so it follows outgoing calls.
+func WithContext(ctx context.Context, tenant string) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, tenant)
+	return WithBaggage(ctx, tenant)
+}
+
+// FromContext returns the tenant the context is scoped to, or "".
+func FromContext(ctx context.Context) string {
+	t, _ := ctx.Value(contextKey{}).(string)
+	return t
+}
+
+// WithBaggage adds the tenant to the baggage of ctx.
+func WithBaggage(ctx context.Context, tenant string) context.Context {
+	member, err := baggage.NewMember(BaggageKey, tenant)
+	if err != nil {
+		return ctx
+	}
+	b, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// FromBaggage returns the tenant carried in the baggage of ctx, or "".
+func FromBaggage(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(BaggageKey).Value()
+}
@@ -0,0 +1,67 @@
+package tenant
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstanceIDs(t *testing.T) {
+	for _, tc := range []struct {
+		tenant, id, instanceID string
+	}{
+		{tenant: "acme", id: "order-1", instanceID: "acme~order-1"},
+		{tenant: "", id: "order-1", instanceID: "order-1"},
+		{tenant: "", id: "acme~order-1", instanceID: "acme~order-1"},
+	} {
+		if got := InstanceID(tc.tenant, tc.id); got != tc.instanceID {
+			t.Errorf("InstanceID(%q, %q) = %q, want %q", tc.tenant, tc.id, got, tc.instanceID)
+		}
+		if got, ok := LocalID(tc.tenant, tc.instanceID); !ok || got != tc.id {
+			t.Errorf("LocalID(%q, %q) = %q, %v, want %q", tc.tenant, tc.instanceID, got, ok, tc.id)
+		}
+	}
+
+	for _, tc := range []struct {
+		tenant, instanceID string
+	}{
+		{tenant: "acme", instanceID: "globex~order-1"},
+		{tenant: "acme", instanceID: "order-1"},
+		// A tenant whose ID prefixes another's does not see its instances.
+		{tenant: "acme", instanceID: "acme-corp~order-1"},
+	} {
+		if got, ok := LocalID(tc.tenant, tc.instanceID); ok {
+			t.Errorf("LocalID(%q, %q) = %q, want no match", tc.tenant, tc.instanceID, got)
+		}
+	}
+
+	for instanceID, want := range map[string]string{
+		"acme~order-1":      "acme",
+		"order-1":           "",
+		"not valid~1":       "",
+		"acme~order~1":      "acme",
+		"~order-1":          "",
+		"globex~":           "globex",
+		"acme-corp~order-1": "acme-corp",
+	} {
+		if got := FromInstanceID(instanceID); got != want {
+			t.Errorf("FromInstanceID(%q) = %q, want %q", instanceID, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"acme":                  true,
+		"acme_corp-1":           true,
+		"":                      false,
+		"-acme":                 false,
+		"acme~corp":             false,
+		"acme corp":             false,
+		strings.Repeat("a", 64): true,
+		strings.Repeat("a", 65): false,
+	} {
+		if err := Validate(id); (err == nil) != valid {
+			t.Errorf("Validate(%q) = %v, want valid %v", id, err, valid)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Package watchdog reports running workflow instances that stopped making progress, and
+// optionally terminates or reruns them.
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/google/uuid"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/dapr/kit/logger"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/instances"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+var log = logger.NewLogger("watchdog")
+
+const (
+	pageSize = 500
+	// workers bounds the instances checked at once.
+	workers = 8
+	// defaultMaxActions bounds the actions taken by one scan unless configured.
+	defaultMaxActions = 10
+	// terminateTimeout bounds the wait for a terminated instance to stop before a rerun.
+	terminateTimeout = 30 * time.Second
+)
+
+// Instance is a stuck instance.
+type Instance struct {
+	InstanceID    string    `json:"instanceId"`
+	Workflow      string    `json:"workflow"`
+	RuntimeStatus string    `json:"runtimeStatus"`
+	LastUpdatedAt time.Time `json:"lastUpdatedAt"`
+	// StuckFor is how long the instance has gone without an update, in seconds.
+	StuckFor float64 `json:"stuckForSeconds"`
+	SLA      string  `json:"sla"`
+	// Action is the action taken on the instance, if any.
+	Action      string `json:"action,omitempty"`
+	ActionError string `json:"actionError,omitempty"`
+	// RerunID is the instance started by a rerun.
+	RerunID string `json:"rerunInstanceId,omitempty"`
+}
+
+// Scan is the result of one scan.
+type Scan struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Scanned counts the instances listed.
+	Scanned int `json:"scanned"`
+	// Stuck holds the stuck instances, the longest stuck first.
+	Stuck []Instance `json:"stuck"`
+	// Error is why the scan stopped early, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Watchdog periodically scans running instances for ones that have not been updated within
+// the SLA of their workflow.
+type Watchdog struct {
+	client *workflow.Client
+	cfg    config.Watchdog
+	now    func() time.Time
+
+	mu   sync.Mutex
+	last *Scan
+
+	scans   metric.Int64Counter
+	actions metric.Int64Counter
+}
+
+// New returns a watchdog applying cfg to the instances reachable through client.
+func New(client *workflow.Client, cfg config.Watchdog) *Watchdog {
+	if cfg.MaxActionsPerScan == 0 {
+		cfg.MaxActionsPerScan = defaultMaxActions
+	}
+	w := &Watchdog{client: client, cfg: cfg, now: time.Now}
+
+	meter := otel.Meter("watchdog")
+	w.scans, _ = meter.Int64Counter("watchdog.scans",
+		metric.WithDescription("Watchdog scans by outcome"))
+	w.actions, _ = meter.Int64Counter("watchdog.actions",
+		metric.WithDescription("Actions taken on stuck instances by workflow, action and outcome"))
+	_, _ = meter.Int64ObservableGauge("watchdog.stuck",
+		metric.WithDescription("Stuck instances found by the latest scan, by workflow"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			scan, ok := w.LastScan()
+			if !ok {
+				return nil
+			}
+			counts := make(map[string]int64)
+			for _, in := range scan.Stuck {
+				counts[in.Workflow]++
+			}
+			for name, n := range counts {
+				o.Observe(n, metric.WithAttributes(attribute.String("workflow", name)))
+			}
+			return nil
+		}))
+	return w
+}
+
+// Run scans every cfg.Interval until ctx is done.
+func (w *Watchdog) Run(ctx context.Context) {
+	log.Infof("Watchdog enabled: every %s, default %+v, %d workflow SLAs",
+		w.cfg.Interval, w.cfg.Default, len(w.cfg.Workflows))
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		w.Scan(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastScan returns the result of the latest scan, if any.
+func (w *Watchdog) LastScan() (Scan, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last == nil {
+		return Scan{}, false
+	}
+	return *w.last, true
+}
+
+func (w *Watchdog) slaFor(name string) config.WatchdogSLA {
+	if s, ok := w.cfg.Workflows[name]; ok {
+		return s
+	}
+	return w.cfg.Default
+}
+
+// actionFor returns the action taken on stuck instances of the named workflow. Only
+// workflows with their own SLA get one: the default SLA is not set knowing how long each
+// workflow can legitimately wait, so instances held to it are reported and left as is.
+func (w *Watchdog) actionFor(name string) string {
+	return w.cfg.Workflows[name].Action
+}
+
+// Scan checks every running instance once, acts on the stuck ones and returns the result.
+func (w *Watchdog) Scan(ctx context.Context) Scan {
+	scan := Scan{StartedAt: w.now().UTC(), Stuck: []Instance{}}
+
+	var mu sync.Mutex
+	ids := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				in, stuck, err := w.check(ctx, id)
+				if err != nil {
+					log.Warnf("Watchdog could not check workflow %s: %v", id, err)
+					continue
+				}
+				if stuck {
+					mu.Lock()
+					scan.Stuck = append(scan.Stuck, in)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	err := instances.ForEach(ctx, w.client, pageSize, func(id string) error {
+		scan.Scanned++
+		select {
+		case ids <- id:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(ids)
+	wg.Wait()
+
+	// Act on the longest stuck instances first, up to the per-scan cap.
+	sort.Slice(scan.Stuck, func(i, j int) bool { return scan.Stuck[i].StuckFor > scan.Stuck[j].StuckFor })
+	acted := 0
+	for i := range scan.Stuck {
+		in := &scan.Stuck[i]
+		action := w.actionFor(in.Workflow)
+		if action == "" || err != nil {
+			continue
+		}
+		if acted >= w.cfg.MaxActionsPerScan {
+			log.Warnf("Watchdog reached %d actions this scan, leaving workflow %s as is", acted, in.InstanceID)
+			continue
+		}
+		acted++
+		w.act(ctx, in, action)
+	}
+
+	scan.FinishedAt = w.now().UTC()
+	outcome := "success"
+	if err != nil {
+		scan.Error = err.Error()
+		outcome = "error"
+		log.Errorf("Watchdog scan stopped after %d instances: %v", scan.Scanned, err)
+	}
+	w.scans.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	for _, in := range scan.Stuck {
+		log.Warnf("Workflow %s (%s) is stuck: no update for %s, SLA %s",
+			in.InstanceID, in.Workflow, time.Duration(in.StuckFor*float64(time.Second)).Round(time.Second), in.SLA)
+	}
+	log.Infof("Watchdog scan: scanned %d, stuck %d, acted on %d", scan.Scanned, len(scan.Stuck), acted)
+
+	w.mu.Lock()
+	w.last = &scan
+	w.mu.Unlock()
+	return scan
+}
+
+// check reports whether the instance is running and has not been updated within its SLA.
+func (w *Watchdog) check(ctx context.Context, id string) (Instance, bool, error) {
+	meta, err := w.client.FetchWorkflowMetadata(ctx, id)
+	if instances.IsNotFound(err) {
+		return Instance{}, false, nil
+	}
+	if err != nil {
+		return Instance{}, false, err
+	}
+
+	st := meta.String()
+	if (st != "RUNNING" && st != "PENDING") || meta.LastUpdatedAt == nil {
+		return Instance{}, false, nil
+	}
+	sla := w.slaFor(meta.Name).SLA
+	last := meta.LastUpdatedAt.AsTime()
+	idle := w.now().Sub(last)
+	if sla == 0 || idle < sla {
+		return Instance{}, false, nil
+	}
+
+	return Instance{
+		InstanceID:    id,
+		Workflow:      meta.Name,
+		RuntimeStatus: st,
+		LastUpdatedAt: last,
+		StuckFor:      idle.Seconds(),
+		SLA:           sla.String(),
+	}, true, nil
+}
+
+// act takes action on in and records the outcome on it.
+func (w *Watchdog) act(ctx context.Context, in *Instance, action string) {
+	reason := fmt.Sprintf("watchdog: no progress for %s, SLA %s",
+		time.Duration(in.StuckFor*float64(time.Second)).Round(time.Second), in.SLA)
+
+	var err error
+	switch action {
+	case config.WatchdogTerminate:
+		err = w.terminate(ctx, in.InstanceID, reason)
+	case config.WatchdogRerun:
+		in.RerunID, err = w.rerun(ctx, in.InstanceID, reason)
+	}
+
+	in.Action = action
+	outcome := "success"
+	if err != nil {
+		in.ActionError = err.Error()
+		outcome = "error"
+		log.Errorf("Watchdog failed to %s workflow %s: %v", action, in.InstanceID, err)
+	} else {
+		log.Infof("Watchdog did %s on workflow %s", action, in.InstanceID)
+	}
+	w.actions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("workflow", in.Workflow),
+		attribute.String("action", action),
+		attribute.String("outcome", outcome),
+	))
+}
+
+func (w *Watchdog) terminate(ctx context.Context, id, reason string) error {
+	return w.client.TerminateWorkflow(ctx, id, workflow.WithRecursiveTerminate(true), workflow.WithOutput(reason))
+}
+
+// rerun terminates the instance, since only finished instances can be rerun, and reruns it
+// from its last scheduled activity or child workflow as a new instance of the same tenant.
+func (w *Watchdog) rerun(ctx context.Context, id, reason string) (string, error) {
+	hist, err := w.client.GetInstanceHistory(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("get history: %w", err)
+	}
+	eventID, ok := lastRerunPoint(hist.Events)
+	if !ok {
+		return "", errors.New("no activity or child workflow to rerun from")
+	}
+
+	if err := w.terminate(ctx, id, reason); err != nil {
+		return "", fmt.Errorf("terminate: %w", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, terminateTimeout)
+	defer cancel()
+	if _, err := w.client.WaitForWorkflowCompletion(waitCtx, id); err != nil {
+		return "", fmt.Errorf("wait for termination: %w", err)
+	}
+
+	newID := tenant.InstanceID(tenant.FromInstanceID(id), uuid.NewString())
+	newID, err = w.client.RerunWorkflowFromEvent(ctx, id, eventID, workflow.WithRerunNewInstanceID(newID))
+	if err != nil {
+		return "", fmt.Errorf("rerun from event %d: %w", eventID, err)
+	}
+	return newID, nil
+}
+
+// lastRerunPoint returns the ID of the last activity or child workflow scheduled.
+func lastRerunPoint(events []*protos.HistoryEvent) (uint32, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		e := events[i]
+		if e.GetTaskScheduled() != nil || e.GetSubOrchestrationInstanceCreated() != nil {
+			return uint32(e.GetEventId()), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,152 @@
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+
+	"github.com/javier-aliaga/dapr-go-samples/config"
+	"github.com/javier-aliaga/dapr-go-samples/dapr/daprtest"
+)
+
+const (
+	running   = protos.OrchestrationStatus_ORCHESTRATION_STATUS_RUNNING
+	pending   = protos.OrchestrationStatus_ORCHESTRATION_STATUS_PENDING
+	completed = protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED
+)
+
+var now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newWatchdog(sidecar *daprtest.Sidecar, cfg config.Watchdog) *Watchdog {
+	w := New(sidecar.Client(), cfg)
+	w.now = func() time.Time { return now }
+	return w
+}
+
+// history is a history that scheduled an activity as event 1, and another as event 3.
+func history() []*protos.HistoryEvent {
+	scheduled := func(id int32) *protos.HistoryEvent {
+		return &protos.HistoryEvent{EventId: id, EventType: &protos.HistoryEvent_TaskScheduled{TaskScheduled: &protos.TaskScheduledEvent{Name: "CheckHealth"}}}
+	}
+	return []*protos.HistoryEvent{
+		{EventId: -1, EventType: &protos.HistoryEvent_OrchestratorStarted{OrchestratorStarted: &protos.OrchestratorStartedEvent{}}},
+		scheduled(1),
+		{EventId: -1, EventType: &protos.HistoryEvent_TaskCompleted{TaskCompleted: &protos.TaskCompletedEvent{TaskScheduledId: 1}}},
+		scheduled(3),
+	}
+}
+
+func TestScan(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	sidecar.SetClock(func() time.Time { return now })
+	for _, in := range []daprtest.Instance{
+		{ID: "stuck", Name: "SimpleWorkflow", Status: running, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "fresh", Name: "SimpleWorkflow", Status: running, UpdatedAt: now.Add(-30 * time.Minute)},
+		{ID: "pending", Name: "SimpleWorkflow", Status: pending, UpdatedAt: now.Add(-3 * time.Hour)},
+		{ID: "done", Name: "SimpleWorkflow", Status: completed, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "saga", Name: "OrderSagaWorkflow", Status: running, UpdatedAt: now.Add(-20 * time.Minute)},
+		// A workflow SLA replaces the default as a whole, and zero never reports.
+		{ID: "batch", Name: "BatchWorkflow", Status: running, UpdatedAt: now.Add(-48 * time.Hour)},
+		{ID: "acme~monitor", Name: "MonitorWorkflow", Status: running, UpdatedAt: now.Add(-time.Hour), Input: `{"target":"x"}`, History: history()},
+	} {
+		sidecar.Put(in)
+	}
+	w := newWatchdog(sidecar, config.Watchdog{
+		Interval: time.Minute,
+		// Instances held to the default SLA are only reported.
+		Default: config.WatchdogSLA{SLA: time.Hour},
+		Workflows: map[string]config.WatchdogSLA{
+			"OrderSagaWorkflow": {SLA: 10 * time.Minute, Action: config.WatchdogTerminate},
+			"MonitorWorkflow":   {SLA: 10 * time.Minute, Action: config.WatchdogRerun},
+			"BatchWorkflow":     {},
+		},
+	})
+
+	scan := w.Scan(context.Background())
+	if scan.Scanned != 7 || scan.Error != "" {
+		t.Fatalf("scan: %+v", scan)
+	}
+	var stuck []string
+	for _, in := range scan.Stuck {
+		stuck = append(stuck, in.InstanceID+" "+in.Action)
+	}
+	// The longest stuck come first.
+	want := []string{"pending ", "stuck ", "acme~monitor rerun", "saga terminate"}
+	if !reflect.DeepEqual(stuck, want) {
+		t.Fatalf("stuck %v, want %v", stuck, want)
+	}
+	if in := scan.Stuck[1]; in.Workflow != "SimpleWorkflow" || in.RuntimeStatus != "RUNNING" || in.StuckFor != 7200 || in.SLA != "1h0m0s" {
+		t.Errorf("stuck instance %+v", in)
+	}
+	if last, ok := w.LastScan(); !ok || !reflect.DeepEqual(last, scan) {
+		t.Errorf("last scan %+v, want the scan", last)
+	}
+
+	if in, _ := sidecar.Instance("saga"); in.Status != protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED ||
+		in.Output != `"watchdog: no progress for 20m0s, SLA 10m0s"` {
+		t.Errorf("saga is %s with output %q, want terminated by the watchdog", in.Status, in.Output)
+	}
+	for _, id := range []string{"stuck", "pending", "batch"} {
+		if in, _ := sidecar.Instance(id); in.Status == protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED {
+			t.Errorf("%s was terminated", id)
+		}
+	}
+
+	// The rerun starts from the last scheduled activity, as a new instance of the same tenant.
+	monitor := scan.Stuck[2]
+	if monitor.ActionError != "" || !strings.HasPrefix(monitor.RerunID, "acme~") {
+		t.Fatalf("rerun: %+v", monitor)
+	}
+	if !slices.Contains(sidecar.Calls(), "RerunWorkflowFromEvent acme~monitor 3") {
+		t.Errorf("calls %v, want a rerun from event 3", sidecar.Calls())
+	}
+	if in, ok := sidecar.Instance(monitor.RerunID); !ok || in.Status != running || in.Input != `{"target":"x"}` {
+		t.Errorf("rerun instance %+v", in)
+	}
+}
+
+func TestScanActionLimits(t *testing.T) {
+	sidecar := daprtest.Start(t)
+	for i := range 3 {
+		sidecar.Put(daprtest.Instance{ID: fmt.Sprintf("saga-%d", i), Name: "OrderSagaWorkflow", Status: running, UpdatedAt: now.Add(-time.Duration(i+1) * time.Hour)})
+	}
+	// Without a history there is nothing to rerun from, and the instance is left running.
+	sidecar.Put(daprtest.Instance{ID: "monitor", Name: "MonitorWorkflow", Status: running, UpdatedAt: now.Add(-10 * time.Hour)})
+	w := newWatchdog(sidecar, config.Watchdog{
+		Interval: time.Minute,
+		Workflows: map[string]config.WatchdogSLA{
+			"OrderSagaWorkflow": {SLA: time.Minute, Action: config.WatchdogTerminate},
+			"MonitorWorkflow":   {SLA: time.Minute, Action: config.WatchdogRerun},
+		},
+		MaxActionsPerScan: 2,
+	})
+
+	scan := w.Scan(context.Background())
+	var acted []string
+	for _, in := range scan.Stuck {
+		acted = append(acted, fmt.Sprintf("%s %s %t", in.InstanceID, in.Action, in.ActionError != ""))
+	}
+	// The longest stuck are acted on first, and a failed action counts towards the cap.
+	want := []string{"monitor rerun true", "saga-2 terminate false", "saga-1  false", "saga-0  false"}
+	if !reflect.DeepEqual(acted, want) {
+		t.Errorf("acted %v, want %v", acted, want)
+	}
+	for id, want := range map[string]protos.OrchestrationStatus{
+		"monitor": running, "saga-2": protos.OrchestrationStatus_ORCHESTRATION_STATUS_TERMINATED, "saga-1": running,
+	} {
+		if in, _ := sidecar.Instance(id); in.Status != want {
+			t.Errorf("%s is %s, want %s", id, in.Status, want)
+		}
+	}
+
+	sidecar.Fail("ListInstanceIDs", fmt.Errorf("state store down"))
+	if scan := w.Scan(context.Background()); scan.Error == "" || len(scan.Stuck) != 0 {
+		t.Errorf("failed listing: %+v, want the error reported", scan)
+	}
+}
@@ -0,0 +1,210 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dapr/durabletask-go/task"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// ApprovalEvent is the external event carrying an ApprovalDecision.
+const ApprovalEvent = "approval"
+
+// Approval states reported in ApprovalStatus.State.
+const (
+	ApprovalWaiting   = "waiting"
+	ApprovalEscalated = "escalated"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalExpired   = "expired"
+)
+
+const (
+	defaultReminderInterval = time.Hour
+	defaultMaxReminders     = 3
+)
+
+// ApprovalRequest is the input of ApprovalWorkflow.
+type ApprovalRequest struct {
+	Subject            string `json:"subject"`
+	Approver           string `json:"approver"`
+	EscalationApprover string `json:"escalationApprover,omitempty"`
+	// ReminderInterval is a Go duration string such as "30m".
+	ReminderInterval string `json:"reminderInterval,omitempty"`
+	// MaxReminders is the number of reminders sent to an approver before escalating or expiring.
+	MaxReminders int `json:"maxReminders,omitempty"`
+}
+
+// Interval returns the time between reminders. An interval that is not positive would
+// make the workflow send every reminder at once, so it is an error.
+func (r ApprovalRequest) Interval() (time.Duration, error) {
+	if r.ReminderInterval == "" {
+		return defaultReminderInterval, nil
+	}
+	d, err := time.ParseDuration(r.ReminderInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid reminder interval: %w", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid reminder interval %q: must be positive", r.ReminderInterval)
+	}
+	return d, nil
+}
+
+// ApprovalDecision is the payload of the ApprovalEvent.
+type ApprovalDecision struct {
+	Approved bool   `json:"approved"`
+	Approver string `json:"approver"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// ApprovalStatus is published as the workflow custom status while it waits.
+type ApprovalStatus struct {
+	State     string `json:"state"`
+	WaitingOn string `json:"waitingOn,omitempty"`
+	Reminders int    `json:"reminders"`
+}
+
+// ApprovalResult is the output of ApprovalWorkflow.
+type ApprovalResult struct {
+	State     string `json:"state"`
+	DecidedBy string `json:"decidedBy,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+	Escalated bool   `json:"escalated"`
+	Reminders int    `json:"reminders"`
+}
+
+// ReminderInput is the input of SendApprovalReminder.
+type ReminderInput struct {
+	InstanceID string `json:"instanceId"`
+	Subject    string `json:"subject"`
+	Approver   string `json:"approver"`
+	Reminder   int    `json:"reminder"`
+	Escalation bool   `json:"escalation"`
+}
+
+// ApprovalWorkflow waits for an ApprovalEvent from the approver. Each wait is bounded by a
+// durable timer; every time it fires a reminder is sent, and after MaxReminders the request
+// escalates to the escalation approver. If nobody decides, the request expires.
+func ApprovalWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var req ApprovalRequest
+	if err := ctx.GetInput(&req); err != nil {
+		return nil, fmt.Errorf("decode approval request: %w", err)
+	}
+	if req.Approver == "" {
+		return nil, errors.New("approval request has no approver")
+	}
+
+	interval, err := req.Interval()
+	if err != nil {
+		return nil, err
+	}
+	maxReminders := req.MaxReminders
+	if maxReminders <= 0 {
+		maxReminders = defaultMaxReminders
+	}
+
+	status := ApprovalStatus{State: ApprovalWaiting, WaitingOn: req.Approver}
+	result := ApprovalResult{}
+	reminders := 0
+
+	for {
+		if err := setApprovalStatus(ctx, status); err != nil {
+			return nil, err
+		}
+
+		var decision ApprovalDecision
+		err := ctx.WaitForExternalEvent(ApprovalEvent, interval).Await(&decision)
+		switch {
+		case err == nil:
+			if !approverAllowed(decision.Approver, req, status) {
+				if !ctx.IsReplaying() {
+					log.Warnf("approval %s: ignoring decision from %q", ctx.ID(), decision.Approver)
+				}
+				continue
+			}
+			result.DecidedBy = decision.Approver
+			result.Comment = decision.Comment
+			result.State = ApprovalRejected
+			if decision.Approved {
+				result.State = ApprovalApproved
+			}
+		case errors.Is(err, task.ErrTaskCanceled):
+			// The durable timer fired before a decision arrived.
+			if reminders < maxReminders {
+				reminders++
+				status.Reminders++
+				if err := sendReminder(ctx, req, status, reminders); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if status.State == ApprovalWaiting && req.EscalationApprover != "" {
+				status.State = ApprovalEscalated
+				status.WaitingOn = req.EscalationApprover
+				result.Escalated = true
+				reminders = 0
+				if err := sendReminder(ctx, req, status, reminders); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			result.State = ApprovalExpired
+		default:
+			return nil, err
+		}
+		break
+	}
+
+	result.Reminders = status.Reminders
+	if err := setApprovalStatus(ctx, ApprovalStatus{State: result.State, Reminders: status.Reminders}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// approverAllowed reports whether approver may decide. Once escalated, both approvers may.
+func approverAllowed(approver string, req ApprovalRequest, status ApprovalStatus) bool {
+	if approver == req.Approver {
+		return true
+	}
+	return status.State == ApprovalEscalated && approver == req.EscalationApprover
+}
+
+func sendReminder(ctx *workflow.WorkflowContext, req ApprovalRequest, status ApprovalStatus, reminder int) error {
+	return callActivity(ctx, SendApprovalReminder, ReminderInput{
+		InstanceID: ctx.ID(),
+		Subject:    req.Subject,
+		Approver:   status.WaitingOn,
+		Reminder:   reminder,
+		Escalation: status.State == ApprovalEscalated,
+	}).Await(nil)
+}
+
+func setApprovalStatus(ctx *workflow.WorkflowContext, status ApprovalStatus) error {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("encode approval status: %w", err)
+	}
+	ctx.SetCustomStatus(string(b))
+	return nil
+}
+
+// SendApprovalReminder notifies an approver that a request is still waiting on them.
+// Reminder zero is the escalation notice sent to the escalation approver.
+func SendApprovalReminder(ctx workflow.ActivityContext) (any, error) {
+	var in ReminderInput
+	if err := ctx.GetInput(&in); err != nil {
+		return nil, err
+	}
+
+	if in.Reminder == 0 {
+		log.Infof("Escalating approval %s (%q) to %s", in.InstanceID, in.Subject, in.Approver)
+		return nil, nil
+	}
+	log.Infof("Reminder %d for approval %s (%q) sent to %s", in.Reminder, in.InstanceID, in.Subject, in.Approver)
+	return nil, nil
+}
@@ -0,0 +1,168 @@
+package workflows
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+// fireLatest fires the timer created last, the one the workflow waits on.
+func fireLatest(t *testing.T, o *orchestration) {
+	t.Helper()
+	latest := int32(-1)
+	for id := range o.timers {
+		latest = max(latest, id)
+	}
+	if latest < 0 {
+		t.Fatal("no timer is pending")
+	}
+	o.fire(latest)
+}
+
+// expectApprovalStatus fails the test unless the custom status of the workflow is want.
+func expectApprovalStatus(t *testing.T, o *orchestration, want ApprovalStatus) {
+	t.Helper()
+	var got ApprovalStatus
+	if err := json.Unmarshal([]byte(o.customStatus), &got); err != nil {
+		t.Fatalf("custom status %q: %v", o.customStatus, err)
+	}
+	if got != want {
+		t.Fatalf("status %+v, want %+v", got, want)
+	}
+}
+
+func approvalResult(t *testing.T, o *orchestration) ApprovalResult {
+	t.Helper()
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+		t.Fatalf("workflow ended %s: %v", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+	var r ApprovalResult
+	if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestApprovalWorkflowEscalates(t *testing.T) {
+	o := newOrchestration(t, "ApprovalWorkflow", ApprovalWorkflow)
+	o.start("ApprovalWorkflow", ApprovalRequest{
+		Subject: "expense", Approver: "alice", EscalationApprover: "bob", ReminderInterval: "1h", MaxReminders: 2,
+	})
+	expectApprovalStatus(t, o, ApprovalStatus{State: ApprovalWaiting, WaitingOn: "alice"})
+
+	// Every timeout reminds alice, until she had MaxReminders.
+	for i := 1; i <= 2; i++ {
+		fireLatest(t, o)
+		expectPending(t, o, "SendApprovalReminder")
+		o.complete(o.pendingIDs()[0], nil)
+		expectApprovalStatus(t, o, ApprovalStatus{State: ApprovalWaiting, WaitingOn: "alice", Reminders: i})
+	}
+
+	// The next one escalates to bob, who then gets reminders of his own.
+	fireLatest(t, o)
+	o.complete(o.pendingIDs()[0], nil)
+	expectApprovalStatus(t, o, ApprovalStatus{State: ApprovalEscalated, WaitingOn: "bob", Reminders: 2})
+	fireLatest(t, o)
+	o.complete(o.pendingIDs()[0], nil)
+	expectApprovalStatus(t, o, ApprovalStatus{State: ApprovalEscalated, WaitingOn: "bob", Reminders: 3})
+
+	var reminders []ReminderInput
+	for _, e := range o.history {
+		if ts := e.GetTaskScheduled(); ts != nil {
+			var in ReminderInput
+			_ = json.Unmarshal([]byte(ts.GetInput().GetValue()), &in)
+			reminders = append(reminders, ReminderInput{Approver: in.Approver, Reminder: in.Reminder, Escalation: in.Escalation})
+		}
+	}
+	want := []ReminderInput{
+		{Approver: "alice", Reminder: 1},
+		{Approver: "alice", Reminder: 2},
+		{Approver: "bob", Reminder: 0, Escalation: true},
+		{Approver: "bob", Reminder: 1, Escalation: true},
+	}
+	if !reflect.DeepEqual(reminders, want) {
+		t.Errorf("reminders %+v, want %+v", reminders, want)
+	}
+
+	// Decisions from anyone else are ignored; both approvers may decide once escalated.
+	o.raise(ApprovalEvent, ApprovalDecision{Approved: true, Approver: "mallory"})
+	if o.result != nil {
+		t.Fatal("a decision from mallory completed the approval")
+	}
+	o.raise(ApprovalEvent, ApprovalDecision{Approved: false, Approver: "alice", Comment: "too expensive"})
+
+	r := approvalResult(t, o)
+	wantResult := ApprovalResult{State: ApprovalRejected, DecidedBy: "alice", Comment: "too expensive", Escalated: true, Reminders: 3}
+	if r != wantResult {
+		t.Errorf("result %+v, want %+v", r, wantResult)
+	}
+	expectApprovalStatus(t, o, ApprovalStatus{State: ApprovalRejected, Reminders: 3})
+}
+
+func TestApprovalWorkflowDecisions(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		req  ApprovalRequest
+		// timeouts is how many waits time out before decision is raised, if any.
+		timeouts int
+		decision *ApprovalDecision
+		want     ApprovalResult
+	}{
+		{name: "approved", req: ApprovalRequest{Approver: "alice"},
+			decision: &ApprovalDecision{Approved: true, Approver: "alice"},
+			want:     ApprovalResult{State: ApprovalApproved, DecidedBy: "alice"}},
+		{name: "approved after a reminder", req: ApprovalRequest{Approver: "alice", MaxReminders: 2}, timeouts: 1,
+			decision: &ApprovalDecision{Approved: true, Approver: "alice"},
+			want:     ApprovalResult{State: ApprovalApproved, DecidedBy: "alice", Reminders: 1}},
+		{name: "escalation approver before escalating", req: ApprovalRequest{Approver: "alice", EscalationApprover: "bob"},
+			decision: &ApprovalDecision{Approved: true, Approver: "bob"}},
+		{name: "expired without escalation", req: ApprovalRequest{Approver: "alice", MaxReminders: 1}, timeouts: 2,
+			want: ApprovalResult{State: ApprovalExpired, Reminders: 1}},
+		{name: "expired after escalation", req: ApprovalRequest{Approver: "alice", EscalationApprover: "bob", MaxReminders: 1}, timeouts: 4,
+			want: ApprovalResult{State: ApprovalExpired, Escalated: true, Reminders: 2}},
+	} {
+		o := newOrchestration(t, "ApprovalWorkflow", ApprovalWorkflow)
+		o.start("ApprovalWorkflow", tc.req)
+		for range tc.timeouts {
+			fireLatest(t, o)
+			if o.result != nil {
+				break
+			}
+			o.complete(o.pendingIDs()[0], nil)
+		}
+		if tc.decision != nil {
+			o.raise(ApprovalEvent, tc.decision)
+		}
+
+		if tc.want.State == "" {
+			if o.result != nil {
+				t.Errorf("%s: completed with %s, want still waiting", tc.name, o.result.GetResult().GetValue())
+			}
+			continue
+		}
+		if o.result == nil {
+			t.Errorf("%s: still waiting, want %+v", tc.name, tc.want)
+			continue
+		}
+		if r := approvalResult(t, o); r != tc.want {
+			t.Errorf("%s: result %+v, want %+v", tc.name, r, tc.want)
+		}
+	}
+}
+
+func TestApprovalWorkflowRejectsInvalidRequests(t *testing.T) {
+	for _, req := range []ApprovalRequest{
+		{Subject: "no approver"},
+		{Approver: "alice", ReminderInterval: "soon"},
+		{Approver: "alice", ReminderInterval: "0s"},
+		{Approver: "alice", ReminderInterval: "-1h"},
+	} {
+		o := newOrchestration(t, "ApprovalWorkflow", ApprovalWorkflow)
+		o.start("ApprovalWorkflow", req)
+		if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED {
+			t.Errorf("%+v: workflow ended %s, want failed", req, o.result.GetOrchestrationStatus())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// Failure policies understood by BatchWorkflow.
+const (
+	// FailFast stops scheduling new items after the first failure and fails the workflow
+	// once the in-flight items have drained. The partial BatchReport is left as the JSON
+	// custom status of the failed instance.
+	FailFast = "fail_fast"
+	// CollectErrors processes every item and reports failures alongside the results.
+	CollectErrors = "collect_errors"
+)
+
+const defaultBatchConcurrency = 10
+
+// BatchInput is the input of BatchWorkflow.
+type BatchInput struct {
+	Items          []json.RawMessage `json:"items"`
+	MaxConcurrency int               `json:"maxConcurrency,omitempty"`
+	FailurePolicy  string            `json:"failurePolicy,omitempty"`
+}
+
+// BatchItemResult is the outcome of processing a single item.
+type BatchItemResult struct {
+	Index  int             `json:"index"`
+	Output json.RawMessage `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// BatchReport is the aggregated output of BatchWorkflow.
+type BatchReport struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Skipped   int               `json:"skipped"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// BatchWorkflow fans out one ProcessItem activity per input item, keeping at most
+// MaxConcurrency activities in flight, and fans the results back into a BatchReport.
+//
+// Items are awaited in the order they were scheduled, so the window refills in order: a
+// slow item holds its slot, and those of the items behind it, until it completes, even
+// when later items are already done.
+func BatchWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var input BatchInput
+	if err := ctx.GetInput(&input); err != nil {
+		return nil, fmt.Errorf("decode batch input: %w", err)
+	}
+
+	limit := input.MaxConcurrency
+	if limit <= 0 {
+		limit = defaultBatchConcurrency
+	}
+
+	policy := input.FailurePolicy
+	if policy == "" {
+		policy = CollectErrors
+	}
+	if policy != FailFast && policy != CollectErrors {
+		return nil, fmt.Errorf("unknown failure policy %q", policy)
+	}
+
+	type pending struct {
+		index int
+		task  workflow.Task
+	}
+
+	report := BatchReport{Total: len(input.Items)}
+	var inflight []pending
+	var firstErr error
+	next := 0
+
+	for next < len(input.Items) || len(inflight) > 0 {
+		// Top up the window unless a fail-fast batch has already seen an error.
+		for firstErr == nil && next < len(input.Items) && len(inflight) < limit {
+			inflight = append(inflight, pending{
+				index: next,
+				task:  callActivity(ctx, ProcessItem, input.Items[next]),
+			})
+			next++
+		}
+
+		// The SDK cannot wait for whichever task completes first, so tasks are awaited in
+		// scheduling order.
+		head := inflight[0]
+		inflight = inflight[1:]
+
+		result := BatchItemResult{Index: head.index}
+		if err := head.task.Await(&result.Output); err != nil {
+			result.Error = err.Error()
+			report.Failed++
+			if policy == FailFast && firstErr == nil {
+				firstErr = fmt.Errorf("item %d: %w", head.index, err)
+			}
+		} else {
+			report.Succeeded++
+		}
+		report.Results = append(report.Results, result)
+
+		if firstErr != nil && len(inflight) == 0 {
+			break
+		}
+	}
+
+	report.Skipped = report.Total - report.Succeeded - report.Failed
+
+	if firstErr != nil {
+		b, err := json.Marshal(report)
+		if err != nil {
+			return nil, fmt.Errorf("encode batch report: %w", err)
+		}
+		ctx.SetCustomStatus(string(b))
+		return nil, fmt.Errorf("batch aborted (%d succeeded, %d failed, %d skipped): %w",
+			report.Succeeded, report.Failed, report.Skipped, firstErr)
+	}
+
+	return report, nil
+}
+
+// ProcessItem is the per-item activity scheduled by BatchWorkflow. It echoes the item back
+// and fails for items that are JSON null, which makes failure policies easy to exercise.
+func ProcessItem(ctx workflow.ActivityContext) (any, error) {
+	var item json.RawMessage
+	if err := ctx.GetInput(&item); err != nil {
+		return nil, err
+	}
+
+	if len(item) == 0 || string(item) == "null" {
+		return nil, errors.New("empty item")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	return item, nil
+}
@@ -0,0 +1,131 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+func startBatch(t *testing.T, input BatchInput) *orchestration {
+	t.Helper()
+	o := newOrchestration(t, "BatchWorkflow", BatchWorkflow)
+	o.start("BatchWorkflow", input)
+	return o
+}
+
+func batchItems(items ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		out[i] = json.RawMessage(item)
+	}
+	return out
+}
+
+func TestBatchWorkflowConcurrency(t *testing.T) {
+	o := startBatch(t, BatchInput{Items: batchItems(`"a"`, `"b"`, `"c"`, `"d"`, `"e"`), MaxConcurrency: 2})
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{0, 1}) {
+		t.Fatalf("in flight %v, want [0 1]", got)
+	}
+
+	// The window refills in order: item 1 finishing first frees no slot until item 0 does.
+	o.complete(1, "b")
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{0}) {
+		t.Fatalf("after item 1: in flight %v, want [0]", got)
+	}
+	o.complete(0, "a")
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{2, 3}) {
+		t.Fatalf("after items 0 and 1: in flight %v, want [2 3]", got)
+	}
+	o.complete(2, "c")
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{3, 4}) {
+		t.Fatalf("after item 2: in flight %v, want [3 4]", got)
+	}
+	o.complete(3, "d")
+	o.complete(4, "e")
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+		t.Fatalf("workflow ended %s: %v", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+	var report BatchReport
+	if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := BatchReport{Total: 5, Succeeded: 5, Results: []BatchItemResult{
+		{Index: 0, Output: json.RawMessage(`"a"`)},
+		{Index: 1, Output: json.RawMessage(`"b"`)},
+		{Index: 2, Output: json.RawMessage(`"c"`)},
+		{Index: 3, Output: json.RawMessage(`"d"`)},
+		{Index: 4, Output: json.RawMessage(`"e"`)},
+	}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report %+v, want %+v", report, want)
+	}
+}
+
+func TestBatchWorkflowCollectErrors(t *testing.T) {
+	o := startBatch(t, BatchInput{Items: batchItems(`"a"`, `null`, `"c"`)})
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{0, 1, 2}) {
+		t.Fatalf("in flight %v, want every item under the default limit", got)
+	}
+	o.complete(0, "a")
+	o.fail(1, errors.New("empty item"))
+	o.complete(2, "c")
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+		t.Fatalf("workflow ended %s: %v", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+	var report BatchReport
+	if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Succeeded != 2 || report.Failed != 1 || report.Skipped != 0 {
+		t.Errorf("report %+v, want 2 succeeded and 1 failed", report)
+	}
+	if len(report.Results) != 3 || report.Results[1].Error == "" || report.Results[1].Output != nil {
+		t.Errorf("results %+v, want item 1 to carry its error", report.Results)
+	}
+}
+
+func TestBatchWorkflowFailFast(t *testing.T) {
+	o := startBatch(t, BatchInput{Items: batchItems(`"a"`, `null`, `"c"`, `"d"`), MaxConcurrency: 2, FailurePolicy: FailFast})
+	o.complete(0, "a")
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{1, 2}) {
+		t.Fatalf("in flight %v, want [1 2]", got)
+	}
+
+	// The failure schedules nothing new, and the workflow waits for item 2 to drain.
+	o.fail(1, errors.New("empty item"))
+	if got := o.pendingIDs(); !reflect.DeepEqual(got, []int32{2}) || o.result != nil {
+		t.Fatalf("after the failure: in flight %v, result %v, want [2] and no result", got, o.result)
+	}
+	o.complete(2, "c")
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED {
+		t.Fatalf("workflow ended %s, want failed", o.result.GetOrchestrationStatus())
+	}
+	if len(o.scheduled()) != 3 {
+		t.Errorf("scheduled %v, want item 3 skipped", o.scheduled())
+	}
+
+	// The failed instance keeps the partial report.
+	var report BatchReport
+	if err := json.Unmarshal([]byte(o.customStatus), &report); err != nil {
+		t.Fatalf("custom status %q: %v", o.customStatus, err)
+	}
+	if report.Total != 4 || report.Succeeded != 2 || report.Failed != 1 || report.Skipped != 1 || len(report.Results) != 3 {
+		t.Errorf("report %+v, want 2 succeeded, 1 failed and 1 skipped", report)
+	}
+}
+
+func TestBatchWorkflowUnknownPolicy(t *testing.T) {
+	o := startBatch(t, BatchInput{Items: batchItems(`"a"`), FailurePolicy: "retry_forever"})
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED {
+		t.Errorf("workflow ended %s, want failed", o.result.GetOrchestrationStatus())
+	}
+	if len(o.scheduled()) != 0 {
+		t.Errorf("scheduled %v, want nothing", o.scheduled())
+	}
+}
@@ -0,0 +1,152 @@
+package workflows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+)
+
+// CallbackInput is the input of DeliverCallback.
+type CallbackInput struct {
+	URL     string            `json:"url"`
+	Payload callbacks.Payload `json:"payload"`
+}
+
+var callbackRetryPolicy = &workflow.RetryPolicy{
+	MaxAttempts:          8,
+	InitialRetryInterval: 2 * time.Second,
+	BackoffCoefficient:   2,
+	MaxRetryInterval:     5 * time.Minute,
+	Handle:               RetryableError,
+}
+
+// WithCompletionCallback wraps wf so that, when the workflow input is a JSON object with a
+// "callbackUrl" field, the final outcome is POSTed there before the workflow finishes.
+// Delivery is an activity retried with backoff, so it survives restarts; a callback that
+// still fails is logged and does not change the outcome of the workflow.
+func WithCompletionCallback(wf workflow.Workflow) workflow.Workflow {
+	return func(ctx *workflow.WorkflowContext) (any, error) {
+		var in struct {
+			CallbackURL string `json:"callbackUrl"`
+		}
+		// Inputs that are not objects simply have no callback.
+		_ = ctx.GetInput(&in)
+
+		out, err := wf(ctx)
+		if in.CallbackURL == "" {
+			return out, err
+		}
+
+		payload := callbacks.Payload{
+			InstanceID:    ctx.ID(),
+			Workflow:      ctx.Name(),
+			RuntimeStatus: "COMPLETED",
+			CompletedAt:   ctx.CurrentTimeUTC(),
+		}
+		if err != nil {
+			payload.RuntimeStatus = "FAILED"
+			payload.Error = err.Error()
+		} else if out != nil {
+			if b, merr := json.Marshal(out); merr == nil {
+				payload.Output = b
+			}
+		}
+
+		derr := callActivity(ctx, DeliverCallback,
+			CallbackInput{URL: in.CallbackURL, Payload: payload},
+			workflow.WithActivityRetryPolicy(callbackRetryPolicy),
+		).Await(nil)
+		if derr != nil && !ctx.IsReplaying() {
+			log.Errorf("Giving up on callback for workflow %s: %v", ctx.ID(), derr)
+		}
+
+		return out, err
+	}
+}
+
+var callbackClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: otelhttp.NewTransport(callbacks.NewTransport()),
+}
+
+// DeliverCallback POSTs the signed payload to the callback URL and records the attempt in
+// the delivery log. 4xx responses other than 408 and 429, and URLs that address internal
+// networks, are not retried.
+func DeliverCallback(ctx workflow.ActivityContext) (any, error) {
+	var in CallbackInput
+	if err := ctx.GetInput(&in); err != nil {
+		return nil, err
+	}
+
+	settings := callbacks.Current()
+	body, err := json.Marshal(in.Payload)
+	if err != nil {
+		return nil, NonRetryable(fmt.Errorf("encode callback payload: %w", err))
+	}
+
+	// The log numbers the attempt, so that attempts made by other replicas or before a
+	// restart count too.
+	delivery := callbacks.Delivery{
+		InstanceID: in.Payload.InstanceID,
+		URL:        in.URL,
+		At:         time.Now().UTC(),
+	}
+
+	deliverErr := postCallback(ctx, in, body, &delivery)
+	delivery.Succeeded = deliverErr == nil
+	if deliverErr != nil {
+		delivery.Error = deliverErr.Error()
+	}
+	if err := settings.Log.Record(ctx.Context(), delivery); err != nil {
+		log.Errorf("Failed to record callback delivery for workflow %s: %v", delivery.InstanceID, err)
+	}
+
+	return nil, deliverErr
+}
+
+// postCallback sends one request and stores the response status on delivery.
+func postCallback(ctx workflow.ActivityContext, in CallbackInput, body []byte, delivery *callbacks.Delivery) error {
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodPost, in.URL, bytes.NewReader(body))
+	if err != nil {
+		return NonRetryable(fmt.Errorf("invalid callback URL: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(callbacks.HeaderInstanceID, in.Payload.InstanceID)
+	req.Header.Set(callbacks.HeaderTimestamp, strconv.FormatInt(delivery.At.Unix(), 10))
+	if secret := callbacks.Current().Secret; len(secret) > 0 {
+		req.Header.Set(callbacks.HeaderSignature, callbacks.Sign(secret, delivery.At, body))
+	}
+
+	if err := callbacks.CheckURL(in.URL); err != nil {
+		return NonRetryable(err)
+	}
+	resp, err := callbackClient.Do(req)
+	if errors.Is(err, callbacks.ErrForbiddenURL) {
+		return NonRetryable(fmt.Errorf("callback request failed: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("callback request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	delivery.StatusCode = resp.StatusCode
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return NonRetryable(fmt.Errorf("callback rejected with %s", resp.Status))
+	default:
+		return fmt.Errorf("callback failed with %s", resp.Status)
+	}
+}
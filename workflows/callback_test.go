@@ -0,0 +1,92 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/javier-aliaga/dapr-go-samples/callbacks"
+)
+
+// configureCallbacks installs callback settings with a fresh delivery log until the test
+// ends, and returns the log.
+func configureCallbacks(t *testing.T, allowPrivate bool) *callbacks.MemoryLog {
+	t.Helper()
+	prev := *callbacks.Current()
+	t.Cleanup(func() { callbacks.Configure(prev) })
+	deliveries := callbacks.NewMemoryLog()
+	callbacks.Configure(callbacks.Settings{Secret: []byte("secret"), Log: deliveries, AllowPrivateNetworks: allowPrivate})
+	return deliveries
+}
+
+func TestDeliverCallback(t *testing.T) {
+	payload := callbacks.Payload{InstanceID: "inst", Workflow: "SimpleWorkflow", RuntimeStatus: "COMPLETED"}
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		// wantErr is whether delivery fails, and wantRetry whether it is retried.
+		wantErr   bool
+		wantRetry bool
+	}{
+		{name: "delivered", status: http.StatusNoContent},
+		{name: "server error", status: http.StatusBadGateway, wantErr: true, wantRetry: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantErr: true, wantRetry: true},
+		{name: "rejected", status: http.StatusNotFound, wantErr: true},
+	} {
+		deliveries := configureCallbacks(t, true)
+		srv, got := httpServer(t, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(tc.status) })
+
+		_, err := DeliverCallback(newActivityContext(t, CallbackInput{URL: srv.URL + "/hook", Payload: payload}))
+		if (err != nil) != tc.wantErr || (err != nil && RetryableError(err) != tc.wantRetry) {
+			t.Errorf("%s: error %v, want error %v and retry %v", tc.name, err, tc.wantErr, tc.wantRetry)
+		}
+
+		if got.method != http.MethodPost || got.headers.Get(callbacks.HeaderInstanceID) != "inst" {
+			t.Errorf("%s: request %s with headers %v", tc.name, got.method, got.headers)
+		}
+		ts, _ := strconv.ParseInt(got.headers.Get(callbacks.HeaderTimestamp), 10, 64)
+		if want := callbacks.Sign([]byte("secret"), time.Unix(ts, 0), []byte(got.body)); got.headers.Get(callbacks.HeaderSignature) != want {
+			t.Errorf("%s: signature %q, want %q", tc.name, got.headers.Get(callbacks.HeaderSignature), want)
+		}
+		var sent callbacks.Payload
+		if err := json.Unmarshal([]byte(got.body), &sent); err != nil || sent.InstanceID != "inst" || sent.RuntimeStatus != "COMPLETED" {
+			t.Errorf("%s: body %s", tc.name, got.body)
+		}
+
+		logged, _ := deliveries.List(context.Background(), "inst")
+		if len(logged) != 1 || logged[0].StatusCode != tc.status || logged[0].Succeeded == tc.wantErr {
+			t.Errorf("%s: delivery log %+v", tc.name, logged)
+		}
+	}
+}
+
+func TestDeliverCallbackRefusesInternalAddresses(t *testing.T) {
+	reached := false
+	srv, _ := httpServer(t, func(w http.ResponseWriter, r *http.Request) { reached = true })
+	u, _ := url.Parse(srv.URL)
+	payload := callbacks.Payload{InstanceID: "inst", Workflow: "SimpleWorkflow", RuntimeStatus: "COMPLETED"}
+
+	for _, target := range []string{
+		srv.URL + "/hook",
+		// The name passes the URL check, and is refused once it resolves.
+		"http://localhost:" + u.Port() + "/hook",
+		"http://169.254.169.254/latest/meta-data",
+	} {
+		deliveries := configureCallbacks(t, false)
+		_, err := DeliverCallback(newActivityContext(t, CallbackInput{URL: target, Payload: payload}))
+		if err == nil || RetryableError(err) {
+			t.Errorf("%s: error %v, want a non-retryable error", target, err)
+		}
+		if logged, _ := deliveries.List(context.Background(), "inst"); len(logged) != 1 || logged[0].Succeeded {
+			t.Errorf("%s: delivery log %+v, want the refused attempt", target, logged)
+		}
+	}
+	if reached {
+		t.Error("a callback reached the internal server")
+	}
+}
@@ -0,0 +1,381 @@
+package workflows
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Definition is a workflow described in YAML or JSON and run by Interpret.
+type Definition struct {
+	// Name is the name the workflow is registered under. Defaults to the file name
+	// without its extension.
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Steps       []*Step `yaml:"steps"`
+	// Output is the output of the workflow, a template like step inputs.
+	Output any `yaml:"output"`
+
+	// File is the file the definition was loaded from, and Hash the SHA-256 of its content.
+	File string `yaml:"-"`
+	Hash string `yaml:"-"`
+
+	output template
+}
+
+// Step is one step of a Definition. Exactly one of Activity, Workflow, Event, Timer and
+// Parallel is set.
+type Step struct {
+	// ID names the step for expressions of later steps, as steps.<id>.output and
+	// steps.<id>.error.
+	ID string `yaml:"id"`
+	// If is an expression; the step is skipped when it is false.
+	If string `yaml:"if"`
+	// ContinueOnError records the error of a failed step and carries on. Otherwise a failed
+	// step fails the workflow.
+	ContinueOnError bool `yaml:"continueOnError"`
+
+	// Activity calls the named activity and Workflow starts the named child workflow,
+	// with Input, a template, as input.
+	Activity string     `yaml:"activity"`
+	Workflow string     `yaml:"workflow"`
+	Input    any        `yaml:"input"`
+	Retry    *StepRetry `yaml:"retry"`
+
+	// Event waits for an external event; its payload is the output of the step.
+	Event *StepEvent `yaml:"event"`
+	// Timer waits for a durable timer.
+	Timer time.Duration `yaml:"timer"`
+	// Parallel runs branches side by side. Its output lists the output of the last step
+	// run by every branch.
+	Parallel []Branch `yaml:"parallel"`
+
+	cond  expr
+	input template
+}
+
+// StepRetry retries a failed activity or child workflow. Errors marked with NonRetryable
+// are not retried.
+type StepRetry struct {
+	MaxAttempts        int           `yaml:"maxAttempts"`
+	InitialInterval    time.Duration `yaml:"initialInterval"`
+	BackoffCoefficient float64       `yaml:"backoffCoefficient"`
+	MaxInterval        time.Duration `yaml:"maxInterval"`
+	// Timeout bounds all the attempts together.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// StepEvent waits for the named external event, for at most Timeout when set.
+type StepEvent struct {
+	Name    string        `yaml:"name"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Branch is a sequence of steps run by a parallel step.
+type Branch struct {
+	Steps []*Step `yaml:"steps"`
+}
+
+// Step kinds, as reported by Step.kind.
+const (
+	stepActivity = "activity"
+	stepWorkflow = "workflow"
+	stepEvent    = "event"
+	stepTimer    = "timer"
+	stepParallel = "parallel"
+)
+
+func (s *Step) kind() string {
+	switch {
+	case s.Activity != "":
+		return stepActivity
+	case s.Workflow != "":
+		return stepWorkflow
+	case s.Event != nil:
+		return stepEvent
+	case s.Timer != 0:
+		return stepTimer
+	case s.Parallel != nil:
+		return stepParallel
+	}
+	return ""
+}
+
+// name identifies the step in errors and the custom status.
+func (s *Step) name() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	switch s.kind() {
+	case stepActivity:
+		return s.Activity
+	case stepWorkflow:
+		return s.Workflow
+	case stepEvent:
+		if s.Event.Name != "" {
+			return "event " + s.Event.Name
+		}
+	}
+	return s.kind()
+}
+
+var stepIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// definitionExtensions are the files LoadDefinitions reads.
+var definitionExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// LoadDefinitions reads and validates every definition in dir, one per .yaml, .yml or
+// .json file, sorted by name.
+func LoadDefinitions(dir string) ([]*Definition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read definitions: %w", err)
+	}
+
+	var defs []*Definition
+	files := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() || !definitionExtensions[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read definition: %w", err)
+		}
+		def, err := ParseDefinition(b)
+		if err != nil {
+			return nil, fmt.Errorf("definition %s: %w", path, err)
+		}
+		if def.Name == "" {
+			def.Name = strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		}
+		def.File = path
+		if other, ok := files[def.Name]; ok {
+			return nil, fmt.Errorf("workflow %s is defined in both %s and %s", def.Name, other, path)
+		}
+		files[def.Name] = path
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+// ParseDefinition decodes and validates a definition in YAML or JSON. Unknown fields are
+// errors, so typos do not go unnoticed.
+func ParseDefinition(b []byte) (*Definition, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var def Definition
+	if err := dec.Decode(&def); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty definition")
+		}
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	def.Hash = hex.EncodeToString(sum[:])
+	if err := def.compile(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// compile validates the definition and compiles its expressions and templates. Expressions
+// may only refer to steps that ran before: earlier steps of the same sequence, and steps
+// that precede the enclosing parallel step.
+func (d *Definition) compile() error {
+	if len(d.Steps) == 0 {
+		return errors.New("definition has no steps")
+	}
+	c := &compiler{ids: make(map[string]bool)}
+	visible, err := c.steps(d.Steps, nil, false)
+	if err != nil {
+		return err
+	}
+	if d.output, err = compileTemplate(d.Output, workflowRoots); err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
+	if err := checkPaths(d.output.paths, visible); err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
+	return nil
+}
+
+type compiler struct {
+	// ids holds every step ID of the definition, which must be unique.
+	ids map[string]bool
+}
+
+// steps compiles a sequence of steps that can see the steps in visible, and returns the
+// steps visible after it.
+func (c *compiler) steps(steps []*Step, visible map[string]bool, inBranch bool) (map[string]bool, error) {
+	scope := make(map[string]bool, len(visible))
+	for id := range visible {
+		scope[id] = true
+	}
+	for i, s := range steps {
+		if s == nil {
+			return nil, fmt.Errorf("step %d is empty", i+1)
+		}
+		if err := c.step(s, scope, inBranch); err != nil {
+			return nil, fmt.Errorf("step %s: %w", s.name(), err)
+		}
+		if s.ID != "" {
+			scope[s.ID] = true
+		}
+	}
+	return scope, nil
+}
+
+func (c *compiler) step(s *Step, scope map[string]bool, inBranch bool) error {
+	kinds := 0
+	for _, set := range []bool{s.Activity != "", s.Workflow != "", s.Event != nil, s.Timer != 0, s.Parallel != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errors.New("set exactly one of activity, workflow, event, timer and parallel")
+	}
+
+	if s.ID != "" {
+		if !stepIDPattern.MatchString(s.ID) {
+			return fmt.Errorf("id must be letters, digits and underscores, not starting with a digit")
+		}
+		if c.ids[s.ID] {
+			return errors.New("duplicate id")
+		}
+		c.ids[s.ID] = true
+	}
+
+	if s.If != "" {
+		var err error
+		if s.cond, err = parseExpr(s.If, workflowRoots); err != nil {
+			return fmt.Errorf("if: %w", err)
+		}
+		if err := checkPaths(func(f func(pathExpr)) { paths(s.cond, f) }, scope); err != nil {
+			return fmt.Errorf("if: %w", err)
+		}
+	}
+
+	kind := s.kind()
+	if kind != stepActivity && kind != stepWorkflow && (s.Input != nil || s.Retry != nil) {
+		return errors.New("only activity and workflow steps take input and retry")
+	}
+	switch kind {
+	case stepActivity, stepWorkflow:
+		var err error
+		if s.input, err = compileTemplate(s.Input, workflowRoots); err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+		if err := checkPaths(s.input.paths, scope); err != nil {
+			return fmt.Errorf("input: %w", err)
+		}
+		if r := s.Retry; r != nil {
+			if r.MaxAttempts < 1 || r.InitialInterval <= 0 {
+				return errors.New("retry needs maxAttempts and initialInterval")
+			}
+			if r.BackoffCoefficient < 0 || r.MaxInterval < 0 || r.Timeout < 0 {
+				return errors.New("retry cannot have negative values")
+			}
+		}
+	case stepEvent:
+		if s.Event.Name == "" {
+			return errors.New("event needs a name")
+		}
+		if s.Event.Timeout < 0 {
+			return errors.New("event timeout cannot be negative")
+		}
+	case stepTimer:
+		if s.Timer < 0 {
+			return errors.New("timer cannot be negative")
+		}
+	case stepParallel:
+		if inBranch {
+			return errors.New("parallel steps cannot be nested; call a child workflow instead")
+		}
+		if len(s.Parallel) == 0 {
+			return errors.New("parallel needs branches")
+		}
+		// Branches see what precedes the parallel step, not each other; the steps of every
+		// branch are visible after it.
+		after := make(map[string]bool)
+		for i, b := range s.Parallel {
+			if len(b.Steps) == 0 {
+				return fmt.Errorf("branch %d has no steps", i+1)
+			}
+			visible, err := c.steps(b.Steps, scope, true)
+			if err != nil {
+				return fmt.Errorf("branch %d: %w", i+1, err)
+			}
+			for id := range visible {
+				after[id] = true
+			}
+		}
+		for id := range after {
+			scope[id] = true
+		}
+	}
+	return nil
+}
+
+// checkPaths checks that the step paths walked by walk refer to steps in scope.
+func checkPaths(walk func(func(pathExpr)), scope map[string]bool) error {
+	var err error
+	walk(func(p pathExpr) {
+		if err != nil || p.elems[0] != "steps" {
+			return
+		}
+		if len(p.elems) < 2 {
+			err = fmt.Errorf("%s: name a step, as steps.<id>", p.src)
+			return
+		}
+		id, _ := p.elems[1].(string)
+		if !scope[id] {
+			err = fmt.Errorf("%s: no step %v runs before", p.src, p.elems[1])
+		}
+	})
+	return err
+}
+
+// References returns the activities and child workflows the definition calls, sorted.
+func (d *Definition) References() (activities, workflows []string) {
+	seenA, seenW := make(map[string]bool), make(map[string]bool)
+	var walk func(steps []*Step)
+	walk = func(steps []*Step) {
+		for _, s := range steps {
+			switch s.kind() {
+			case stepActivity:
+				seenA[s.Activity] = true
+			case stepWorkflow:
+				seenW[s.Workflow] = true
+			case stepParallel:
+				for _, b := range s.Parallel {
+					walk(b.Steps)
+				}
+			}
+		}
+	}
+	walk(d.Steps)
+	for name := range seenA {
+		activities = append(activities, name)
+	}
+	for name := range seenW {
+		workflows = append(workflows, name)
+	}
+	sort.Strings(activities)
+	sort.Strings(workflows)
+	return activities, workflows
+}
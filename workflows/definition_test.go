@@ -0,0 +1,223 @@
+package workflows
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDefinitionValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		def     string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			def: `
+steps:
+  - id: check
+    activity: Check
+    input: ${input.order}
+  - id: fan
+    if: steps.check.output.ok == true
+    parallel:
+      - steps:
+          - id: a
+            activity: A
+            input: ${steps.check.output}
+      - steps:
+          - id: b
+            activity: B
+          - id: b2
+            activity: B2
+            input: ${steps.b.output}
+  - activity: Notify
+    input: ["${steps.a.output}", "${steps.b2.output}"]
+output: ${steps.fan.output}
+`,
+		},
+		{
+			name:    "empty",
+			def:     ``,
+			wantErr: "empty definition",
+		},
+		{
+			name:    "no steps",
+			def:     `name: Nothing`,
+			wantErr: "definition has no steps",
+		},
+		{
+			name:    "unknown field",
+			def:     "steps:\n  - activty: A\n",
+			wantErr: "field activty not found",
+		},
+		{
+			name:    "no kind",
+			def:     "steps:\n  - id: a\n",
+			wantErr: "set exactly one of activity, workflow, event, timer and parallel",
+		},
+		{
+			name:    "two kinds",
+			def:     "steps:\n  - activity: A\n    timer: 1s\n",
+			wantErr: "set exactly one of",
+		},
+		{
+			name:    "invalid id",
+			def:     "steps:\n  - id: 1a\n    activity: A\n",
+			wantErr: "id must be letters, digits and underscores",
+		},
+		{
+			name: "duplicate id",
+			def: `
+steps:
+  - id: a
+    activity: A
+  - id: a
+    activity: B
+`,
+			wantErr: "step a: duplicate id",
+		},
+		{
+			name: "duplicate id across branches",
+			def: `
+steps:
+  - parallel:
+      - steps:
+          - id: a
+            activity: A
+      - steps:
+          - id: a
+            activity: B
+`,
+			wantErr: "branch 2: step a: duplicate id",
+		},
+		{
+			name: "forward reference in if",
+			def: `
+steps:
+  - activity: A
+    if: steps.later.output == 1
+  - id: later
+    activity: B
+`,
+			wantErr: "steps.later.output: no step later runs before",
+		},
+		{
+			name: "self reference in input",
+			def: `
+steps:
+  - id: a
+    activity: A
+    input: ${steps.a.output}
+`,
+			wantErr: "no step a runs before",
+		},
+		{
+			name: "forward reference in output",
+			def: `
+steps:
+  - activity: A
+output: ${steps.a.output}
+`,
+			wantErr: "output: steps.a.output: no step a runs before",
+		},
+		{
+			name: "reference to a sibling branch",
+			def: `
+steps:
+  - parallel:
+      - steps:
+          - id: a
+            activity: A
+      - steps:
+          - activity: B
+            input: ${steps.a.output}
+`,
+			wantErr: "branch 2: step B: input: steps.a.output: no step a runs before",
+		},
+		{
+			name: "reference without a step",
+			def: `
+steps:
+  - activity: A
+    if: steps == null
+`,
+			wantErr: "name a step, as steps.<id>",
+		},
+		{
+			name: "nested parallel",
+			def: `
+steps:
+  - parallel:
+      - steps:
+          - parallel:
+              - steps:
+                  - activity: A
+`,
+			wantErr: "parallel steps cannot be nested",
+		},
+		{
+			name:    "parallel without branches",
+			def:     "steps:\n  - parallel: []\n",
+			wantErr: "parallel needs branches",
+		},
+		{
+			name:    "empty branch",
+			def:     "steps:\n  - parallel:\n      - steps: []\n",
+			wantErr: "branch 1 has no steps",
+		},
+		{
+			name:    "input on an event",
+			def:     "steps:\n  - event: {name: go}\n    input: 1\n",
+			wantErr: "only activity and workflow steps take input and retry",
+		},
+		{
+			name:    "event without a name",
+			def:     "steps:\n  - event: {timeout: 1m}\n",
+			wantErr: "event needs a name",
+		},
+		{
+			name:    "incomplete retry",
+			def:     "steps:\n  - activity: A\n    retry: {maxAttempts: 3}\n",
+			wantErr: "retry needs maxAttempts and initialInterval",
+		},
+		{
+			name:    "invalid expression",
+			def:     "steps:\n  - activity: A\n    if: input.total >\n",
+			wantErr: "step A: if: expression",
+		},
+	} {
+		_, err := ParseDefinition([]byte(tc.def))
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: error = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestDefinitionReferences(t *testing.T) {
+	def, err := ParseDefinition([]byte(`
+steps:
+  - activity: B
+  - workflow: Child
+  - parallel:
+      - steps:
+          - activity: A
+      - steps:
+          - activity: B
+          - workflow: Child
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activities, workflows := def.References()
+	if want := []string{"A", "B"}; !reflect.DeepEqual(activities, want) {
+		t.Errorf("activities = %v, want %v", activities, want)
+	}
+	if want := []string{"Child"}; !reflect.DeepEqual(workflows, want) {
+		t.Errorf("workflows = %v, want %v", workflows, want)
+	}
+}
@@ -0,0 +1,24 @@
+package workflows
+
+import (
+	"errors"
+	"strings"
+)
+
+// nonRetryableMarker tags activity errors that retry policies should not retry. Activity
+// errors reach the workflow as plain messages, so the marker travels in the text.
+const nonRetryableMarker = "[non-retryable] "
+
+// NonRetryable marks err so that retry policies using RetryableError give up on it.
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(nonRetryableMarker + err.Error())
+}
+
+// RetryableError is a RetryPolicy.Handle that retries everything except errors marked
+// with NonRetryable.
+func RetryableError(err error) bool {
+	return !strings.Contains(err.Error(), nonRetryableMarker)
+}
@@ -0,0 +1,618 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions of declarative workflows read the workflow state:
+//
+//	input.order.total > 100 && steps.approval.output.approved == true
+//
+// Paths start at a root, such as input, steps or instanceId, and go down with .name, [index] or ['name'];
+// missing values are null. Literals are numbers, 'strings' or "strings", true, false and
+// null. Operators are ==, !=, <, <=, >, >=, &&, || and !, with the usual precedence.
+
+// expr is a compiled expression.
+type expr interface {
+	eval(env map[string]any) (any, error)
+}
+
+// workflowRoots are the names paths of declarative workflows may start with.
+var workflowRoots = []string{"input", "steps", "instanceId"}
+
+type literalExpr struct{ v any }
+
+func (e literalExpr) eval(map[string]any) (any, error) { return e.v, nil }
+
+// pathExpr is a path into the state; elements are map keys (string) or indexes (int).
+type pathExpr struct {
+	src   string
+	elems []any
+}
+
+func (e pathExpr) eval(env map[string]any) (any, error) {
+	var v any = env
+	for _, el := range e.elems {
+		switch k := el.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, nil
+			}
+			v = m[k]
+		case int:
+			s, ok := v.([]any)
+			if !ok || k < 0 || k >= len(s) {
+				return nil, nil
+			}
+			v = s[k]
+		}
+	}
+	return v, nil
+}
+
+type notExpr struct{ x expr }
+
+func (e notExpr) eval(env map[string]any) (any, error) {
+	v, err := e.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type binaryExpr struct {
+	op   string
+	l, r expr
+}
+
+func (e binaryExpr) eval(env map[string]any) (any, error) {
+	l, err := e.l.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// && and || short-circuit.
+	switch e.op {
+	case "&&":
+		if !truthy(l) {
+			return false, nil
+		}
+		r, err := e.r.eval(env)
+		return truthy(r), err
+	case "||":
+		if truthy(l) {
+			return true, nil
+		}
+		r, err := e.r.eval(env)
+		return truthy(r), err
+	}
+
+	r, err := e.r.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	}
+
+	c, err := compare(l, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.op, err)
+	}
+	switch e.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// truthy reports whether v counts as true: null, false, 0, "" and empty lists and objects
+// do not.
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	if f, ok := number(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// number returns v as a float64 when it is a number, as decoded from JSON or YAML.
+func number(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+// normalize converts the numbers in v to float64, so values decoded from YAML compare
+// equal to the same values decoded from JSON.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = normalize(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = normalize(e)
+		}
+		return out
+	}
+	if f, ok := number(v); ok {
+		return f
+	}
+	return v
+}
+
+func equal(l, r any) bool {
+	return reflect.DeepEqual(normalize(l), normalize(r))
+}
+
+func compare(l, r any) (int, error) {
+	if a, ok := number(l); ok {
+		if b, ok := number(r); ok {
+			switch {
+			case a < b:
+				return -1, nil
+			case a > b:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if a, ok := l.(string); ok {
+		if b, ok := r.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", typeName(l), typeName(r))
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// paths calls f with every path in e.
+func paths(e expr, f func(pathExpr)) {
+	switch x := e.(type) {
+	case pathExpr:
+		f(x)
+	case notExpr:
+		paths(x.x, f)
+	case binaryExpr:
+		paths(x.l, f)
+		paths(x.r, f)
+	}
+}
+
+// parseExpr compiles an expression whose paths start at one of roots.
+func parseExpr(src string, roots []string) (expr, error) {
+	p := &exprParser{src: src, roots: roots}
+	p.next()
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return e, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokError
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type exprParser struct {
+	src   string
+	roots []string
+	pos   int
+	tok   token
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("expression %q at %d: %s", p.src, p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next scans the next token into p.tok.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	case unicode.IsDigit(rune(c)) || c == '-' && p.pos+1 < len(p.src) && unicode.IsDigit(rune(p.src[p.pos+1])):
+		p.pos++
+		for p.pos < len(p.src) && (unicode.IsDigit(rune(p.src[p.pos])) || strings.IndexByte(".eE+-", p.src[p.pos]) >= 0) {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case c == '\'' || c == '"':
+		p.pos++
+		var b strings.Builder
+		for p.pos < len(p.src) && p.src[p.pos] != c {
+			if p.src[p.pos] == '\\' && p.pos+1 < len(p.src) {
+				p.pos++
+			}
+			b.WriteByte(p.src[p.pos])
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			p.tok = token{kind: tokError, text: "unterminated string", pos: start}
+			return
+		}
+		p.pos++
+		p.tok = token{kind: tokString, text: b.String(), pos: start}
+	default:
+		for _, op := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", "."} {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = token{kind: tokOp, text: op, pos: start}
+				return
+			}
+		}
+		p.tok = token{kind: tokError, text: fmt.Sprintf("unexpected character %q", c), pos: start}
+	}
+}
+
+func (p *exprParser) or() (expr, error) {
+	l, err := p.and()
+	for err == nil && p.tok.kind == tokOp && p.tok.text == "||" {
+		p.next()
+		var r expr
+		if r, err = p.and(); err == nil {
+			l = binaryExpr{op: "||", l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *exprParser) and() (expr, error) {
+	l, err := p.comparison()
+	for err == nil && p.tok.kind == tokOp && p.tok.text == "&&" {
+		p.next()
+		var r expr
+		if r, err = p.comparison(); err == nil {
+			l = binaryExpr{op: "&&", l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *exprParser) comparison() (expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.tok.text; {
+	case p.tok.kind == tokOp && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">="):
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryExpr{op: op, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *exprParser) unary() (expr, error) {
+	if p.tok.kind == tokOp && p.tok.text == "!" {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok.text)
+		}
+		p.next()
+		return literalExpr{v: f}, nil
+	case tokString:
+		p.next()
+		return literalExpr{v: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			p.next()
+			return literalExpr{v: tok.text == "true"}, nil
+		case "null":
+			p.next()
+			return literalExpr{v: nil}, nil
+		}
+		return p.path()
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokOp || p.tok.text != ")" {
+				return nil, p.errorf("missing )")
+			}
+			p.next()
+			return e, nil
+		}
+	case tokError:
+		return nil, p.errorf("%s", tok.text)
+	case tokEOF:
+		return nil, p.errorf("unexpected end")
+	}
+	return nil, p.errorf("unexpected %q", tok.text)
+}
+
+func (p *exprParser) path() (expr, error) {
+	start := p.tok.pos
+	root := p.tok.text
+	if !slices.Contains(p.roots, root) {
+		want := strings.Join(p.roots, ", ")
+		if i := strings.LastIndex(want, ", "); i >= 0 {
+			want = want[:i] + " or " + want[i+2:]
+		}
+		return nil, p.errorf("unknown name %q, want %s", root, want)
+	}
+	e := pathExpr{elems: []any{root}}
+	p.next()
+	for p.tok.kind == tokOp && (p.tok.text == "." || p.tok.text == "[") {
+		if p.tok.text == "." {
+			p.next()
+			if p.tok.kind != tokIdent {
+				return nil, p.errorf("expected a name after .")
+			}
+			e.elems = append(e.elems, p.tok.text)
+			p.next()
+			continue
+		}
+
+		p.next()
+		switch p.tok.kind {
+		case tokNumber:
+			i, err := strconv.Atoi(p.tok.text)
+			if err != nil || i < 0 {
+				return nil, p.errorf("invalid index %q", p.tok.text)
+			}
+			e.elems = append(e.elems, i)
+		case tokString:
+			e.elems = append(e.elems, p.tok.text)
+		default:
+			return nil, p.errorf("expected an index or a quoted name in []")
+		}
+		p.next()
+		if p.tok.kind != tokOp || p.tok.text != "]" {
+			return nil, p.errorf("missing ]")
+		}
+		p.next()
+	}
+	e.src = strings.TrimSpace(p.src[start:p.tok.pos])
+	return e, nil
+}
+
+// template is a compiled template: a value in which strings holding ${expression} are
+// replaced. A string that is a single ${expression} becomes the value of the expression,
+// of any type; expressions embedded in longer strings are formatted as text.
+type template struct {
+	literal any
+	// expr is set for strings that are a single expression.
+	expr expr
+	// parts is set for strings embedding expressions; elements are string or expr.
+	parts []any
+	list  []template
+	obj   map[string]template
+}
+
+// compileTemplate compiles v, whose expressions have paths starting at one of roots.
+func compileTemplate(v any, roots []string) (template, error) {
+	switch x := v.(type) {
+	case string:
+		return compileString(x, roots)
+	case []any:
+		t := template{list: make([]template, len(x))}
+		for i, e := range x {
+			var err error
+			if t.list[i], err = compileTemplate(e, roots); err != nil {
+				return template{}, err
+			}
+		}
+		return t, nil
+	case map[string]any:
+		t := template{obj: make(map[string]template, len(x))}
+		for k, e := range x {
+			et, err := compileTemplate(e, roots)
+			if err != nil {
+				return template{}, err
+			}
+			t.obj[k] = et
+		}
+		return t, nil
+	}
+	return template{literal: normalize(v)}, nil
+}
+
+func compileString(s string, roots []string) (template, error) {
+	if !strings.Contains(s, "${") {
+		return template{literal: s}, nil
+	}
+	var parts []any
+	rest := s
+	for {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			if rest != "" {
+				parts = append(parts, rest)
+			}
+			break
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return template{}, fmt.Errorf("unterminated ${ in %q", s)
+		}
+		if i > 0 {
+			parts = append(parts, rest[:i])
+		}
+		e, err := parseExpr(rest[i+2:i+j], roots)
+		if err != nil {
+			return template{}, err
+		}
+		parts = append(parts, e)
+		rest = rest[i+j+1:]
+	}
+	if len(parts) == 1 {
+		if e, ok := parts[0].(expr); ok {
+			return template{expr: e}, nil
+		}
+	}
+	return template{parts: parts}, nil
+}
+
+func (t template) render(env map[string]any) (any, error) {
+	switch {
+	case t.expr != nil:
+		return t.expr.eval(env)
+	case t.parts != nil:
+		var b strings.Builder
+		for _, p := range t.parts {
+			e, ok := p.(expr)
+			if !ok {
+				b.WriteString(p.(string))
+				continue
+			}
+			v, err := e.eval(env)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := v.(string); ok {
+				b.WriteString(s)
+				continue
+			}
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			b.Write(raw)
+		}
+		return b.String(), nil
+	case t.list != nil:
+		out := make([]any, len(t.list))
+		for i, e := range t.list {
+			var err error
+			if out[i], err = e.render(env); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case t.obj != nil:
+		out := make(map[string]any, len(t.obj))
+		for k, e := range t.obj {
+			v, err := e.render(env)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	}
+	return t.literal, nil
+}
+
+// paths calls f with every path in the expressions of t.
+func (t template) paths(f func(pathExpr)) {
+	if t.expr != nil {
+		paths(t.expr, f)
+	}
+	for _, p := range t.parts {
+		if e, ok := p.(expr); ok {
+			paths(e, f)
+		}
+	}
+	for _, e := range t.list {
+		e.paths(f)
+	}
+	for _, e := range t.obj {
+		e.paths(f)
+	}
+}
@@ -0,0 +1,177 @@
+package workflows
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEnv is the state expressions are evaluated against, as decoded from JSON.
+func testEnv() map[string]any {
+	return map[string]any{
+		"input": map[string]any{
+			"name": "bob",
+			"order": map[string]any{
+				"total":   150.0,
+				"items":   []any{map[string]any{"sku": "a"}},
+				"odd key": 1.0,
+			},
+		},
+		"steps": map[string]any{
+			"approval": map[string]any{"output": map[string]any{"approved": true}, "error": nil, "skipped": false},
+		},
+		"instanceId": "inst",
+	}
+}
+
+func TestParseExprEval(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want any
+	}{
+		{`input.order.total > 100`, true},
+		{`input.order.total > 100 && steps.approval.output.approved == true`, true},
+		{`input.order.items[0].sku`, "a"},
+		{`input.order['odd key']`, 1.0},
+		{`input["order"].total`, 150.0},
+		{`instanceId == 'inst'`, true},
+		{`input.missing.deep`, nil},
+		{`input.order.items[5]`, nil},
+		{`input.name[0]`, nil},
+		{`!input.missing`, true},
+		{`null == input.missing`, true},
+		{`1 == 1.0`, true},
+		{`-1.5 < 0`, true},
+		{`'b' > "a"`, true},
+		{`'it\'s' == "it's"`, true},
+		{`input.order != null`, true},
+		{`false && false || true`, true},
+		{`false && (false || true)`, false},
+		{`!(1 == 2)`, true},
+		{`!!input.name`, true},
+		{`input.order.items`, []any{map[string]any{"sku": "a"}}},
+		// && and || short-circuit, so the comparison that would fail is not evaluated.
+		{`true || input.name < 1`, true},
+		{`false && input.name < 1`, false},
+	} {
+		e, err := parseExpr(tc.src, workflowRoots)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tc.src, err)
+			continue
+		}
+		got, err := e.eval(testEnv())
+		if err != nil {
+			t.Errorf("eval(%q): %v", tc.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("eval(%q) = %#v, want %#v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src     string
+		wantErr string
+	}{
+		{`order.total`, `unknown name "order", want input, steps or instanceId`},
+		{`input.`, "expected a name after ."},
+		{`input[`, "expected an index or a quoted name in []"},
+		{`input[0`, "missing ]"},
+		{`input[-1]`, `invalid index "-1"`},
+		{`(1 == 1`, "missing )"},
+		{`'abc`, "unterminated string"},
+		{`1 ==`, "unexpected end"},
+		{`1 2`, `unexpected "2"`},
+		{`1 == 2 == 3`, `unexpected "=="`},
+		{`input.a @ 1`, "unexpected character '@'"},
+		{`1.2.3 > 0`, `invalid number "1.2.3"`},
+		{``, "unexpected end"},
+	} {
+		_, err := parseExpr(tc.src, workflowRoots)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("parseExpr(%q) error = %v, want %q", tc.src, err, tc.wantErr)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src     string
+		wantErr string
+	}{
+		{`input.name < 1`, "<: cannot compare string with number"},
+		{`input.order >= 1`, ">=: cannot compare object with number"},
+		{`input.missing > 'a'`, ">: cannot compare null with string"},
+		{`true && input.order.items < 1`, "cannot compare list with number"},
+	} {
+		e, err := parseExpr(tc.src, workflowRoots)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tc.src, err)
+			continue
+		}
+		_, err = e.eval(testEnv())
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("eval(%q) error = %v, want %q", tc.src, err, tc.wantErr)
+		}
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tmpl any
+		want any
+	}{
+		{"nil", nil, nil},
+		{"plain string", "no expressions", "no expressions"},
+		// Numbers decoded from YAML render like the same numbers decoded from JSON.
+		{"literal number", 3, 3.0},
+		{"single expression keeps its type", "${input.order.total}", 150.0},
+		{"single expression object", "${steps.approval.output}", map[string]any{"approved": true}},
+		{"single expression with spaces", "${ input.name }", "bob"},
+		{"embedded string", "hi ${input.name}!", "hi bob!"},
+		{"embedded number", "total: ${input.order.total}", "total: 150"},
+		{"embedded object as JSON", "approval ${steps.approval.output}", `approval {"approved":true}`},
+		{"embedded null", "x${input.missing}", "xnull"},
+		{"several expressions", "${input.name}/${instanceId}", "bob/inst"},
+		{"list", []any{"${input.name}", 1, "lit"}, []any{"bob", 1.0, "lit"}},
+		{
+			"object",
+			map[string]any{"customer": "${input.name}", "nested": map[string]any{"ok": "${input.order.total > 100}"}},
+			map[string]any{"customer": "bob", "nested": map[string]any{"ok": true}},
+		},
+	} {
+		tmpl, err := compileTemplate(tc.tmpl, workflowRoots)
+		if err != nil {
+			t.Errorf("%s: compile: %v", tc.name, err)
+			continue
+		}
+		got, err := tmpl.render(testEnv())
+		if err != nil {
+			t.Errorf("%s: render: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: render = %#v, want %#v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompileTemplateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		tmpl    any
+		wantErr string
+	}{
+		{"${input.name", "unterminated ${"},
+		{"hi ${order.total}", `unknown name "order"`},
+		{[]any{"ok", "${1 ==}"}, "unexpected end"},
+		{map[string]any{"k": "${input.}"}, "expected a name after ."},
+	} {
+		_, err := compileTemplate(tc.tmpl, workflowRoots)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("compileTemplate(%#v) error = %v, want %q", tc.tmpl, err, tc.wantErr)
+		}
+	}
+}
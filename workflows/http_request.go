@@ -0,0 +1,236 @@
+package workflows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// HTTPRequestInput is the input of HTTPRequest. When Data is set, the URL, header values
+// and Body are templates that read it, as in declarative workflows:
+//
+//	{"url": "https://orders/v1/orders/${data.orderId}", "data": {"orderId": "o-1"}}
+type HTTPRequestInput struct {
+	// Method defaults to GET, or POST when there is a body.
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as is when it is a string, and as JSON otherwise.
+	Body any `json:"body,omitempty"`
+	Data any `json:"data,omitempty"`
+	// Timeout bounds the request, such as "10s". Defaults to 30 seconds.
+	Timeout string `json:"timeout,omitempty"`
+	// ExpectStatus lists the status codes that succeed. Defaults to any 2xx.
+	ExpectStatus []int `json:"expectStatus,omitempty"`
+	// Extract maps names to expressions over the response: status, headers (by canonical
+	// name) and body, decoded when it is JSON. When set, the output holds the extracted
+	// values instead of the body.
+	Extract map[string]string `json:"extract,omitempty"`
+}
+
+// HTTPResponse is the output of HTTPRequest.
+type HTTPResponse struct {
+	Status int            `json:"status"`
+	Body   any            `json:"body,omitempty"`
+	Values map[string]any `json:"values,omitempty"`
+}
+
+const (
+	defaultHTTPRequestTimeout = 30 * time.Second
+	// maxHTTPResponseBody bounds the responses kept in the workflow history.
+	maxHTTPResponseBody = 1 << 20
+)
+
+var (
+	httpDataRoots     = []string{"data"}
+	httpResponseRoots = []string{"status", "headers", "body"}
+)
+
+// httpRequestClient propagates the trace context of the activity to the callee.
+var httpRequestClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// HTTPRequest calls an HTTP endpoint. Unexpected 4xx responses other than 408 and 429 are
+// not retried, and neither are invalid inputs; other failures are.
+func HTTPRequest(ctx workflow.ActivityContext) (any, error) {
+	var in HTTPRequestInput
+	if err := ctx.GetInput(&in); err != nil {
+		return nil, NonRetryable(fmt.Errorf("decode input: %w", err))
+	}
+
+	timeout := defaultHTTPRequestTimeout
+	if in.Timeout != "" {
+		d, err := time.ParseDuration(in.Timeout)
+		if err != nil || d <= 0 {
+			return nil, NonRetryable(fmt.Errorf("invalid timeout %q", in.Timeout))
+		}
+		timeout = d
+	}
+	// Extraction is compiled up front, so that a typo does not wait for a request.
+	extract := make(map[string]expr, len(in.Extract))
+	for name, src := range in.Extract {
+		e, err := parseExpr(src, httpResponseRoots)
+		if err != nil {
+			return nil, NonRetryable(fmt.Errorf("extract %s: %w", name, err))
+		}
+		extract[name] = e
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx.Context(), timeout)
+	defer cancel()
+
+	req, err := in.request(reqCtx)
+	if err != nil {
+		return nil, NonRetryable(err)
+	}
+
+	resp, err := httpRequestClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: read response: %w", req.Method, req.URL.Redacted(), err)
+	}
+	if len(raw) > maxHTTPResponseBody {
+		return nil, NonRetryable(fmt.Errorf("%s %s: response exceeds %d bytes", req.Method, req.URL.Redacted(), maxHTTPResponseBody))
+	}
+
+	if !in.expected(resp.StatusCode) {
+		err := fmt.Errorf("%s %s: unexpected %s%s", req.Method, req.URL.Redacted(), resp.Status, excerpt(raw))
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, NonRetryable(err)
+		}
+		return nil, err
+	}
+
+	out := HTTPResponse{Status: resp.StatusCode, Body: decodeBody(raw)}
+	if len(extract) == 0 {
+		return out, nil
+	}
+	headers := make(map[string]any, len(resp.Header))
+	for k := range resp.Header {
+		headers[k] = resp.Header.Get(k)
+	}
+	env := map[string]any{"status": float64(resp.StatusCode), "headers": headers, "body": out.Body}
+	out.Body = nil
+	out.Values = make(map[string]any, len(extract))
+	for name, e := range extract {
+		var err error
+		if out.Values[name], err = e.eval(env); err != nil {
+			return nil, NonRetryable(fmt.Errorf("extract %s: %w", name, err))
+		}
+	}
+	return out, nil
+}
+
+// request builds the request described by in, rendering its templates.
+func (in HTTPRequestInput) request(ctx context.Context) (*http.Request, error) {
+	render := func(what string, v any) (any, error) {
+		if in.Data == nil {
+			return v, nil
+		}
+		t, err := compileTemplate(v, httpDataRoots)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		out, err := t.render(map[string]any{"data": in.Data})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		return out, nil
+	}
+
+	url, err := render("url", in.URL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render("body", in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		reader      io.Reader
+		contentType string
+	)
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader, contentType = strings.NewReader(b), "text/plain; charset=utf-8"
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("encode body: %w", err)
+		}
+		reader, contentType = bytes.NewReader(raw), "application/json"
+	}
+
+	method := strings.ToUpper(in.Method)
+	if method == "" {
+		method = http.MethodGet
+		if reader != nil {
+			method = http.MethodPost
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprint(url), reader)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range in.Headers {
+		value, err := render("header "+k, v)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, fmt.Sprint(value))
+	}
+	return req, nil
+}
+
+func (in HTTPRequestInput) expected(status int) bool {
+	if len(in.ExpectStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(in.ExpectStatus, status)
+}
+
+// decodeBody returns a JSON body decoded, and any other body as text.
+func decodeBody(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err == nil {
+		return v
+	}
+	return string(raw)
+}
+
+// excerpt quotes the start of a response body for error messages.
+func excerpt(raw []byte) string {
+	const limit = 200
+	s := strings.TrimSpace(string(raw))
+	if s == "" {
+		return ""
+	}
+	if len(s) > limit {
+		s = s[:limit] + "..."
+	}
+	return ": " + s
+}
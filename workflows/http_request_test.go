@@ -0,0 +1,276 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+// fakeActivityContext runs an activity with a JSON-encoded input.
+type fakeActivityContext struct {
+	ctx   context.Context
+	input []byte
+}
+
+func newActivityContext(t *testing.T, input any) *fakeActivityContext {
+	t.Helper()
+	raw, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeActivityContext{ctx: context.Background(), input: raw}
+}
+
+func (c *fakeActivityContext) GetInput(v any) error                  { return json.Unmarshal(c.input, v) }
+func (c *fakeActivityContext) GetTaskID() int32                      { return 1 }
+func (c *fakeActivityContext) GetTaskExecutionID() string            { return "exec-1" }
+func (c *fakeActivityContext) Context() context.Context              { return c.ctx }
+func (c *fakeActivityContext) GetTraceContext() *protos.TraceContext { return nil }
+
+// recordedRequest is what the test server received.
+type recordedRequest struct {
+	method  string
+	path    string
+	headers http.Header
+	body    string
+}
+
+// httpServer serves handler and records the last request it got.
+func httpServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *recordedRequest) {
+	t.Helper()
+	got := &recordedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*got = recordedRequest{method: r.Method, path: r.URL.RequestURI(), headers: r.Header.Clone(), body: string(body)}
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func respond(code int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+func TestHTTPRequestRendersTemplates(t *testing.T) {
+	srv, got := httpServer(t, respond(http.StatusCreated, `{"id":"o-1"}`))
+
+	out, err := HTTPRequest(newActivityContext(t, HTTPRequestInput{
+		URL:     srv.URL + "/orders/${data.orderId}?customer=${data.customer.id}",
+		Headers: map[string]string{"X-Order": "${data.orderId}", "X-Total": "${data.total}", "X-Static": "static"},
+		Body:    map[string]any{"order": "${data.orderId}", "total": "${data.total}", "note": "for ${data.customer.id}"},
+		Data:    map[string]any{"orderId": "o-1", "total": 12.5, "customer": map[string]any{"id": "c-9"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.method != http.MethodPost {
+		t.Errorf("method = %s, want POST for a request with a body", got.method)
+	}
+	if want := "/orders/o-1?customer=c-9"; got.path != want {
+		t.Errorf("path = %s, want %s", got.path, want)
+	}
+	for k, want := range map[string]string{"X-Order": "o-1", "X-Total": "12.5", "X-Static": "static", "Content-Type": "application/json"} {
+		if v := got.headers.Get(k); v != want {
+			t.Errorf("header %s = %q, want %q", k, v, want)
+		}
+	}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(got.body), &body); err != nil {
+		t.Fatalf("decode request body %q: %v", got.body, err)
+	}
+	if want := map[string]any{"order": "o-1", "total": 12.5, "note": "for c-9"}; !reflect.DeepEqual(body, want) {
+		t.Errorf("request body = %v, want %v", body, want)
+	}
+
+	resp := out.(HTTPResponse)
+	if resp.Status != http.StatusCreated || !reflect.DeepEqual(resp.Body, map[string]any{"id": "o-1"}) {
+		t.Errorf("output = %+v", resp)
+	}
+}
+
+func TestHTTPRequestWithoutData(t *testing.T) {
+	srv, got := httpServer(t, respond(http.StatusOK, "plain"))
+
+	// Without data, ${...} is sent as is.
+	out, err := HTTPRequest(newActivityContext(t, HTTPRequestInput{
+		Method: "put",
+		URL:    srv.URL + "/raw",
+		Body:   "${not.a.template}",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.method != http.MethodPut || got.body != "${not.a.template}" {
+		t.Errorf("request = %s %q", got.method, got.body)
+	}
+	if ct := got.headers.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain for a string body", ct)
+	}
+	if resp := out.(HTTPResponse); resp.Body != "plain" {
+		t.Errorf("body = %#v, want the text of a non-JSON response", resp.Body)
+	}
+}
+
+func TestHTTPRequestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		code      int
+		expect    []int
+		wantErr   bool
+		retryable bool
+	}{
+		{name: "2xx", code: http.StatusNoContent},
+		{name: "expected 404", code: http.StatusNotFound, expect: []int{200, 404}},
+		{name: "2xx not expected", code: http.StatusOK, expect: []int{202}, wantErr: true, retryable: true},
+		{name: "400", code: http.StatusBadRequest, wantErr: true},
+		{name: "404", code: http.StatusNotFound, wantErr: true},
+		{name: "409", code: http.StatusConflict, wantErr: true},
+		{name: "408", code: http.StatusRequestTimeout, wantErr: true, retryable: true},
+		{name: "429", code: http.StatusTooManyRequests, wantErr: true, retryable: true},
+		{name: "500", code: http.StatusInternalServerError, wantErr: true, retryable: true},
+		{name: "503", code: http.StatusServiceUnavailable, wantErr: true, retryable: true},
+	} {
+		srv, _ := httpServer(t, respond(tc.code, "details"))
+		out, err := HTTPRequest(newActivityContext(t, HTTPRequestInput{URL: srv.URL, ExpectStatus: tc.expect}))
+		if !tc.wantErr {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			} else if resp := out.(HTTPResponse); resp.Status != tc.code {
+				t.Errorf("%s: status = %d, want %d", tc.name, resp.Status, tc.code)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: got no error", tc.name)
+			continue
+		}
+		if RetryableError(err) != tc.retryable {
+			t.Errorf("%s: retryable = %t, want %t: %v", tc.name, !tc.retryable, tc.retryable, err)
+		}
+		if !strings.Contains(err.Error(), "details") {
+			t.Errorf("%s: error %q does not quote the response", tc.name, err)
+		}
+	}
+}
+
+func TestHTTPRequestResponseLimit(t *testing.T) {
+	for _, tc := range []struct {
+		size    int
+		wantErr bool
+	}{
+		{size: maxHTTPResponseBody},
+		{size: maxHTTPResponseBody + 1, wantErr: true},
+	} {
+		srv, _ := httpServer(t, respond(http.StatusOK, strings.Repeat("x", tc.size)))
+		out, err := HTTPRequest(newActivityContext(t, HTTPRequestInput{URL: srv.URL}))
+		if !tc.wantErr {
+			if err != nil {
+				t.Errorf("%d bytes: %v", tc.size, err)
+			} else if body, _ := out.(HTTPResponse).Body.(string); len(body) != tc.size {
+				t.Errorf("%d bytes: got a body of %d", tc.size, len(body))
+			}
+			continue
+		}
+		if err == nil || RetryableError(err) || !strings.Contains(err.Error(), "response exceeds") {
+			t.Errorf("%d bytes: error = %v, want a non-retryable size error", tc.size, err)
+		}
+	}
+}
+
+func TestHTTPRequestExtract(t *testing.T) {
+	srv, _ := httpServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/orders/o-1")
+		_, _ = io.WriteString(w, `{"order":{"id":"o-1","items":[{"sku":"a"}],"total":150}}`)
+	})
+
+	out, err := HTTPRequest(newActivityContext(t, HTTPRequestInput{
+		URL: srv.URL,
+		Extract: map[string]string{
+			"id":       "body.order.id",
+			"sku":      "body.order.items[0].sku",
+			"big":      "body.order.total > 100",
+			"location": "headers.Location",
+			"ok":       "status == 200",
+			"missing":  "body.nothing.here",
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := out.(HTTPResponse)
+	want := map[string]any{"id": "o-1", "sku": "a", "big": true, "location": "/orders/o-1", "ok": true, "missing": nil}
+	if !reflect.DeepEqual(resp.Values, want) {
+		t.Errorf("values = %v, want %v", resp.Values, want)
+	}
+	if resp.Body != nil {
+		t.Errorf("body = %v, want none when extracting", resp.Body)
+	}
+}
+
+func TestHTTPRequestInvalidInput(t *testing.T) {
+	requested := false
+	srv, _ := httpServer(t, func(w http.ResponseWriter, _ *http.Request) { requested = true })
+
+	for _, tc := range []struct {
+		name    string
+		in      HTTPRequestInput
+		wantErr string
+	}{
+		{"timeout", HTTPRequestInput{URL: srv.URL, Timeout: "soon"}, `invalid timeout "soon"`},
+		{"extract", HTTPRequestInput{URL: srv.URL, Extract: map[string]string{"x": "input.x"}}, "extract x"},
+		{"url template", HTTPRequestInput{URL: srv.URL + "/${data.", Data: map[string]any{}}, "url: unterminated ${"},
+		{"header template", HTTPRequestInput{URL: srv.URL, Headers: map[string]string{"X": "${input.x}"}, Data: map[string]any{}}, "header X"},
+		{"url", HTTPRequestInput{URL: "://nowhere"}, "invalid request"},
+	} {
+		_, err := HTTPRequest(newActivityContext(t, tc.in))
+		if err == nil || RetryableError(err) || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error = %v, want a non-retryable %q", tc.name, err, tc.wantErr)
+		}
+	}
+	if requested {
+		t.Errorf("an invalid input was sent")
+	}
+}
+
+func TestHTTPRequestPropagatesTraceContext(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	srv, got := httpServer(t, respond(http.StatusOK, ""))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	actx := newActivityContext(t, HTTPRequestInput{URL: srv.URL})
+	actx.ctx = trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	if _, err := HTTPRequest(actx); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(),
+		propagation.HeaderCarrier(got.headers)))
+	if !sc.IsValid() || sc.TraceID() != traceID {
+		t.Errorf("traceparent %q does not continue trace %s", got.headers.Get("Traceparent"), traceID)
+	}
+}
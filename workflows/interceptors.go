@@ -0,0 +1,195 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// ActivityInterceptor wraps an activity with cross-cutting behaviour. name is the name the
+// activity is registered under.
+type ActivityInterceptor func(name string, next workflow.Activity) workflow.Activity
+
+// ChainActivityInterceptors composes interceptors so that the first one is the outermost.
+func ChainActivityInterceptors(interceptors ...ActivityInterceptor) ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next = interceptors[i](name, next)
+		}
+		return next
+	}
+}
+
+// DefaultActivityInterceptors is the chain applied to every registered activity. Tenant
+// unwrapping sits outermost so that every other interceptor sees the activity's own input.
+// Recovery sits innermost so that panics are converted before the timeout goroutine boundary.
+func DefaultActivityInterceptors(timeout time.Duration, perActivity map[string]time.Duration) []ActivityInterceptor {
+	return []ActivityInterceptor{
+		TenantInterceptor(),
+		LoggingInterceptor(),
+		MetricsInterceptor(),
+		TracingInterceptor(),
+		TimeoutInterceptor(timeout, perActivity),
+		RecoverInterceptor(),
+	}
+}
+
+// activityContext overrides the context of an ActivityContext.
+type activityContext struct {
+	workflow.ActivityContext
+	ctx context.Context
+}
+
+func (a activityContext) Context() context.Context {
+	return a.ctx
+}
+
+// LoggingInterceptor logs the start, duration and outcome of every activity execution.
+func LoggingInterceptor() ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		return func(ctx workflow.ActivityContext) (any, error) {
+			log.Infof("Activity %s (task %d) started with traceparent: %s", name, ctx.GetTaskID(), ctx.GetTraceContext().GetTraceParent())
+			start := time.Now()
+
+			out, err := next(ctx)
+			if err != nil {
+				log.Errorf("Activity %s (task %d) failed after %s: %v", name, ctx.GetTaskID(), time.Since(start), err)
+				return out, err
+			}
+
+			log.Infof("Activity %s (task %d) finished in %s", name, ctx.GetTaskID(), time.Since(start))
+			return out, nil
+		}
+	}
+}
+
+// TracingInterceptor wraps the activity in a "Custom||<name>" span whose context is
+// passed down to the activity. The span records the tenant found in the baggage.
+func TracingInterceptor() ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		return func(ctx workflow.ActivityContext) (any, error) {
+			spanCtx, span := tracer.Start(ctx.Context(), "Custom||"+name)
+			defer span.End()
+			if t := tenant.FromBaggage(spanCtx); t != "" {
+				span.SetAttributes(attribute.String("tenant.id", t))
+			}
+
+			out, err := next(activityContext{ActivityContext: ctx, ctx: spanCtx})
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return out, err
+		}
+	}
+}
+
+// MetricsInterceptor records an execution counter and a duration histogram per activity
+// and outcome on the global meter provider.
+func MetricsInterceptor() ActivityInterceptor {
+	meter := otel.Meter("workflows.activities")
+	executions, _ := meter.Int64Counter("activity.executions",
+		metric.WithDescription("Number of activity executions"))
+	duration, _ := meter.Float64Histogram("activity.duration",
+		metric.WithDescription("Duration of activity executions"),
+		metric.WithUnit("s"))
+
+	return func(name string, next workflow.Activity) workflow.Activity {
+		return func(ctx workflow.ActivityContext) (any, error) {
+			start := time.Now()
+			out, err := next(ctx)
+
+			outcome := "success"
+			if err != nil {
+				outcome = "error"
+			}
+			attrs := metric.WithAttributes(
+				attribute.String("activity", name),
+				attribute.String("outcome", outcome),
+			)
+			executions.Add(ctx.Context(), 1, attrs)
+			duration.Record(ctx.Context(), time.Since(start).Seconds(), attrs)
+			return out, err
+		}
+	}
+}
+
+// ErrActivityTimeout is returned when an activity exceeds its deadline.
+var ErrActivityTimeout = errors.New("activity timed out")
+
+// TimeoutInterceptor bounds every activity by a deadline, taken from perActivity when the
+// activity is listed there and from timeout otherwise. A non-positive deadline disables it.
+// The activity's context is cancelled on expiry; activities that ignore it keep running in
+// the background, but the workflow sees ErrActivityTimeout straight away.
+func TimeoutInterceptor(timeout time.Duration, perActivity map[string]time.Duration) ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		d := timeout
+		if override, ok := perActivity[name]; ok {
+			d = override
+		}
+		if d <= 0 {
+			return next
+		}
+
+		return func(ctx workflow.ActivityContext) (any, error) {
+			tctx, cancel := context.WithTimeout(ctx.Context(), d)
+			defer cancel()
+
+			type result struct {
+				out      any
+				err      error
+				panicked any
+			}
+			done := make(chan result, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- result{panicked: r}
+					}
+				}()
+				out, err := next(activityContext{ActivityContext: ctx, ctx: tctx})
+				done <- result{out: out, err: err}
+			}()
+
+			select {
+			case res := <-done:
+				if res.panicked != nil {
+					// Re-raise on the caller's goroutine so outer interceptors see it.
+					panic(res.panicked)
+				}
+				return res.out, res.err
+			case <-tctx.Done():
+				if !errors.Is(tctx.Err(), context.DeadlineExceeded) {
+					return nil, tctx.Err()
+				}
+				return nil, fmt.Errorf("%w: %s exceeded %s", ErrActivityTimeout, name, d)
+			}
+		}
+	}
+}
+
+// RecoverInterceptor converts a panic in the activity into an error carrying the stack trace.
+func RecoverInterceptor() ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		return func(ctx workflow.ActivityContext) (out any, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					out = nil
+					err = fmt.Errorf("activity %s panicked: %v\n%s", name, r, debug.Stack())
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// recordingInterceptor appends "<label> <name>" to calls on the way in and "<label> done"
+// on the way out.
+func recordingInterceptor(label string, calls *[]string) ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		return func(ctx workflow.ActivityContext) (any, error) {
+			*calls = append(*calls, label+" "+name)
+			out, err := next(ctx)
+			*calls = append(*calls, label+" done")
+			return out, err
+		}
+	}
+}
+
+func TestChainActivityInterceptors(t *testing.T) {
+	var calls []string
+	chain := ChainActivityInterceptors(
+		recordingInterceptor("outer", &calls),
+		recordingInterceptor("middle", &calls),
+		recordingInterceptor("inner", &calls),
+	)
+	activity := chain("Charge", func(workflow.ActivityContext) (any, error) {
+		calls = append(calls, "activity")
+		return "ok", nil
+	})
+
+	out, err := activity(newActivityContext(t, nil))
+	if out != "ok" || err != nil {
+		t.Fatalf("activity returned %v, %v", out, err)
+	}
+	want := []string{"outer Charge", "middle Charge", "inner Charge", "activity", "inner done", "middle done", "outer done"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %v, want %v", calls, want)
+	}
+
+	// An empty chain leaves the activity as is.
+	calls = nil
+	if _, err := ChainActivityInterceptors()("Charge", func(workflow.ActivityContext) (any, error) { return nil, nil })(newActivityContext(t, nil)); err != nil || calls != nil {
+		t.Errorf("empty chain: %v, calls %v", err, calls)
+	}
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	// blocking waits for its context, and reports how it ended.
+	blocking := func(ctx workflow.ActivityContext) (any, error) {
+		select {
+		case <-ctx.Context().Done():
+			return nil, ctx.Context().Err()
+		case <-time.After(50 * time.Millisecond):
+			return "finished", nil
+		}
+	}
+	timeouts := TimeoutInterceptor(10*time.Millisecond, map[string]time.Duration{
+		"Slow":      5 * time.Second,
+		"Unbounded": 0,
+	})
+
+	for _, tc := range []struct {
+		name    string
+		wantOut any
+		wantErr error
+	}{
+		{name: "Charge", wantErr: ErrActivityTimeout},
+		// Per-activity deadlines replace the default, and zero disables it.
+		{name: "Slow", wantOut: "finished"},
+		{name: "Unbounded", wantOut: "finished"},
+	} {
+		out, err := timeouts(tc.name, blocking)(newActivityContext(t, nil))
+		if out != tc.wantOut || !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: %v, %v, want %v, %v", tc.name, out, err, tc.wantOut, tc.wantErr)
+		}
+	}
+
+	// The workflow sees the timeout straight away, even when the activity ignores its context.
+	start := time.Now()
+	_, err := timeouts("Stubborn", func(workflow.ActivityContext) (any, error) {
+		time.Sleep(200 * time.Millisecond)
+		return nil, nil
+	})(newActivityContext(t, nil))
+	if !errors.Is(err, ErrActivityTimeout) || time.Since(start) > 150*time.Millisecond {
+		t.Errorf("stubborn activity: %v after %s, want %v right after the deadline", err, time.Since(start), ErrActivityTimeout)
+	}
+
+	// Cancelling the caller is not reported as a timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = TimeoutInterceptor(time.Minute, nil)("Charge", blocking)(&fakeActivityContext{ctx: ctx})
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrActivityTimeout) {
+		t.Errorf("cancelled activity: %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRecoverInterceptor(t *testing.T) {
+	panicking := func(workflow.ActivityContext) (any, error) { panic("boom") }
+
+	out, err := RecoverInterceptor()("Charge", panicking)(newActivityContext(t, nil))
+	if out != nil || err == nil || !strings.Contains(err.Error(), "activity Charge panicked: boom") {
+		t.Errorf("recovered %v, %v, want the panic as an error", out, err)
+	}
+
+	// In the default chain, recovery sits inside the timeout goroutine.
+	chain := ChainActivityInterceptors(DefaultActivityInterceptors(time.Minute, nil)...)
+	if _, err := chain("Charge", panicking)(newActivityContext(t, nil)); err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+		t.Errorf("default chain: %v, want the panic as an error", err)
+	}
+
+	// Without recovery, the timeout interceptor re-raises the panic on the caller's goroutine.
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want the activity's panic", r)
+		}
+	}()
+	_, _ = TimeoutInterceptor(time.Minute, nil)("Charge", panicking)(newActivityContext(t, nil))
+	t.Error("the panic was swallowed")
+}
+
+func TestTenantInterceptor(t *testing.T) {
+	type order struct {
+		ID string `json:"id"`
+	}
+	for _, tc := range []struct {
+		name       string
+		input      any
+		wantTenant string
+		wantOrder  order
+	}{
+		{name: "tenant input", input: tenantInput{Tenant: "acme", Input: order{ID: "1"}}, wantTenant: "acme", wantOrder: order{ID: "1"}},
+		{name: "tenant without input", input: tenantInput{Tenant: "acme"}, wantTenant: "acme"},
+		{name: "plain input", input: order{ID: "2"}, wantOrder: order{ID: "2"}},
+		{name: "not an object", input: "text"},
+	} {
+		var gotTenant string
+		var gotOrder order
+		activity := TenantInterceptor()("Charge", func(ctx workflow.ActivityContext) (any, error) {
+			gotTenant = tenant.FromContext(ctx.Context())
+			_ = ctx.GetInput(&gotOrder)
+			return nil, nil
+		})
+		if _, err := activity(newActivityContext(t, tc.input)); err != nil {
+			t.Fatal(err)
+		}
+		if gotTenant != tc.wantTenant || gotOrder != tc.wantOrder {
+			t.Errorf("%s: tenant %q and input %+v, want %q and %+v", tc.name, gotTenant, gotOrder, tc.wantTenant, tc.wantOrder)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dapr/durabletask-go/task"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// Interpret returns a workflow that runs def. Steps run in order on the workflow context,
+// so replays take the same path as long as def does not change: deploy a changed
+// definition under a new name while instances of the old one are in flight.
+//
+// The input of the workflow is available to expressions as input, and the outcome of
+// every step that ran as steps.<id>.output, steps.<id>.error and steps.<id>.skipped.
+func Interpret(def *Definition) workflow.Workflow {
+	return func(ctx *workflow.WorkflowContext) (any, error) {
+		var input any
+		if err := ctx.GetInput(&input); err != nil {
+			return nil, fmt.Errorf("decode input: %w", err)
+		}
+		r := &interpreter{
+			ctx:   ctx,
+			steps: make(map[string]any),
+		}
+		r.env = map[string]any{"input": input, "steps": r.steps, "instanceId": ctx.ID()}
+
+		for i, s := range def.Steps {
+			if err := setProgress(ctx, Progress{
+				Step:            s.name(),
+				PercentComplete: i * 100 / len(def.Steps),
+				WaitingFor:      waitingFor(s),
+			}); err != nil {
+				return nil, err
+			}
+			if err := r.run(s); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := setProgress(ctx, Progress{Step: "Completed", PercentComplete: 100}); err != nil {
+			return nil, err
+		}
+		out, err := def.output.render(r.env)
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		return out, nil
+	}
+}
+
+// waitingFor returns the event a top-level step waits for, for the custom status.
+func waitingFor(s *Step) string {
+	if s.Event != nil {
+		return s.Event.Name
+	}
+	return ""
+}
+
+// interpreter is the state of one execution of a definition.
+type interpreter struct {
+	ctx *workflow.WorkflowContext
+	env map[string]any
+	// steps holds the outcome of every step with an ID that ran, keyed by ID.
+	steps map[string]any
+}
+
+// run runs a step that is not part of a parallel branch.
+func (r *interpreter) run(s *Step) error {
+	ok, err := r.enabled(s)
+	if err != nil || !ok {
+		return err
+	}
+	if s.kind() == stepParallel {
+		return r.parallel(s)
+	}
+	t, err := r.schedule(s)
+	if err != nil {
+		return r.finish(s, nil, err)
+	}
+	out, err := await(s, t)
+	return r.finish(s, out, err)
+}
+
+// enabled evaluates the condition of s, and records s as skipped when it is false.
+func (r *interpreter) enabled(s *Step) (bool, error) {
+	if s.cond == nil {
+		return true, nil
+	}
+	v, err := s.cond.eval(r.env)
+	if err != nil {
+		return false, fmt.Errorf("step %s: if: %w", s.name(), err)
+	}
+	if truthy(v) {
+		return true, nil
+	}
+	if s.ID != "" {
+		r.steps[s.ID] = map[string]any{"output": nil, "error": nil, "skipped": true}
+	}
+	return false, nil
+}
+
+// schedule starts the task of an activity, workflow, event or timer step.
+func (r *interpreter) schedule(s *Step) (workflow.Task, error) {
+	switch s.kind() {
+	case stepActivity, stepWorkflow:
+		input, err := s.input.render(r.env)
+		if err != nil {
+			return nil, fmt.Errorf("input: %w", err)
+		}
+		if s.kind() == stepActivity {
+			var opts []workflow.CallActivityOption
+			if s.Retry != nil {
+				opts = append(opts, workflow.WithActivityRetryPolicy(retryPolicy(s.Retry)))
+			}
+			return callActivity(r.ctx, s.Activity, input, opts...), nil
+		}
+		var opts []workflow.ChildWorkflowOption
+		if input != nil {
+			opts = append(opts, workflow.WithChildWorkflowInput(input))
+		}
+		if s.Retry != nil {
+			opts = append(opts, workflow.WithChildWorkflowRetryPolicy(retryPolicy(s.Retry)))
+		}
+		return r.ctx.CallChildWorkflow(s.Workflow, opts...), nil
+	case stepEvent:
+		// A negative timeout waits forever.
+		timeout := s.Event.Timeout
+		if timeout == 0 {
+			timeout = -1
+		}
+		return r.ctx.WaitForExternalEvent(s.Event.Name, timeout), nil
+	case stepTimer:
+		return r.ctx.CreateTimer(s.Timer), nil
+	}
+	return nil, fmt.Errorf("cannot schedule a %s step", s.kind())
+}
+
+func retryPolicy(r *StepRetry) *workflow.RetryPolicy {
+	return &workflow.RetryPolicy{
+		MaxAttempts:          r.MaxAttempts,
+		InitialRetryInterval: r.InitialInterval,
+		BackoffCoefficient:   r.BackoffCoefficient,
+		MaxRetryInterval:     r.MaxInterval,
+		RetryTimeout:         r.Timeout,
+		Handle:               RetryableError,
+	}
+}
+
+// await waits for the task of s and decodes its output.
+func await(s *Step, t workflow.Task) (any, error) {
+	var raw json.RawMessage
+	err := t.Await(&raw)
+	if errors.Is(err, task.ErrTaskCanceled) && s.Event != nil {
+		return nil, fmt.Errorf("no %s event within %s", s.Event.Name, s.Event.Timeout)
+	}
+	if err != nil || len(raw) == 0 {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("decode output: %w", err)
+	}
+	return out, nil
+}
+
+// finish records the outcome of s. A failure is returned unless s continues on error.
+func (r *interpreter) finish(s *Step, out any, err error) error {
+	outcome := map[string]any{"output": out, "error": nil, "skipped": false}
+	if err != nil {
+		if !s.ContinueOnError {
+			return fmt.Errorf("step %s: %w", s.name(), err)
+		}
+		if !r.ctx.IsReplaying() {
+			log.Warnf("Workflow %s: step %s failed, continuing: %v", r.ctx.ID(), s.name(), err)
+		}
+		outcome["output"] = nil
+		outcome["error"] = err.Error()
+	}
+	if s.ID != "" {
+		r.steps[s.ID] = outcome
+	}
+	return nil
+}
+
+// branch is a parallel branch in progress.
+type branch struct {
+	steps []*Step
+	next  int
+	// current is the step whose task is pending, if any.
+	current *Step
+	pending workflow.Task
+	// last is the output of the last step the branch ran.
+	last any
+}
+
+// parallel runs the branches of s side by side. Tasks can only be awaited one at a time,
+// so the branches take turns: each awaits its pending step, in branch order, and schedules
+// its next one straight away. Steps that finish meanwhile are picked up on their turn.
+func (r *interpreter) parallel(s *Step) error {
+	branches := make([]*branch, len(s.Parallel))
+	for i, b := range s.Parallel {
+		branches[i] = &branch{steps: b.Steps}
+		if err := r.advance(branches[i]); err != nil {
+			return r.finish(s, nil, err)
+		}
+	}
+
+	for {
+		waiting := false
+		for _, b := range branches {
+			if b.pending == nil {
+				continue
+			}
+			waiting = true
+			step := b.current
+			out, err := await(step, b.pending)
+			b.current, b.pending = nil, nil
+			if err := r.finish(step, out, err); err != nil {
+				return r.finish(s, nil, err)
+			}
+			b.last = out
+			if err := r.advance(b); err != nil {
+				return r.finish(s, nil, err)
+			}
+		}
+		if !waiting {
+			break
+		}
+	}
+
+	outs := make([]any, len(branches))
+	for i, b := range branches {
+		outs[i] = b.last
+	}
+	return r.finish(s, outs, nil)
+}
+
+// advance schedules the next step of b that is enabled, unless one is pending.
+func (r *interpreter) advance(b *branch) error {
+	for b.pending == nil && b.next < len(b.steps) {
+		s := b.steps[b.next]
+		b.next++
+		ok, err := r.enabled(s)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		t, err := r.schedule(s)
+		if err != nil {
+			if err := r.finish(s, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		b.current, b.pending = s, t
+	}
+	return nil
+}
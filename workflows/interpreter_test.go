@@ -0,0 +1,185 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+// chainActivity is the fake result of the activities of parallelDefinition: its input with
+// its name appended.
+func chainActivity(ts *protos.TaskScheduledEvent) string {
+	var in string
+	_ = json.Unmarshal([]byte(ts.GetInput().GetValue()), &in)
+	return in + ">" + ts.Name
+}
+
+const parallelDefinition = `
+steps:
+  - id: fan
+    parallel:
+      - steps:
+          - id: a1
+            activity: A1
+            input: ${input.a}
+          - id: a2
+            activity: A2
+            input: ${steps.a1.output}
+      - steps:
+          - id: b1
+            activity: B1
+            input: ${input.b}
+          - id: skipped
+            if: steps.b1.output == 'never'
+            activity: Never
+          - id: b2
+            activity: B2
+            input: ${steps.b1.output}
+  - id: done
+    activity: Done
+    input: ${steps.fan.output[0]}
+output:
+  fan: ${steps.fan.output}
+  done: ${steps.done.output}
+  skipped: ${steps.skipped.skipped}
+`
+
+// TestInterpretParallelReplay checks that the branches of a parallel step schedule the same
+// tasks, with the same IDs, whichever order their activities complete in, and that
+// replaying the recorded history from scratch reaches the same result.
+func TestInterpretParallelReplay(t *testing.T) {
+	def, err := ParseDefinition([]byte(parallelDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := map[string]func(ids []int32) int32{
+		"first scheduled first": func(ids []int32) int32 { return ids[0] },
+		"last scheduled first":  func(ids []int32) int32 { return ids[len(ids)-1] },
+	}
+	var (
+		wantScheduled []string
+		wantResult    string
+	)
+	for name, next := range orders {
+		o := newOrchestration(t, "Parallel", Interpret(def))
+		o.start("Parallel", map[string]any{"a": "a", "b": "b"})
+		for o.result == nil {
+			ids := o.pendingIDs()
+			if len(ids) == 0 {
+				t.Fatalf("%s: workflow is stuck", name)
+			}
+			id := next(ids)
+			o.complete(id, chainActivity(o.pending[id]))
+		}
+		if o.result.OrchestrationStatus != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+			t.Fatalf("%s: workflow ended %s: %v", name, o.result.OrchestrationStatus, o.result.FailureDetails)
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &got); err != nil {
+			t.Fatalf("%s: decode result: %v", name, err)
+		}
+		want := map[string]any{
+			"fan":     []any{"a>A1>A2", "b>B1>B2"},
+			"done":    "a>A1>A2>Done",
+			"skipped": true,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: result = %v, want %v", name, got, want)
+		}
+
+		scheduled := o.scheduled()
+		if wantScheduled == nil {
+			wantScheduled, wantResult = scheduled, o.result.GetResult().GetValue()
+		} else if !reflect.DeepEqual(scheduled, wantScheduled) {
+			t.Errorf("%s: scheduled %v, want %v", name, scheduled, wantScheduled)
+		}
+
+		// A worker that lost the workflow replays its whole history in one go.
+		replay := startWorker(t, "Parallel", Interpret(def))
+		last := len(o.history) - 1
+		for o.history[last].GetOrchestratorStarted() == nil {
+			last--
+		}
+		resp := replay.execute(t, o.history[:last], o.history[last:])
+		if len(resp.Actions) != 1 || resp.Actions[0].GetCompleteOrchestration().GetResult().GetValue() != wantResult {
+			t.Errorf("%s: replay actions %v, want completion with %s", name, resp.Actions, wantResult)
+		}
+	}
+	if want := 5; len(wantScheduled) != want {
+		t.Errorf("scheduled %v, want %d activities", wantScheduled, want)
+	}
+}
+
+const waitingDefinition = `
+steps:
+  - id: pause
+    timer: 30s
+  - id: approval
+    event:
+      name: approval
+  - id: charge
+    activity: Charge
+    continueOnError: true
+  - id: notify
+    activity: Notify
+    input: ${steps.charge.error}
+output:
+  approved: ${steps.approval.output.approved}
+  notified: ${steps.notify.output}
+`
+
+// TestInterpretWaitsAndContinuesOnError checks that timer and event steps wait for their
+// timer and event, and that a step continuing on error hands its error to later steps.
+func TestInterpretWaitsAndContinuesOnError(t *testing.T) {
+	def, err := ParseDefinition([]byte(waitingDefinition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newOrchestration(t, "Waiting", Interpret(def))
+	o.start("Waiting", nil)
+
+	if len(o.timers) != 1 || len(o.pending) != 0 {
+		t.Fatalf("timers %v and activities %v, want only the pause", o.timers, o.pendingNames())
+	}
+	started := o.clock
+	for id, fireAt := range o.timers {
+		if d := fireAt.Sub(started); d < 29*time.Second || d > 30*time.Second {
+			t.Errorf("timer fires %s after the start, want 30s", d)
+		}
+		o.fire(id)
+	}
+
+	// The event step waits without a timeout, so without a timer.
+	if len(o.timers) != 0 || len(o.pending) != 0 {
+		t.Fatalf("timers %v and activities %v while waiting for the event", o.timers, o.pendingNames())
+	}
+	o.raise("approval", map[string]any{"approved": true})
+
+	expectPending(t, o, "Charge")
+	o.fail(o.pendingIDs()[0], errors.New("card declined"))
+	expectPending(t, o, "Notify")
+	id := o.pendingIDs()[0]
+	var notifyInput string
+	if err := json.Unmarshal([]byte(o.pending[id].GetInput().GetValue()), &notifyInput); err != nil || !strings.Contains(notifyInput, "card declined") {
+		t.Errorf("Notify input %s, want the error of charge", o.pending[id].GetInput().GetValue())
+	}
+	o.complete(id, "sent")
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+		t.Fatalf("workflow ended %s: %v", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"approved": true, "notified": "sent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("result = %v, want %v", got, want)
+	}
+}
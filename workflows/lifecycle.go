@@ -0,0 +1,82 @@
+package workflows
+
+import (
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/lifecycle"
+)
+
+// LifecycleInput is the input of PublishLifecycleEvent.
+type LifecycleInput struct {
+	Type string         `json:"type"`
+	Data lifecycle.Data `json:"data"`
+}
+
+var lifecycleRetryPolicy = &workflow.RetryPolicy{
+	MaxAttempts:          5,
+	InitialRetryInterval: time.Second,
+	BackoffCoefficient:   2,
+	MaxRetryInterval:     30 * time.Second,
+}
+
+// lifecycleEventsPatch marks the instances that publish lifecycle events.
+const lifecycleEventsPatch = "lifecycle-events"
+
+// WithLifecycleEvents wraps wf so that it publishes workflow.started when it begins and
+// workflow.completed or workflow.failed when it returns. Publishing runs as an activity, so
+// notifications survive restarts like the rest of the workflow. A notification that cannot
+// be delivered is logged and never changes the outcome of the workflow.
+//
+// The activities are scheduled whether publishing is enabled or not, and do nothing when it
+// is not, so that the history of an instance does not depend on the config of the app that
+// replays it. Instances started before the wrapper was added are told apart by a patch.
+//
+// Workflows that continue as new must not be wrapped: every generation would report
+// itself as started and completed.
+func WithLifecycleEvents(wf workflow.Workflow) workflow.Workflow {
+	return func(ctx *workflow.WorkflowContext) (any, error) {
+		if !ctx.IsPatched(lifecycleEventsPatch) {
+			return wf(ctx)
+		}
+
+		publishLifecycle(ctx, lifecycle.TypeStarted, lifecycle.Data{Status: "RUNNING"})
+
+		out, err := wf(ctx)
+		if err != nil {
+			publishLifecycle(ctx, lifecycle.TypeFailed, lifecycle.Data{Status: "FAILED", Error: err.Error()})
+			return nil, err
+		}
+
+		publishLifecycle(ctx, lifecycle.TypeCompleted, lifecycle.Data{Status: "COMPLETED"})
+		return out, nil
+	}
+}
+
+func publishLifecycle(ctx *workflow.WorkflowContext, typ string, data lifecycle.Data) {
+	data.InstanceID = ctx.ID()
+	data.Workflow = ctx.Name()
+
+	err := callActivity(ctx, PublishLifecycleEvent,
+		LifecycleInput{Type: typ, Data: data},
+		workflow.WithActivityRetryPolicy(lifecycleRetryPolicy),
+	).Await(nil)
+	if err != nil && !ctx.IsReplaying() {
+		log.Errorf("Failed to publish %s for workflow %s: %v", typ, ctx.ID(), err)
+	}
+}
+
+// PublishLifecycleEvent sends a lifecycle CloudEvent to the configured sink, and does nothing
+// when there is none. The event ID is the task execution ID, which is stable across
+// retries, so consumers can deduplicate.
+func PublishLifecycleEvent(ctx workflow.ActivityContext) (any, error) {
+	var in LifecycleInput
+	if err := ctx.GetInput(&in); err != nil {
+		return nil, err
+	}
+
+	ev := lifecycle.New(in.Type, in.Data).WithTraceContext(ctx.Context())
+	ev.ID = ctx.GetTaskExecutionID()
+	return nil, lifecycle.Publish(ctx.Context(), ev)
+}
@@ -0,0 +1,137 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/javier-aliaga/dapr-go-samples/lifecycle"
+)
+
+// lifecycleTestWorkflow runs the Inner activity and returns its output.
+var lifecycleTestWorkflow = WithLifecycleEvents(func(ctx *workflow.WorkflowContext) (any, error) {
+	var out string
+	if err := ctx.CallActivity("Inner").Await(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+})
+
+// expectLifecycle fails the test unless the activity pending is PublishLifecycleEvent for
+// an event of type typ, and returns its ID.
+func expectLifecycle(t *testing.T, o *orchestration, typ string) int32 {
+	t.Helper()
+	expectPending(t, o, "PublishLifecycleEvent")
+	id := o.pendingIDs()[0]
+	var in LifecycleInput
+	if err := json.Unmarshal([]byte(o.pending[id].GetInput().GetValue()), &in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Type != typ || in.Data.InstanceID != testInstanceID || in.Data.Workflow != "LifecycleTest" {
+		t.Fatalf("published %+v, want %s for %s", in, typ, testInstanceID)
+	}
+	return id
+}
+
+// failLifecycle fails every attempt at publishing an event of type typ, and returns how
+// many there were.
+func failLifecycle(t *testing.T, o *orchestration, typ string) int {
+	t.Helper()
+	for attempts := 1; ; attempts++ {
+		o.fail(expectLifecycle(t, o, typ), errors.New("pubsub down"))
+		if len(o.timers) == 0 {
+			return attempts
+		}
+		fireLatest(t, o)
+	}
+}
+
+func TestWithLifecycleEvents(t *testing.T) {
+	o := newOrchestration(t, "LifecycleTest", lifecycleTestWorkflow)
+	o.start("LifecycleTest", nil)
+	o.complete(expectLifecycle(t, o, lifecycle.TypeStarted), nil)
+	expectPending(t, o, "Inner")
+	o.complete(o.pendingIDs()[0], "done")
+	o.complete(expectLifecycle(t, o, lifecycle.TypeCompleted), nil)
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED ||
+		o.result.GetResult().GetValue() != `"done"` {
+		t.Errorf("workflow ended %s with %s, want the output of the wrapped workflow", o.result.GetOrchestrationStatus(), o.result.GetResult().GetValue())
+	}
+}
+
+func TestWithLifecycleEventsFailure(t *testing.T) {
+	o := newOrchestration(t, "LifecycleTest", lifecycleTestWorkflow)
+	o.start("LifecycleTest", nil)
+	// A notification that cannot be delivered does not change the outcome.
+	if n := failLifecycle(t, o, lifecycle.TypeStarted); n != int(lifecycleRetryPolicy.MaxAttempts) {
+		t.Errorf("publishing was attempted %d times, want %d", n, lifecycleRetryPolicy.MaxAttempts)
+	}
+	expectPending(t, o, "Inner")
+	o.fail(o.pendingIDs()[0], errors.New("boom"))
+	failLifecycle(t, o, lifecycle.TypeFailed)
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED {
+		t.Errorf("workflow ended %s, want failed", o.result.GetOrchestrationStatus())
+	}
+}
+
+func TestWithLifecycleEventsUnpatched(t *testing.T) {
+	// An instance started before the wrapper was added already scheduled Inner first, and
+	// replays without publishing.
+	o := newOrchestration(t, "LifecycleTest", lifecycleTestWorkflow)
+	o.history = []*protos.HistoryEvent{
+		o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_OrchestratorStarted{OrchestratorStarted: &protos.OrchestratorStartedEvent{}}}),
+		o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionStarted{ExecutionStarted: &protos.ExecutionStartedEvent{
+			Name: "LifecycleTest", Input: wrapperspb.String("null"), OrchestrationInstance: &protos.OrchestrationInstance{InstanceId: testInstanceID},
+		}}}),
+		o.event(0, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TaskScheduled{TaskScheduled: &protos.TaskScheduledEvent{Name: "Inner"}}}),
+	}
+	o.pending[0] = o.history[2].GetTaskScheduled()
+	o.complete(0, "done")
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED || len(o.scheduled()) != 1 {
+		t.Errorf("workflow ended %s after scheduling %v, want completed without publishing", o.result.GetOrchestrationStatus(), o.scheduled())
+	}
+}
+
+func TestPublishLifecycleEvent(t *testing.T) {
+	t.Cleanup(func() { lifecycle.SetSink(nil) })
+	in := LifecycleInput{Type: lifecycle.TypeCompleted, Data: lifecycle.Data{InstanceID: "inst", Workflow: "SimpleWorkflow", Status: "COMPLETED"}}
+
+	// Without a sink the activity does nothing.
+	lifecycle.SetSink(nil)
+	if _, err := PublishLifecycleEvent(newActivityContext(t, in)); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := &lifecycle.MemorySink{}
+	lifecycle.SetSink(sink)
+	if _, err := PublishLifecycleEvent(newActivityContext(t, in)); err != nil {
+		t.Fatal(err)
+	}
+	events := sink.Events()
+	if len(events) != 1 {
+		t.Fatalf("published %d events, want 1", len(events))
+	}
+	// Retries publish the same event ID, so consumers can deduplicate.
+	ev := events[0]
+	if ev.ID != "exec-1" || ev.Type != in.Type || ev.Subject != "inst" || ev.Source != lifecycle.Source || !reflect.DeepEqual(ev.Data, in.Data) {
+		t.Errorf("published %+v", ev)
+	}
+
+	lifecycle.SetSink(failingSink{})
+	if _, err := PublishLifecycleEvent(newActivityContext(t, in)); err == nil {
+		t.Error("a failed publish was not reported, so it would not be retried")
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Publish(context.Context, lifecycle.Event) error { return errors.New("pubsub down") }
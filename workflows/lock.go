@@ -0,0 +1,217 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/lock"
+)
+
+// LockInput is the input of AcquireLock, RenewLock and ReleaseLock.
+type LockInput struct {
+	Resource string `json:"resource"`
+	Owner    string `json:"owner"`
+	// ExpiryInSeconds is the lease granted by AcquireLock and RenewLock.
+	ExpiryInSeconds int `json:"expiryInSeconds,omitempty"`
+}
+
+// LockResult is the output of AcquireLock.
+type LockResult struct {
+	Acquired bool `json:"acquired"`
+}
+
+// LockOptions configure WithLock.
+type LockOptions struct {
+	// TTL is the lease on the lock, so that it is released if the instance stops making
+	// progress. Renew it for critical sections that take longer; Dapr lock components
+	// cannot renew leases, so with them TTL must cover the whole section. Defaults to a
+	// minute.
+	TTL time.Duration
+	// Timeout bounds the wait for the lock while another instance holds it. Defaults to
+	// 5 minutes.
+	Timeout time.Duration
+	// RetryInterval is how often the lock is tried while waiting. Defaults to 5 seconds.
+	RetryInterval time.Duration
+}
+
+const (
+	defaultLockTTL           = time.Minute
+	defaultLockTimeout       = 5 * time.Minute
+	defaultLockRetryInterval = 5 * time.Second
+)
+
+// ErrLockTimeout is returned by WithLock when the lock stays taken for the whole timeout.
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
+var lockRetryPolicy = &workflow.RetryPolicy{
+	MaxAttempts:          5,
+	InitialRetryInterval: time.Second,
+	BackoffCoefficient:   2,
+	MaxRetryInterval:     30 * time.Second,
+	Handle:               RetryableError,
+}
+
+// Lease is a lock held by a workflow instance.
+type Lease struct {
+	ctx      *workflow.WorkflowContext
+	resource string
+	ttl      time.Duration
+	expires  time.Time
+}
+
+// Expires returns when the lease runs out, in workflow time.
+func (l *Lease) Expires() time.Time {
+	return l.expires
+}
+
+// Renew extends the lease by its TTL. It fails when the lease already expired, in which
+// case another instance may have entered the critical section, and when the lock store
+// cannot renew leases.
+func (l *Lease) Renew() error {
+	if err := callActivity(l.ctx, RenewLock, l.input(), workflow.WithActivityRetryPolicy(lockRetryPolicy)).Await(nil); err != nil {
+		return fmt.Errorf("renew lock %s: %w", l.resource, err)
+	}
+	l.expires = l.ctx.CurrentTimeUTC().Add(l.ttl)
+	return nil
+}
+
+func (l *Lease) input() LockInput {
+	// Instance IDs are unique and stable across replays, so they identify the owner.
+	return LockInput{Resource: l.resource, Owner: l.ctx.ID(), ExpiryInSeconds: int((l.ttl + time.Second - 1) / time.Second)}
+}
+
+// WithLock runs fn while the instance holds the lock on resource, waiting for other
+// instances to release it first. The lock is released once fn returns, whether it failed
+// or not; if the instance stops before, the lease expires instead.
+func WithLock(ctx *workflow.WorkflowContext, resource string, opts LockOptions, fn func(*Lease) error) error {
+	l, err := acquireLock(ctx, resource, opts)
+	if err != nil {
+		return err
+	}
+
+	// Await unwinds the workflow with a panic whenever it has to wait for a task, which runs
+	// deferred calls too; the lock is kept across those.
+	returned := false
+	defer func() {
+		if !returned {
+			return
+		}
+		rerr := callActivity(ctx, ReleaseLock, l.input(), workflow.WithActivityRetryPolicy(lockRetryPolicy)).Await(nil)
+		if rerr != nil && !ctx.IsReplaying() {
+			log.Warnf("Workflow %s: releasing lock %s: %v", ctx.ID(), resource, rerr)
+		}
+	}()
+
+	err = fn(l)
+	returned = true
+	return err
+}
+
+// acquireLock tries the lock on resource until it is free or opts.Timeout passes.
+func acquireLock(ctx *workflow.WorkflowContext, resource string, opts LockOptions) (*Lease, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultLockTTL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultLockTimeout
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultLockRetryInterval
+	}
+
+	l := &Lease{ctx: ctx, resource: resource, ttl: opts.TTL}
+	deadline := ctx.CurrentTimeUTC().Add(opts.Timeout)
+	for {
+		var res LockResult
+		if err := callActivity(ctx, AcquireLock, l.input(), workflow.WithActivityRetryPolicy(lockRetryPolicy)).Await(&res); err != nil {
+			return nil, fmt.Errorf("acquire lock %s: %w", resource, err)
+		}
+		if res.Acquired {
+			l.expires = ctx.CurrentTimeUTC().Add(opts.TTL)
+			return l, nil
+		}
+		if !ctx.CurrentTimeUTC().Before(deadline) {
+			return nil, fmt.Errorf("%w %s after %s", ErrLockTimeout, resource, opts.Timeout)
+		}
+		if err := ctx.CreateTimer(opts.RetryInterval).Await(nil); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// AcquireLock tries to acquire a lock, without waiting. Resources are scoped to the tenant
+// of the calling instance.
+func AcquireLock(ctx workflow.ActivityContext) (any, error) {
+	in, store, err := lockInput(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := in.ttl()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := store.TryLock(ctx.Context(), scopedKey(ctx.Context(), in.Resource), in.Owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return LockResult{Acquired: ok}, nil
+}
+
+// RenewLock extends the lease of a held lock. A lock that is no longer held stays so, and
+// a store that cannot renew leases never will, so neither failure is retried.
+func RenewLock(ctx workflow.ActivityContext) (any, error) {
+	in, store, err := lockInput(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := in.ttl()
+	if err != nil {
+		return nil, err
+	}
+	return nil, lockError(store.Renew(ctx.Context(), scopedKey(ctx.Context(), in.Resource), in.Owner, ttl))
+}
+
+// ReleaseLock releases a held lock.
+func ReleaseLock(ctx workflow.ActivityContext) (any, error) {
+	in, store, err := lockInput(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nil, lockError(store.Unlock(ctx.Context(), scopedKey(ctx.Context(), in.Resource), in.Owner))
+}
+
+// lockInput decodes and checks the input of a lock activity, and returns the installed
+// store. Invalid inputs and a missing store are not retried.
+func lockInput(ctx workflow.ActivityContext) (LockInput, lock.Store, error) {
+	var in LockInput
+	if err := ctx.GetInput(&in); err != nil {
+		return in, nil, NonRetryable(fmt.Errorf("decode input: %w", err))
+	}
+	if in.Resource == "" || in.Owner == "" {
+		return in, nil, NonRetryable(errors.New("lock needs a resource and an owner"))
+	}
+	store, err := lock.Current()
+	if err != nil {
+		return in, nil, NonRetryable(err)
+	}
+	return in, store, nil
+}
+
+func (in LockInput) ttl() (time.Duration, error) {
+	if in.ExpiryInSeconds <= 0 {
+		return 0, NonRetryable(errors.New("lock needs a positive expiryInSeconds"))
+	}
+	return time.Duration(in.ExpiryInSeconds) * time.Second, nil
+}
+
+// lockError marks locks that are not held, and renewals the store does not support, as
+// non-retryable.
+func lockError(err error) error {
+	if errors.Is(err, lock.ErrNotHeld) || errors.Is(err, lock.ErrRenewUnsupported) {
+		return NonRetryable(err)
+	}
+	return err
+}
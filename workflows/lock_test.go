@@ -0,0 +1,282 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/lock"
+)
+
+var lockActivities = map[string]workflow.Activity{
+	"AcquireLock": AcquireLock,
+	"RenewLock":   RenewLock,
+	"ReleaseLock": ReleaseLock,
+}
+
+// useLockStore installs an empty lock.MemoryStore for the test.
+func useLockStore(t *testing.T) *lock.MemoryStore {
+	store := lock.NewMemoryStore()
+	lock.SetStore(store)
+	t.Cleanup(func() { lock.SetStore(nil) })
+	return store
+}
+
+// held reports whether anyone holds the lock on res, by trying it as another owner.
+func held(t *testing.T, store lock.Store) bool {
+	t.Helper()
+	ok, err := store.TryLock(context.Background(), "res", "probe", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		_ = store.Unlock(context.Background(), "res", "probe")
+	}
+	return !ok
+}
+
+func TestWithLockReleasesWhenSectionReturns(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		workErr    error
+		sectionErr error
+	}{
+		{name: "success"},
+		{name: "section fails", sectionErr: errors.New("section failed")},
+		{name: "activity fails", workErr: errors.New("work failed")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := useLockStore(t)
+			o := newOrchestration(t, "Locked", func(ctx *workflow.WorkflowContext) (any, error) {
+				return nil, WithLock(ctx, "res", LockOptions{}, func(*Lease) error {
+					if err := ctx.CallActivity("Work").Await(nil); err != nil {
+						return err
+					}
+					return tc.sectionErr
+				})
+			})
+			o.start("Locked", nil)
+			expectPending(t, o, "AcquireLock")
+			o.runAll("AcquireLock", lockActivities)
+
+			// The lock is kept while the section waits for its activity.
+			expectPending(t, o, "Work")
+			if !held(t, store) {
+				t.Fatal("lock is not held during the section")
+			}
+			work := o.pendingIDs()[0]
+			if tc.workErr != nil {
+				o.fail(work, tc.workErr)
+			} else {
+				o.complete(work, nil)
+			}
+
+			expectPending(t, o, "ReleaseLock")
+			if !held(t, store) {
+				t.Fatal("lock was released before ReleaseLock ran")
+			}
+			o.runAll("ReleaseLock", lockActivities)
+			if held(t, store) {
+				t.Error("lock is still held after the section returned")
+			}
+
+			wantErr := tc.sectionErr
+			if tc.workErr != nil {
+				wantErr = tc.workErr
+			}
+			switch {
+			case o.result == nil:
+				t.Fatal("workflow did not complete")
+			case wantErr == nil && o.result.OrchestrationStatus != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED:
+				t.Errorf("workflow ended %s: %v", o.result.OrchestrationStatus, o.result.FailureDetails)
+			case wantErr != nil && !strings.Contains(o.result.GetFailureDetails().GetErrorMessage(), wantErr.Error()):
+				t.Errorf("workflow ended %s with %v, want failure %q", o.result.OrchestrationStatus, o.result.FailureDetails, wantErr)
+			}
+		})
+	}
+}
+
+func TestWithLockWaitsForOwner(t *testing.T) {
+	store := useLockStore(t)
+	if ok, _ := store.TryLock(context.Background(), "res", "other", time.Hour); !ok {
+		t.Fatal("other did not get the lock")
+	}
+
+	o := newOrchestration(t, "Locked", func(ctx *workflow.WorkflowContext) (any, error) {
+		return nil, WithLock(ctx, "res", LockOptions{RetryInterval: 5 * time.Second}, func(*Lease) error {
+			return ctx.CallActivity("Work").Await(nil)
+		})
+	})
+	o.start("Locked", nil)
+	o.runAll("AcquireLock", lockActivities)
+
+	// The lock is taken, so the workflow waits and tries again.
+	expectPending(t, o)
+	if len(o.timers) != 1 {
+		t.Fatalf("timers %v, want one retry", o.timers)
+	}
+	_ = store.Unlock(context.Background(), "res", "other")
+	for id := range o.timers {
+		o.fire(id)
+	}
+	expectPending(t, o, "AcquireLock")
+	o.runAll("AcquireLock", lockActivities)
+
+	expectPending(t, o, "Work")
+	o.complete(o.pendingIDs()[0], nil)
+	o.runAll("ReleaseLock", lockActivities)
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+		t.Errorf("workflow ended %s: %v", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+}
+
+func TestWithLockTimeout(t *testing.T) {
+	store := useLockStore(t)
+	if ok, _ := store.TryLock(context.Background(), "res", "other", time.Hour); !ok {
+		t.Fatal("other did not get the lock")
+	}
+
+	o := newOrchestration(t, "Locked", func(ctx *workflow.WorkflowContext) (any, error) {
+		return nil, WithLock(ctx, "res", LockOptions{Timeout: 20 * time.Second, RetryInterval: 5 * time.Second}, func(*Lease) error {
+			return errors.New("entered the section")
+		})
+	})
+	o.start("Locked", nil)
+	attempts := 0
+	for o.result == nil {
+		if n := o.runAll("AcquireLock", lockActivities); n > 0 {
+			attempts += n
+			continue
+		}
+		if len(o.timers) != 1 {
+			t.Fatalf("timers %v, want one retry", o.timers)
+		}
+		for id := range o.timers {
+			o.fire(id)
+		}
+	}
+
+	if msg := o.result.GetFailureDetails().GetErrorMessage(); !strings.Contains(msg, ErrLockTimeout.Error()) {
+		t.Errorf("workflow ended %s with %q, want %q", o.result.GetOrchestrationStatus(), msg, ErrLockTimeout)
+	}
+	if attempts < 2 {
+		t.Errorf("tried the lock %d times, want retries until the timeout", attempts)
+	}
+	if slices.ContainsFunc(o.scheduled(), func(s string) bool { return strings.Contains(s, " ReleaseLock(") }) {
+		t.Error("released a lock that was never acquired")
+	}
+}
+
+// noRenewStore is a lock store that cannot renew leases, like a Dapr lock component.
+type noRenewStore struct {
+	*lock.MemoryStore
+}
+
+func (noRenewStore) Renew(_ context.Context, resource, _ string, _ time.Duration) error {
+	return fmt.Errorf("renew lock %s: %w", resource, lock.ErrRenewUnsupported)
+}
+
+func TestWithLockRenew(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// steal makes another owner take the lock before the renewal.
+		steal bool
+		// noRenew installs a store that cannot renew leases.
+		noRenew bool
+		wantErr error
+	}{
+		{name: "held"},
+		{name: "taken over", steal: true, wantErr: lock.ErrNotHeld},
+		{name: "unsupported", noRenew: true, wantErr: lock.ErrRenewUnsupported},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := useLockStore(t)
+			if tc.noRenew {
+				lock.SetStore(noRenewStore{store})
+			}
+			o := newOrchestration(t, "Locked", func(ctx *workflow.WorkflowContext) (any, error) {
+				var expires []time.Time
+				err := WithLock(ctx, "res", LockOptions{TTL: time.Minute}, func(l *Lease) error {
+					expires = append(expires, l.Expires())
+					if err := ctx.CallActivity("Work").Await(nil); err != nil {
+						return err
+					}
+					if err := l.Renew(); err != nil {
+						return err
+					}
+					expires = append(expires, l.Expires())
+					return nil
+				})
+				return expires, err
+			})
+			o.start("Locked", nil)
+			o.runAll("AcquireLock", lockActivities)
+			if tc.steal {
+				_ = store.Unlock(context.Background(), "res", testInstanceID)
+				if ok, _ := store.TryLock(context.Background(), "res", "other", time.Hour); !ok {
+					t.Fatal("other did not get the lock")
+				}
+			}
+			o.complete(o.pendingIDs()[0], nil)
+
+			expectPending(t, o, "RenewLock")
+			o.runAll("RenewLock", lockActivities)
+			// A failed renewal is not retried.
+			expectPending(t, o, "ReleaseLock")
+			o.runAll("ReleaseLock", lockActivities)
+
+			if tc.wantErr != nil {
+				if msg := o.result.GetFailureDetails().GetErrorMessage(); !strings.Contains(msg, tc.wantErr.Error()) {
+					t.Errorf("workflow ended %s with %q, want %q", o.result.GetOrchestrationStatus(), msg, tc.wantErr)
+				}
+				if held(t, store) != tc.steal {
+					t.Errorf("lock held = %t after the section failed, want %t", !tc.steal, tc.steal)
+				}
+				return
+			}
+
+			var expires []time.Time
+			if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &expires); err != nil {
+				t.Fatalf("workflow ended %s with %q: %v", o.result.GetOrchestrationStatus(), o.result.GetResult().GetValue(), err)
+			}
+			if len(expires) != 2 || !expires[1].After(expires[0]) {
+				t.Errorf("lease expiries %v, want a later one after renewing", expires)
+			}
+			if held(t, store) {
+				t.Error("lock is still held after the section returned")
+			}
+		})
+	}
+}
+
+// TestAcquireLockRetried checks that an AcquireLock retried after its result was lost finds
+// the lock acquired, rather than taken.
+func TestAcquireLockRetried(t *testing.T) {
+	useLockStore(t)
+	acquire := func(owner string) bool {
+		t.Helper()
+		out, err := AcquireLock(newActivityContext(t, LockInput{Resource: "res", Owner: owner, ExpiryInSeconds: 60}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.(LockResult).Acquired
+	}
+
+	if !acquire("inst") {
+		t.Fatal("inst did not get a free lock")
+	}
+	if !acquire("inst") {
+		t.Error("a retried acquire by the owner found the lock taken")
+	}
+	if acquire("other") {
+		t.Error("other got the lock held by inst")
+	}
+}
@@ -0,0 +1,136 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// MonitorState is both the input of MonitorWorkflow and the state carried from one
+// generation to the next through ContinueAsNew.
+type MonitorState struct {
+	Target      string        `json:"target"`
+	MinInterval time.Duration `json:"minInterval"`
+	MaxInterval time.Duration `json:"maxInterval"`
+	Interval    time.Duration `json:"interval"`
+
+	Generation          int       `json:"generation"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastHealthy         bool      `json:"lastHealthy"`
+	LastCheckedAt       time.Time `json:"lastCheckedAt"`
+}
+
+// HealthResult is the output of CheckHealth.
+type HealthResult struct {
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// MonitorStatus is published as the workflow custom status after every check.
+type MonitorStatus struct {
+	Target              string `json:"target"`
+	Healthy             bool   `json:"healthy"`
+	Detail              string `json:"detail,omitempty"`
+	NextCheckIn         string `json:"nextCheckIn"`
+	Generation          int    `json:"generation"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+}
+
+// MonitorWorkflow runs one health check per generation, sleeps on a durable timer and then
+// continues as new, so the history of an eternal monitor never grows past a single check.
+// The interval backs off towards MaxInterval while the target is healthy and drops to
+// MinInterval as soon as it is not.
+func MonitorWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var state MonitorState
+	if err := ctx.GetInput(&state); err != nil {
+		return nil, fmt.Errorf("decode monitor state: %w", err)
+	}
+	if state.Target == "" {
+		return nil, errors.New("monitor has no target")
+	}
+	if state.MinInterval <= 0 || state.MaxInterval < state.MinInterval {
+		return nil, fmt.Errorf("invalid monitor intervals: min %s, max %s", state.MinInterval, state.MaxInterval)
+	}
+
+	var result HealthResult
+	if err := callActivity(ctx, CheckHealth, state.Target).Await(&result); err != nil {
+		result = HealthResult{Detail: err.Error()}
+	}
+
+	state.Generation++
+	state.LastHealthy = result.Healthy
+	state.LastCheckedAt = ctx.CurrentTimeUTC()
+	state.Interval = nextMonitorInterval(state, result.Healthy)
+	if result.Healthy {
+		state.ConsecutiveFailures = 0
+	} else {
+		state.ConsecutiveFailures++
+	}
+
+	status, err := json.Marshal(MonitorStatus{
+		Target:              state.Target,
+		Healthy:             result.Healthy,
+		Detail:              result.Detail,
+		NextCheckIn:         state.Interval.String(),
+		Generation:          state.Generation,
+		ConsecutiveFailures: state.ConsecutiveFailures,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode monitor status: %w", err)
+	}
+	ctx.SetCustomStatus(string(status))
+
+	if err := ctx.CreateTimer(state.Interval).Await(nil); err != nil {
+		return nil, err
+	}
+
+	ctx.ContinueAsNew(state)
+	return nil, nil
+}
+
+// nextMonitorInterval doubles the interval while the target stays healthy and resets it to
+// the minimum on failure.
+func nextMonitorInterval(state MonitorState, healthy bool) time.Duration {
+	if !healthy {
+		return state.MinInterval
+	}
+	if state.Interval <= 0 {
+		return state.MinInterval
+	}
+	return min(state.Interval*2, state.MaxInterval)
+}
+
+var healthClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// CheckHealth issues a GET against the target URL and reports it healthy on any 2xx.
+func CheckHealth(ctx workflow.ActivityContext) (any, error) {
+	var target string
+	if err := ctx.GetInput(&target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx.Context(), http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid health check target: %w", err)
+	}
+
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return HealthResult{Detail: err.Error()}, nil
+	}
+	defer resp.Body.Close()
+
+	return HealthResult{
+		Healthy: resp.StatusCode >= 200 && resp.StatusCode < 300,
+		Detail:  resp.Status,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+// runMonitorGeneration runs one generation of MonitorWorkflow from state, completing its
+// check with result, or failing it when result is nil. It returns the state the workflow
+// continued as new with and the status it published.
+func runMonitorGeneration(t *testing.T, state MonitorState, result *HealthResult) (MonitorState, MonitorStatus) {
+	t.Helper()
+	o := newOrchestration(t, "MonitorWorkflow", MonitorWorkflow)
+	o.start("MonitorWorkflow", state)
+	expectPending(t, o, "CheckHealth")
+	if result == nil {
+		o.fail(0, errors.New("connection refused"))
+	} else {
+		o.complete(0, result)
+	}
+
+	var status MonitorStatus
+	if err := json.Unmarshal([]byte(o.customStatus), &status); err != nil {
+		t.Fatalf("custom status %q: %v", o.customStatus, err)
+	}
+	if len(o.timers) != 1 {
+		t.Fatalf("timers %v, want the wait for the next check", o.timers)
+	}
+	// The timer is set from the time of the turn that created it.
+	var turnAt time.Time
+	for _, e := range o.history {
+		if e.GetOrchestratorStarted() != nil {
+			turnAt = e.GetTimestamp().AsTime()
+		}
+	}
+	for id, fireAt := range o.timers {
+		if wait := fireAt.Sub(turnAt); wait != statusInterval(t, status) {
+			t.Fatalf("next check in %s, want %s", wait, status.NextCheckIn)
+		}
+		o.fire(id)
+	}
+
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_CONTINUED_AS_NEW {
+		t.Fatalf("generation ended %s: %v, want continued as new", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+	var next MonitorState
+	if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &next); err != nil {
+		t.Fatal(err)
+	}
+	// Each generation starts from an empty history.
+	if n := len(o.scheduled()); n != 1 {
+		t.Fatalf("generation scheduled %d activities, want 1", n)
+	}
+	return next, status
+}
+
+func statusInterval(t *testing.T, status MonitorStatus) time.Duration {
+	t.Helper()
+	d, err := time.ParseDuration(status.NextCheckIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestMonitorWorkflowContinuesAsNew(t *testing.T) {
+	healthy := &HealthResult{Healthy: true, Detail: "200 OK"}
+	down := &HealthResult{Detail: "503 Service Unavailable"}
+
+	state := MonitorState{Target: "http://example.com/health", MinInterval: 10 * time.Second, MaxInterval: 40 * time.Second}
+	for i, tc := range []struct {
+		result       *HealthResult
+		wantInterval time.Duration
+		wantFailures int
+	}{
+		// The interval backs off while healthy, up to the maximum.
+		{result: healthy, wantInterval: 10 * time.Second},
+		{result: healthy, wantInterval: 20 * time.Second},
+		{result: healthy, wantInterval: 40 * time.Second},
+		{result: healthy, wantInterval: 40 * time.Second},
+		// It drops to the minimum on failure, and failures are counted until a healthy check.
+		{result: down, wantInterval: 10 * time.Second, wantFailures: 1},
+		{result: nil, wantInterval: 10 * time.Second, wantFailures: 2},
+		{result: healthy, wantInterval: 20 * time.Second},
+		{result: healthy, wantInterval: 40 * time.Second},
+	} {
+		next, status := runMonitorGeneration(t, state, tc.result)
+		healthyNow := tc.result != nil && tc.result.Healthy
+
+		if next.Generation != i+1 || next.Interval != tc.wantInterval || next.ConsecutiveFailures != tc.wantFailures ||
+			next.LastHealthy != healthyNow || next.LastCheckedAt.IsZero() || next.Target != state.Target {
+			t.Fatalf("generation %d: state %+v, want interval %s and %d failures", i+1, next, tc.wantInterval, tc.wantFailures)
+		}
+		if status.Generation != i+1 || status.Healthy != healthyNow || status.ConsecutiveFailures != tc.wantFailures ||
+			status.NextCheckIn != tc.wantInterval.String() || status.Detail == "" {
+			t.Fatalf("generation %d: status %+v", i+1, status)
+		}
+		state = next
+	}
+}
+
+func TestMonitorWorkflowRejectsInvalidState(t *testing.T) {
+	for _, state := range []MonitorState{
+		{MinInterval: time.Second, MaxInterval: time.Minute},
+		{Target: "http://example.com", MaxInterval: time.Minute},
+		{Target: "http://example.com", MinInterval: time.Minute, MaxInterval: time.Second},
+	} {
+		o := newOrchestration(t, "MonitorWorkflow", MonitorWorkflow)
+		o.start("MonitorWorkflow", state)
+		if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_FAILED {
+			t.Errorf("%+v: workflow ended %s, want failed", state, o.result.GetOrchestrationStatus())
+		}
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		want   bool
+	}{
+		{status: http.StatusOK, want: true},
+		{status: http.StatusNoContent, want: true},
+		{status: http.StatusServiceUnavailable},
+		{status: http.StatusNotFound},
+	} {
+		srv, got := httpServer(t, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(tc.status) })
+		out, err := CheckHealth(newActivityContext(t, srv.URL+"/health"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := out.(HealthResult); r.Healthy != tc.want || r.Detail == "" || got.method != http.MethodGet {
+			t.Errorf("%d: %+v, want healthy %v", tc.status, r, tc.want)
+		}
+	}
+
+	// An unreachable target is unhealthy, not an error the activity is retried for.
+	srv, _ := httpServer(t, func(http.ResponseWriter, *http.Request) {})
+	srv.Close()
+	out, err := CheckHealth(newActivityContext(t, srv.URL))
+	if err != nil || out.(HealthResult).Healthy {
+		t.Errorf("unreachable target: %+v, %v, want unhealthy", out, err)
+	}
+}
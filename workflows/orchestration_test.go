@@ -0,0 +1,325 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// sidecar stands in for the Dapr sidecar of a workflow worker: it hands the orchestrator
+// requests given to execute to the worker, and returns what the worker answers.
+type sidecar struct {
+	protos.UnimplementedTaskHubSidecarServiceServer
+	requests  chan *protos.OrchestratorRequest
+	responses chan *protos.OrchestratorResponse
+}
+
+func (s *sidecar) Hello(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (s *sidecar) GetWorkItems(_ *protos.GetWorkItemsRequest, stream protos.TaskHubSidecarService_GetWorkItemsServer) error {
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case req := <-s.requests:
+			err := stream.Send(&protos.WorkItem{Request: &protos.WorkItem_OrchestratorRequest{OrchestratorRequest: req}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *sidecar) CompleteOrchestratorTask(_ context.Context, resp *protos.OrchestratorResponse) (*protos.CompleteTaskResponse, error) {
+	s.responses <- resp
+	return &protos.CompleteTaskResponse{}, nil
+}
+
+// startWorker runs a worker with wf registered as name against a sidecar, until the test
+// ends.
+func startWorker(t *testing.T, name string, wf workflow.Workflow) *sidecar {
+	t.Helper()
+	reg := workflow.NewRegistry()
+	if err := reg.AddWorkflowN(name, wf); err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sidecar{
+		requests:  make(chan *protos.OrchestratorRequest),
+		responses: make(chan *protos.OrchestratorResponse, 1),
+	}
+	srv := grpc.NewServer()
+	protos.RegisterTaskHubSidecarServiceServer(srv, s)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///"+lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := workflow.NewClient(conn).StartWorker(ctx, reg); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// execute has the worker run the workflow over the past and new events.
+func (s *sidecar) execute(t *testing.T, past, events []*protos.HistoryEvent) *protos.OrchestratorResponse {
+	t.Helper()
+	req := &protos.OrchestratorRequest{InstanceId: testInstanceID, PastEvents: past, NewEvents: events}
+	timeout := time.After(10 * time.Second)
+	select {
+	case s.requests <- req:
+	case <-timeout:
+		t.Fatal("the worker did not ask for work")
+	}
+	select {
+	case resp := <-s.responses:
+		return resp
+	case <-timeout:
+		t.Fatal("the worker did not complete the orchestrator request")
+	}
+	return nil
+}
+
+// orchestration drives a workflow the way the sidecar does: every turn replays the
+// history so far and appends the new events and the tasks the workflow scheduled.
+type orchestration struct {
+	t       *testing.T
+	sidecar *sidecar
+	history []*protos.HistoryEvent
+	// pending holds the activities scheduled and not completed yet, by task ID.
+	pending map[int32]*protos.TaskScheduledEvent
+	// timers holds when the timers created and not fired yet fire, by timer ID.
+	timers map[int32]time.Time
+	result *protos.CompleteOrchestrationAction
+	// customStatus is the custom status the workflow last set.
+	customStatus string
+	clock        time.Time
+}
+
+const testInstanceID = "inst"
+
+func newOrchestration(t *testing.T, name string, wf workflow.Workflow) *orchestration {
+	t.Helper()
+	return &orchestration{
+		t:       t,
+		sidecar: startWorker(t, name, wf),
+		pending: make(map[int32]*protos.TaskScheduledEvent),
+		timers:  make(map[int32]time.Time),
+		clock:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (o *orchestration) event(id int32, e *protos.HistoryEvent) *protos.HistoryEvent {
+	o.clock = o.clock.Add(time.Second)
+	e.EventId = id
+	e.Timestamp = timestamppb.New(o.clock)
+	return e
+}
+
+// start runs the first turn of the workflow with input.
+func (o *orchestration) start(name string, input any) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	o.turn(o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_ExecutionStarted{
+		ExecutionStarted: &protos.ExecutionStartedEvent{
+			Name:                  name,
+			Input:                 wrapperspb.String(string(raw)),
+			OrchestrationInstance: &protos.OrchestrationInstance{InstanceId: testInstanceID},
+		},
+	}}))
+}
+
+// complete completes the scheduled activity id with result.
+func (o *orchestration) complete(id int32, result any) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	delete(o.pending, id)
+	o.turn(o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TaskCompleted{
+		TaskCompleted: &protos.TaskCompletedEvent{TaskScheduledId: id, Result: wrapperspb.String(string(raw))},
+	}}))
+}
+
+// fail fails the scheduled activity id with err.
+func (o *orchestration) fail(id int32, err error) {
+	delete(o.pending, id)
+	o.turn(o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TaskFailed{
+		TaskFailed: &protos.TaskFailedEvent{
+			TaskScheduledId: id,
+			FailureDetails:  &protos.TaskFailureDetails{ErrorType: "error", ErrorMessage: err.Error()},
+		},
+	}}))
+}
+
+// run runs the scheduled activity id with the function of the same name in activities, and
+// completes or fails it with the outcome.
+func (o *orchestration) run(id int32, activities map[string]workflow.Activity) {
+	o.t.Helper()
+	ts, ok := o.pending[id]
+	if !ok {
+		o.t.Fatalf("no activity %d is pending", id)
+	}
+	activity, ok := activities[ts.Name]
+	if !ok {
+		o.t.Fatalf("no activity %s", ts.Name)
+	}
+	out, err := activity(&fakeActivityContext{ctx: context.Background(), input: []byte(ts.GetInput().GetValue())})
+	if err != nil {
+		o.fail(id, err)
+		return
+	}
+	o.complete(id, out)
+}
+
+// runAll runs the pending activities named name, in order, and reports how many ran.
+func (o *orchestration) runAll(name string, activities map[string]workflow.Activity) int {
+	o.t.Helper()
+	n := 0
+	for _, id := range o.pendingIDs() {
+		if ts, ok := o.pending[id]; ok && ts.Name == name {
+			o.run(id, activities)
+			n++
+		}
+	}
+	return n
+}
+
+// fire fires timer id, moving the workflow clock to its time.
+func (o *orchestration) fire(id int32) {
+	fireAt, ok := o.timers[id]
+	if !ok {
+		o.t.Fatalf("no timer %d is pending", id)
+	}
+	delete(o.timers, id)
+	if fireAt.After(o.clock) {
+		o.clock = fireAt
+	}
+	o.turn(o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_TimerFired{
+		TimerFired: &protos.TimerFiredEvent{TimerId: id, FireAt: timestamppb.New(fireAt)},
+	}}))
+}
+
+// raise raises the external event name with payload.
+func (o *orchestration) raise(name string, payload any) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	o.turn(o.event(-1, &protos.HistoryEvent{EventType: &protos.HistoryEvent_EventRaised{
+		EventRaised: &protos.EventRaisedEvent{Name: name, Input: wrapperspb.String(string(raw))},
+	}}))
+}
+
+// turn replays the history with events as new ones and records what the workflow did.
+func (o *orchestration) turn(events ...*protos.HistoryEvent) {
+	o.t.Helper()
+	if o.result != nil {
+		o.t.Fatalf("workflow already completed")
+	}
+	events = append([]*protos.HistoryEvent{o.event(-1, &protos.HistoryEvent{
+		EventType: &protos.HistoryEvent_OrchestratorStarted{OrchestratorStarted: &protos.OrchestratorStartedEvent{}},
+	})}, events...)
+	resp := o.sidecar.execute(o.t, o.history, events)
+	// Like the sidecar, record the patches the turn applied on the event that started it.
+	events[0].GetOrchestratorStarted().Version = resp.GetVersion()
+	o.history = append(o.history, events...)
+	o.customStatus = resp.GetCustomStatus().GetValue()
+
+	for _, a := range resp.Actions {
+		switch {
+		case a.GetScheduleTask() != nil:
+			st := a.GetScheduleTask()
+			if _, ok := o.pending[a.Id]; ok {
+				o.t.Fatalf("task %d scheduled twice", a.Id)
+			}
+			ts := &protos.TaskScheduledEvent{Name: st.Name, Input: st.Input}
+			o.pending[a.Id] = ts
+			o.history = append(o.history, o.event(a.Id, &protos.HistoryEvent{
+				EventType: &protos.HistoryEvent_TaskScheduled{TaskScheduled: ts},
+			}))
+		case a.GetCreateTimer() != nil:
+			fireAt := a.GetCreateTimer().GetFireAt()
+			o.timers[a.Id] = fireAt.AsTime()
+			o.history = append(o.history, o.event(a.Id, &protos.HistoryEvent{
+				EventType: &protos.HistoryEvent_TimerCreated{TimerCreated: &protos.TimerCreatedEvent{FireAt: fireAt}},
+			}))
+		case a.GetCompleteOrchestration() != nil:
+			o.result = a.GetCompleteOrchestration()
+		default:
+			o.t.Fatalf("unexpected action %v", a)
+		}
+	}
+}
+
+// pendingNames returns the names of the activities waiting to complete, by ID.
+func (o *orchestration) pendingNames() []string {
+	var names []string
+	for _, id := range o.pendingIDs() {
+		names = append(names, o.pending[id].Name)
+	}
+	return names
+}
+
+// expectPending fails the test unless the activities waiting to complete are want, by ID.
+func expectPending(t *testing.T, o *orchestration, want ...string) {
+	t.Helper()
+	if got := o.pendingNames(); !reflect.DeepEqual(got, want) && (len(got) > 0 || len(want) > 0) {
+		t.Fatalf("pending activities %v, want %v", got, want)
+	}
+}
+
+// pendingIDs returns the IDs of the activities waiting to complete, in order.
+func (o *orchestration) pendingIDs() []int32 {
+	ids := make([]int32, 0, len(o.pending))
+	for id := range o.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// scheduled lists the activities in the history as "<id> <name>(<input>)", by ID. The
+// actions of a single turn come in no particular order.
+func (o *orchestration) scheduled() []string {
+	var events []*protos.HistoryEvent
+	for _, e := range o.history {
+		if e.GetTaskScheduled() != nil {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].EventId < events[j].EventId })
+	out := make([]string, len(events))
+	for i, e := range events {
+		ts := e.GetTaskScheduled()
+		out[i] = fmt.Sprintf("%d %s(%s)", e.EventId, ts.Name, ts.GetInput().GetValue())
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// OrderInput is the input of OrderSagaWorkflow.
+type OrderInput struct {
+	OrderID  string  `json:"orderId"`
+	Item     string  `json:"item"`
+	Quantity int     `json:"quantity"`
+	Amount   float64 `json:"amount"`
+	// FailAt names a step ("reserve", "charge" or "ship") that should fail, to demo rollback.
+	FailAt string `json:"failAt,omitempty"`
+}
+
+var compensationRetryPolicy = &workflow.RetryPolicy{
+	MaxAttempts:          5,
+	InitialRetryInterval: time.Second,
+	BackoffCoefficient:   2,
+	MaxRetryInterval:     30 * time.Second,
+}
+
+// OrderSagaWorkflow reserves inventory, charges the payment and ships the order. If any
+// step fails, the completed ones are compensated in reverse order.
+func OrderSagaWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	var order OrderInput
+	if err := ctx.GetInput(&order); err != nil {
+		return nil, fmt.Errorf("decode order: %w", err)
+	}
+
+	saga := NewSaga(ctx)
+	steps := []SagaStep{
+		{
+			Name:                    "reserve",
+			Activity:                ReserveInventory,
+			Input:                   order,
+			Compensation:            ReleaseInventory,
+			CompensationRetryPolicy: compensationRetryPolicy,
+		},
+		{
+			Name:                    "charge",
+			Activity:                ChargePayment,
+			Input:                   order,
+			Compensation:            RefundPayment,
+			CompensationRetryPolicy: compensationRetryPolicy,
+		},
+		{
+			Name:     "ship",
+			Activity: ShipOrder,
+			Input:    order,
+		},
+	}
+
+	for _, step := range steps {
+		if err := saga.Step(step, nil); err != nil {
+			break
+		}
+	}
+
+	return saga.Result(), nil
+}
+
+func ReserveInventory(ctx workflow.ActivityContext) (any, error) {
+	return orderStep(ctx, "reserve", "ReserveInventory")
+}
+
+func ReleaseInventory(ctx workflow.ActivityContext) (any, error) {
+	return orderStep(ctx, "", "ReleaseInventory")
+}
+
+func ChargePayment(ctx workflow.ActivityContext) (any, error) {
+	return orderStep(ctx, "charge", "ChargePayment")
+}
+
+func RefundPayment(ctx workflow.ActivityContext) (any, error) {
+	return orderStep(ctx, "", "RefundPayment")
+}
+
+func ShipOrder(ctx workflow.ActivityContext) (any, error) {
+	return orderStep(ctx, "ship", "ShipOrder")
+}
+
+// orderStep is the shared body of the sample order activities. It fails when the order
+// asks for the given step to fail.
+func orderStep(ctx workflow.ActivityContext, step, name string) (any, error) {
+	var order OrderInput
+	if err := ctx.GetInput(&order); err != nil {
+		return nil, err
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	if step != "" && order.FailAt == step {
+		return nil, fmt.Errorf("%s failed for order %s", name, order.OrderID)
+	}
+
+	return nil, nil
+}
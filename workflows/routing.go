@@ -0,0 +1,76 @@
+package workflows
+
+import (
+	"sync/atomic"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// Routes maps activity and child workflow names to the Dapr app ID that hosts them.
+type Routes struct {
+	Activities map[string]string
+	Workflows  map[string]string
+}
+
+var routes atomic.Pointer[Routes]
+
+// SetRoutes installs the routing table used by RouteActions. It must be set before the
+// worker starts and must not change while instances are in flight, since
+// replays need to make the same routing decisions.
+func SetRoutes(r Routes) {
+	routes.Store(&r)
+}
+
+// ActivityAppID returns the app ID an activity is routed to, or "" if it runs locally.
+func ActivityAppID(name string) string {
+	if r := routes.Load(); r != nil {
+		return r.Activities[name]
+	}
+	return ""
+}
+
+// WorkflowAppID returns the app ID a child workflow is routed to, or "" if it runs locally.
+func WorkflowAppID(name string) string {
+	if r := routes.Load(); r != nil {
+		return r.Workflows[name]
+	}
+	return ""
+}
+
+// RouteActions applies the routing table to the activities and child workflows scheduled
+// by an orchestration, however they were called. Targets chosen by the workflow itself are
+// kept, and so are actions without a router, which the sidecar only sets up for
+// orchestrations it can route.
+func RouteActions(actions []*protos.OrchestratorAction) {
+	for _, a := range actions {
+		if a.GetRouter() == nil || a.GetRouter().TargetAppID != nil {
+			continue
+		}
+		var appID string
+		switch {
+		case a.GetScheduleTask() != nil:
+			appID = ActivityAppID(a.GetScheduleTask().GetName())
+		case a.GetCreateSubOrchestration() != nil:
+			appID = WorkflowAppID(a.GetCreateSubOrchestration().GetName())
+		}
+		if appID != "" {
+			a.Router.TargetAppID = &appID
+		}
+	}
+}
+
+// callActivity is ctx.CallActivity with input given directly. input is the activity input,
+// or nil for none. Activities of tenant-scoped instances get it wrapped with the tenant ID,
+// which TenantInterceptor unwraps.
+func callActivity(ctx *workflow.WorkflowContext, activity any, input any, opts ...workflow.CallActivityOption) workflow.Task {
+	if t := tenant.FromInstanceID(ctx.ID()); t != "" {
+		input = tenantInput{Tenant: t, Input: input}
+	}
+	if input != nil {
+		opts = append(opts, workflow.WithActivityInput(input))
+	}
+	return ctx.CallActivity(activity, opts...)
+}
@@ -0,0 +1,79 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+)
+
+// setRoutes installs r for the rest of the test.
+func setRoutes(t *testing.T, r Routes) {
+	prev := routes.Load()
+	t.Cleanup(func() { routes.Store(prev) })
+	SetRoutes(r)
+}
+
+func TestRouteActions(t *testing.T) {
+	setRoutes(t, Routes{
+		Activities: map[string]string{"ChargePayment": "payments"},
+		Workflows:  map[string]string{"ShippingWorkflow": "shipping"},
+	})
+	router := func(target ...string) *protos.TaskRouter {
+		r := &protos.TaskRouter{SourceAppID: "orders"}
+		if len(target) > 0 {
+			r.TargetAppID = &target[0]
+		}
+		return r
+	}
+	task := func(name string, r *protos.TaskRouter) *protos.OrchestratorAction {
+		return &protos.OrchestratorAction{Router: r, OrchestratorActionType: &protos.OrchestratorAction_ScheduleTask{
+			ScheduleTask: &protos.ScheduleTaskAction{Name: name},
+		}}
+	}
+	child := func(name string, r *protos.TaskRouter) *protos.OrchestratorAction {
+		return &protos.OrchestratorAction{Router: r, OrchestratorActionType: &protos.OrchestratorAction_CreateSubOrchestration{
+			CreateSubOrchestration: &protos.CreateSubOrchestrationAction{Name: name},
+		}}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		action *protos.OrchestratorAction
+		// want is the target app ID after routing, "" for none.
+		want string
+	}{
+		{name: "routed activity", action: task("ChargePayment", router()), want: "payments"},
+		{name: "local activity", action: task("ReserveInventory", router())},
+		{name: "routed child workflow", action: child("ShippingWorkflow", router()), want: "shipping"},
+		{name: "activity named like a routed workflow", action: task("ShippingWorkflow", router())},
+		{name: "target chosen by the workflow", action: task("ChargePayment", router("billing")), want: "billing"},
+		{name: "no router", action: task("ChargePayment", nil)},
+		{name: "timer", action: &protos.OrchestratorAction{Router: router(), OrchestratorActionType: &protos.OrchestratorAction_CreateTimer{
+			CreateTimer: &protos.CreateTimerAction{},
+		}}},
+	} {
+		RouteActions([]*protos.OrchestratorAction{tc.action})
+		if got := tc.action.GetRouter().GetTargetAppID(); got != tc.want {
+			t.Errorf("%s: routed to %q, want %q", tc.name, got, tc.want)
+		}
+		if tc.action.GetRouter() != nil && tc.action.GetRouter().GetSourceAppID() != "orders" {
+			t.Errorf("%s: source app changed to %q", tc.name, tc.action.GetRouter().GetSourceAppID())
+		}
+	}
+}
+
+func TestAppIDWithoutRoutes(t *testing.T) {
+	prev := routes.Load()
+	t.Cleanup(func() { routes.Store(prev) })
+	routes.Store(nil)
+
+	if got := ActivityAppID("ChargePayment"); got != "" {
+		t.Errorf("ActivityAppID without routes: %q", got)
+	}
+	if got := WorkflowAppID("ShippingWorkflow"); got != "" {
+		t.Errorf("WorkflowAppID without routes: %q", got)
+	}
+	RouteActions([]*protos.OrchestratorAction{{Router: &protos.TaskRouter{}, OrchestratorActionType: &protos.OrchestratorAction_ScheduleTask{
+		ScheduleTask: &protos.ScheduleTaskAction{Name: "ChargePayment"},
+	}}})
+}
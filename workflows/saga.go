@@ -0,0 +1,117 @@
+package workflows
+
+import (
+	"fmt"
+
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// SagaStep is a single forward action of a saga together with the activity that undoes it.
+type SagaStep struct {
+	// Name identifies the step in the SagaResult.
+	Name string
+	// Activity is the forward activity, referenced the same way as in CallActivity.
+	Activity any
+	Input    any
+	// RetryPolicy optionally retries the forward activity before the saga gives up.
+	RetryPolicy *workflow.RetryPolicy
+
+	// Compensation undoes Activity. Steps without a compensation are skipped on rollback.
+	Compensation any
+	// CompensationInput defaults to Input when nil.
+	CompensationInput any
+	// CompensationRetryPolicy optionally retries the compensating activity.
+	CompensationRetryPolicy *workflow.RetryPolicy
+}
+
+// SagaResult records how far a saga got and what was rolled back.
+type SagaResult struct {
+	Status             string   `json:"status"`
+	Completed          []string `json:"completed,omitempty"`
+	FailedStep         string   `json:"failedStep,omitempty"`
+	Error              string   `json:"error,omitempty"`
+	Compensated        []string `json:"compensated,omitempty"`
+	CompensationFailed []string `json:"compensationFailed,omitempty"`
+}
+
+// Saga statuses reported in SagaResult.Status.
+const (
+	SagaCompleted   = "completed"
+	SagaCompensated = "compensated"
+	// SagaCompensationFailed means at least one compensation exhausted its retries and
+	// the saga could not be fully rolled back.
+	SagaCompensationFailed = "compensation_failed"
+)
+
+// Saga runs steps in order on a workflow context and, when one fails, runs the
+// compensations of the completed steps in reverse order.
+type Saga struct {
+	ctx    *workflow.WorkflowContext
+	done   []SagaStep
+	result SagaResult
+}
+
+// NewSaga returns a saga bound to the given workflow context.
+func NewSaga(ctx *workflow.WorkflowContext) *Saga {
+	return &Saga{ctx: ctx}
+}
+
+// Step runs the forward activity of step and stores its output in out (which may be nil).
+// On failure the saga is compensated and the step error is returned; the caller should
+// stop issuing steps and return Result().
+func (s *Saga) Step(step SagaStep, out any) error {
+	var opts []workflow.CallActivityOption
+	if step.RetryPolicy != nil {
+		opts = append(opts, workflow.WithActivityRetryPolicy(step.RetryPolicy))
+	}
+
+	if err := callActivity(s.ctx, step.Activity, step.Input, opts...).Await(out); err != nil {
+		s.result.FailedStep = step.Name
+		s.result.Error = err.Error()
+		s.compensate()
+		return fmt.Errorf("saga step %s: %w", step.Name, err)
+	}
+
+	s.done = append(s.done, step)
+	s.result.Completed = append(s.result.Completed, step.Name)
+	return nil
+}
+
+// Result returns the saga outcome. It is only meaningful once all steps ran or one failed.
+func (s *Saga) Result() SagaResult {
+	if s.result.Status == "" {
+		s.result.Status = SagaCompleted
+	}
+	return s.result
+}
+
+func (s *Saga) compensate() {
+	s.result.Status = SagaCompensated
+
+	for i := len(s.done) - 1; i >= 0; i-- {
+		step := s.done[i]
+		if step.Compensation == nil {
+			continue
+		}
+
+		input := step.CompensationInput
+		if input == nil {
+			input = step.Input
+		}
+
+		var opts []workflow.CallActivityOption
+		if step.CompensationRetryPolicy != nil {
+			opts = append(opts, workflow.WithActivityRetryPolicy(step.CompensationRetryPolicy))
+		}
+
+		if err := callActivity(s.ctx, step.Compensation, input, opts...).Await(nil); err != nil {
+			if !s.ctx.IsReplaying() {
+				log.Errorf("saga %s: compensation of step %s failed: %v", s.ctx.ID(), step.Name, err)
+			}
+			s.result.Status = SagaCompensationFailed
+			s.result.CompensationFailed = append(s.result.CompensationFailed, step.Name)
+			continue
+		}
+		s.result.Compensated = append(s.result.Compensated, step.Name)
+	}
+}
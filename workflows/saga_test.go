@@ -0,0 +1,137 @@
+package workflows
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dapr/durabletask-go/api/protos"
+	"github.com/dapr/durabletask-go/workflow"
+)
+
+// sagaTestWorkflow runs four steps: a, b, which has no compensation, c with its own
+// compensation input, and d.
+func sagaTestWorkflow(ctx *workflow.WorkflowContext) (any, error) {
+	saga := NewSaga(ctx)
+	for _, step := range []SagaStep{
+		{Name: "a", Activity: "DoA", Input: "a", Compensation: "UndoA"},
+		{Name: "b", Activity: "DoB", Input: "b"},
+		{Name: "c", Activity: "DoC", Input: "c", Compensation: "UndoC", CompensationInput: "undo c"},
+		{Name: "d", Activity: "DoD", Input: "d", Compensation: "UndoD"},
+	} {
+		if err := saga.Step(step, nil); err != nil {
+			break
+		}
+	}
+	return saga.Result(), nil
+}
+
+// sagaResult returns the SagaResult the workflow completed with.
+func sagaResult(t *testing.T, o *orchestration) SagaResult {
+	t.Helper()
+	if o.result.GetOrchestrationStatus() != protos.OrchestrationStatus_ORCHESTRATION_STATUS_COMPLETED {
+		t.Fatalf("workflow ended %s: %v", o.result.GetOrchestrationStatus(), o.result.GetFailureDetails())
+	}
+	var r SagaResult
+	if err := json.Unmarshal([]byte(o.result.GetResult().GetValue()), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSagaCompensatesInReverseOrder(t *testing.T) {
+	o := newOrchestration(t, "SagaTest", sagaTestWorkflow)
+	o.start("SagaTest", nil)
+	for id := int32(0); id < 3; id++ {
+		o.complete(id, nil)
+	}
+	o.fail(3, errors.New("out of stock"))
+
+	// Compensations run one at a time, from the last completed step back; b has none.
+	expectPending(t, o, "UndoC")
+	o.complete(4, nil)
+	expectPending(t, o, "UndoA")
+	o.complete(5, nil)
+
+	want := []string{`0 DoA("a")`, `1 DoB("b")`, `2 DoC("c")`, `3 DoD("d")`, `4 UndoC("undo c")`, `5 UndoA("a")`}
+	if got := o.scheduled(); !reflect.DeepEqual(got, want) {
+		t.Errorf("scheduled %v, want %v", got, want)
+	}
+	r := sagaResult(t, o)
+	wantResult := SagaResult{
+		Status:      SagaCompensated,
+		Completed:   []string{"a", "b", "c"},
+		FailedStep:  "d",
+		Error:       r.Error,
+		Compensated: []string{"c", "a"},
+	}
+	if !reflect.DeepEqual(r, wantResult) || r.Error == "" {
+		t.Errorf("result %+v, want %+v", r, wantResult)
+	}
+}
+
+func TestSagaCompensationFailure(t *testing.T) {
+	o := newOrchestration(t, "SagaTest", sagaTestWorkflow)
+	o.start("SagaTest", nil)
+	o.complete(0, nil)
+	o.complete(1, nil)
+	o.complete(2, nil)
+	o.fail(3, errors.New("out of stock"))
+
+	// A failed compensation does not stop the ones before it.
+	o.fail(4, errors.New("refund service down"))
+	expectPending(t, o, "UndoA")
+	o.complete(5, nil)
+
+	r := sagaResult(t, o)
+	if r.Status != SagaCompensationFailed ||
+		!reflect.DeepEqual(r.CompensationFailed, []string{"c"}) || !reflect.DeepEqual(r.Compensated, []string{"a"}) {
+		t.Errorf("result %+v, want c failed and a compensated", r)
+	}
+}
+
+func TestSagaCompletes(t *testing.T) {
+	o := newOrchestration(t, "SagaTest", sagaTestWorkflow)
+	o.start("SagaTest", nil)
+	for id := int32(0); id < 4; id++ {
+		o.complete(id, nil)
+	}
+
+	if r := sagaResult(t, o); r.Status != SagaCompleted || len(r.Completed) != 4 || r.Compensated != nil {
+		t.Errorf("result %+v, want every step completed", r)
+	}
+	if len(o.scheduled()) != 4 {
+		t.Errorf("scheduled %v, want no compensation", o.scheduled())
+	}
+}
+
+func TestOrderSagaWorkflow(t *testing.T) {
+	for _, tc := range []struct {
+		failAt string
+		// steps are the activities run, in order, and the one named failAt fails.
+		steps      []string
+		wantStatus string
+	}{
+		{steps: []string{"ReserveInventory", "ChargePayment", "ShipOrder"}, wantStatus: SagaCompleted},
+		{failAt: "ReserveInventory", steps: []string{"ReserveInventory"}, wantStatus: SagaCompensated},
+		{failAt: "ChargePayment", steps: []string{"ReserveInventory", "ChargePayment", "ReleaseInventory"}, wantStatus: SagaCompensated},
+		{failAt: "ShipOrder", steps: []string{"ReserveInventory", "ChargePayment", "ShipOrder", "RefundPayment", "ReleaseInventory"},
+			wantStatus: SagaCompensated},
+	} {
+		o := newOrchestration(t, "OrderSagaWorkflow", OrderSagaWorkflow)
+		o.start("OrderSagaWorkflow", OrderInput{OrderID: "order-1", Item: "book", Quantity: 1, Amount: 10})
+		for id, name := range tc.steps {
+			expectPending(t, o, name)
+			if name == tc.failAt {
+				o.fail(int32(id), errors.New(name+" failed"))
+			} else {
+				o.complete(int32(id), nil)
+			}
+		}
+
+		if r := sagaResult(t, o); r.Status != tc.wantStatus {
+			t.Errorf("failing %q: status %s, want %s", tc.failAt, r.Status, tc.wantStatus)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package workflows
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -15,79 +17,133 @@ import (
 var log = logger.NewLogger("workflows.simple_workflow")
 var tracer = otel.Tracer("workflows.simple_workflow")
 
+// Progress is the structured custom status published by SimpleWorkflow.
+type Progress struct {
+	Step            string `json:"step"`
+	PercentComplete int    `json:"percentComplete"`
+	WaitingFor      string `json:"waitingFor,omitempty"`
+}
+
+// setProgress publishes p as the JSON custom status of the workflow.
+func setProgress(ctx *workflow.WorkflowContext, p Progress) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encode progress: %w", err)
+	}
+	ctx.SetCustomStatus(string(b))
+	return nil
+}
+
+// SimpleWorkflowInput is the optional input of SimpleWorkflow.
+type SimpleWorkflowInput struct {
+	// CustomerID keeps instances for the same customer out of each other's critical
+	// section, Activity1 and Activity2.
+	CustomerID string `json:"customerId,omitempty"`
+}
+
 // OrderWorkflow is a sample workflow function.
 func SimpleWorkflow(ctx *workflow.WorkflowContext) (any, error) {
-	if err := ctx.CallActivity(Activity1).Await(nil); err != nil {
-		return nil, err
+	var in SimpleWorkflowInput
+	// Inputs that are not objects simply have no customer.
+	_ = ctx.GetInput(&in)
+
+	if in.CustomerID == "" {
+		if err := simpleCriticalSection(ctx, nil); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := setProgress(ctx, Progress{Step: "AcquireLock", PercentComplete: 0}); err != nil {
+			return nil, err
+		}
+		err := WithLock(ctx, "customer/"+in.CustomerID, LockOptions{}, func(l *Lease) error {
+			return simpleCriticalSection(ctx, l)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if err := ctx.CallActivity(Activity2).Await(nil); err != nil {
+	if err := setProgress(ctx, Progress{Step: "WaitForEvent", PercentComplete: 50, WaitingFor: "event"}); err != nil {
 		return nil, err
 	}
-
 	if err := ctx.WaitForExternalEvent("event", time.Minute*5).Await(nil); err != nil {
 		return nil, err
 	}
 
+	if err := setProgress(ctx, Progress{Step: "ChildWorkflow", PercentComplete: 75}); err != nil {
+		return nil, err
+	}
 	if err := ctx.CallChildWorkflow(ChildWorkflow).Await(nil); err != nil {
 		return nil, err
 	}
 
+	if err := setProgress(ctx, Progress{Step: "Completed", PercentComplete: 100}); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
+// noLockRenewalPatch marks the instances that do not renew the lease of their critical
+// section: Dapr lock components cannot renew leases, and the default lease of a minute
+// covers the section.
+const noLockRenewalPatch = "no-lock-renewal"
+
+// simpleCriticalSection runs Activity1 and Activity2. Instances that renewed the lease l
+// between them, before noLockRenewalPatch, still do when they replay.
+func simpleCriticalSection(ctx *workflow.WorkflowContext, l *Lease) error {
+	if err := setProgress(ctx, Progress{Step: "Activity1", PercentComplete: 0}); err != nil {
+		return err
+	}
+	if err := callActivity(ctx, Activity1, nil).Await(nil); err != nil {
+		return err
+	}
+
+	if l != nil && !ctx.IsPatched(noLockRenewalPatch) {
+		if err := l.Renew(); err != nil {
+			return err
+		}
+	}
+	if err := setProgress(ctx, Progress{Step: "Activity2", PercentComplete: 25}); err != nil {
+		return err
+	}
+	return callActivity(ctx, Activity2, nil).Await(nil)
+}
+
 func ChildWorkflow(ctx *workflow.WorkflowContext) (any, error) {
-	if err := ctx.CallActivity(Activity3).Await(nil); err != nil {
+	if err := callActivity(ctx, Activity3, nil).Await(nil); err != nil {
 		return nil, err
 	}
 
 	return nil, nil
 }
 
+// Activity1, Activity2 and Activity3 simulate work. Logging, spans, timeouts and panic
+// recovery are added by the interceptors applied at registration.
 func Activity1(ctx workflow.ActivityContext) (any, error) {
-	log.Infof("Activity 1 called with traceparent: %s", ctx.GetTraceContext().TraceParent)
-	_, childSpan := tracer.Start(ctx.Context(), "Custom||Activity1")
-	defer childSpan.End()
-
 	time.Sleep(1 * time.Second)
-
-	log.Info("Activity 1 finished")
 	return nil, nil
 }
 
 func Activity2(ctx workflow.ActivityContext) (any, error) {
-	log.Infof("Activity 2 called with traceparent: %s", ctx.GetTraceContext().TraceParent)
-	// If you had a traceparent in input or context, you could log it here, e.g.:
-	// log.Printf("Traceparent: %s", input.TraceParent)
-	_, childSpan := tracer.Start(ctx.Context(), "Custom||Activity2")
-	defer childSpan.End()
 	time.Sleep(1 * time.Second)
-
-	log.Info("Activity 2 finished")
 	return nil, nil
 }
 
+// Activity3 also shows how to continue the workflow trace from the W3C traceparent alone,
+// as code that only receives the header, such as another process, would.
 func Activity3(ctx workflow.ActivityContext) (any, error) {
-	log.Infof("Activity 3 called with traceparent: %s", ctx.GetTraceContext().TraceParent)
-	// If you had a traceparent in input or context, you could log it here, e.g.:
-	// log.Printf("Traceparent: %s", input.TraceParent)
-
-	// Create a new context
-	newCtx := context.Background()
-
 	// Create a TextMapCarrier with the traceparent
 	carrier := propagation.MapCarrier{}
-	carrier.Set("traceparent", ctx.GetTraceContext().TraceParent)
+	carrier.Set("traceparent", ctx.GetTraceContext().GetTraceParent())
 
-	// Use the TraceContext propagator to extract the trace context
+	// Use the TraceContext propagator to extract the trace context into a new context
 	propagator := propagation.TraceContext{}
-	newCtx = propagator.Extract(newCtx, carrier)
+	newCtx := propagator.Extract(context.Background(), carrier)
 
-	_, childSpan := tracer.Start(newCtx, "Custom||Activity3")
+	_, childSpan := tracer.Start(newCtx, "Custom||Activity3||traceparent")
 	defer childSpan.End()
 
 	time.Sleep(1 * time.Second)
-
-	log.Info("Activity 3 finished")
 	return nil, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,237 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/state"
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// StateKeyInput is the input of GetState and DeleteState. DeleteState only deletes the key
+// if ETag, when set, is the stored one.
+type StateKeyInput struct {
+	Key  string `json:"key"`
+	ETag string `json:"etag,omitempty"`
+}
+
+// StateKeysInput is the input of GetBulkState.
+type StateKeysInput struct {
+	Keys []string `json:"keys"`
+}
+
+// SaveStateInput is the input of SaveState. The value is only saved if ETag, when set, is
+// the stored one.
+type SaveStateInput struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+	ETag  string          `json:"etag,omitempty"`
+}
+
+// UpdateStateInput is the input of UpdateState. Patch is a JSON merge patch (RFC 7386):
+// its fields replace those of the stored object, and null fields remove them.
+type UpdateStateInput struct {
+	Key   string          `json:"key"`
+	Patch json.RawMessage `json:"patch"`
+	// MaxAttempts bounds the attempts made when other writers save the key meanwhile.
+	// Defaults to 5.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+const defaultUpdateAttempts = 5
+
+// GetStateValue reads key into v through GetState and returns its ETag, for a later
+// SaveStateValue. found is false, and v left alone, when the key does not exist.
+func GetStateValue(ctx *workflow.WorkflowContext, key string, v any) (etag string, found bool, err error) {
+	var item state.Item
+	if err := callActivity(ctx, GetState, StateKeyInput{Key: key}).Await(&item); err != nil {
+		return "", false, err
+	}
+	if item.Value == nil {
+		return "", false, nil
+	}
+	if err := json.Unmarshal(item.Value, v); err != nil {
+		return "", false, fmt.Errorf("decode state %s: %w", key, err)
+	}
+	return item.ETag, true, nil
+}
+
+// SaveStateValue saves v as the value of key through SaveState. A non-empty etag makes the
+// save fail, without retries, if another writer saved the key since it was read.
+func SaveStateValue(ctx *workflow.WorkflowContext, key string, v any, etag string) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode state %s: %w", key, err)
+	}
+	return callActivity(ctx, SaveState, SaveStateInput{Key: key, Value: b, ETag: etag}).Await(nil)
+}
+
+// GetState returns the item of a key. Keys are scoped to the tenant of the calling instance.
+func GetState(ctx workflow.ActivityContext) (any, error) {
+	var in StateKeyInput
+	store, err := stateInput(ctx, &in, func() error { return checkKey(in.Key) })
+	if err != nil {
+		return nil, err
+	}
+	item, err := store.Get(ctx.Context(), scopedKey(ctx.Context(), in.Key))
+	if err != nil {
+		return nil, err
+	}
+	item.Key = in.Key
+	return item, nil
+}
+
+// GetBulkState returns the items of several keys, in order.
+func GetBulkState(ctx workflow.ActivityContext) (any, error) {
+	var in StateKeysInput
+	store, err := stateInput(ctx, &in, func() error {
+		for _, key := range in.Keys {
+			if err := checkKey(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(in.Keys))
+	for i, key := range in.Keys {
+		keys[i] = scopedKey(ctx.Context(), key)
+	}
+	items, err := store.GetBulk(ctx.Context(), keys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Key = in.Keys[i]
+	}
+	return items, nil
+}
+
+// SaveState saves the value of a key. A stale ETag is not retried, since it stays stale.
+func SaveState(ctx workflow.ActivityContext) (any, error) {
+	var in SaveStateInput
+	store, err := stateInput(ctx, &in, func() error {
+		if err := checkKey(in.Key); err != nil {
+			return err
+		}
+		if len(in.Value) == 0 || string(in.Value) == "null" {
+			return errors.New("state needs a value; delete the key instead")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = store.Save(ctx.Context(), state.Item{Key: scopedKey(ctx.Context(), in.Key), Value: in.Value, ETag: in.ETag})
+	return nil, stateError(err)
+}
+
+// DeleteState deletes a key. A stale ETag is not retried, since it stays stale.
+func DeleteState(ctx workflow.ActivityContext) (any, error) {
+	var in StateKeyInput
+	store, err := stateInput(ctx, &in, func() error { return checkKey(in.Key) })
+	if err != nil {
+		return nil, err
+	}
+	return nil, stateError(store.Delete(ctx.Context(), scopedKey(ctx.Context(), in.Key), in.ETag))
+}
+
+// UpdateState applies a merge patch to the value of a key, reading and saving it with its
+// ETag until no other writer saved the key in between. It returns the item it saved.
+func UpdateState(ctx workflow.ActivityContext) (any, error) {
+	var in UpdateStateInput
+	var patch any
+	store, err := stateInput(ctx, &in, func() error {
+		if err := checkKey(in.Key); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(in.Patch, &patch); err != nil {
+			return fmt.Errorf("invalid patch: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := in.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultUpdateAttempts
+	}
+	v, err := state.Update(ctx.Context(), store, scopedKey(ctx.Context(), in.Key), attempts, func(item state.Item) (json.RawMessage, error) {
+		var current any
+		if item.Value != nil {
+			if err := json.Unmarshal(item.Value, &current); err != nil {
+				return nil, NonRetryable(fmt.Errorf("decode state %s: %w", in.Key, err))
+			}
+		}
+		return json.Marshal(mergePatch(current, patch))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state.Item{Key: in.Key, Value: v}, nil
+}
+
+// stateInput decodes the input of a state activity into in and checks it, and returns the
+// installed store. Invalid inputs and a missing store are not retried.
+func stateInput(ctx workflow.ActivityContext, in any, check func() error) (state.Store, error) {
+	if err := ctx.GetInput(in); err != nil {
+		return nil, NonRetryable(fmt.Errorf("decode input: %w", err))
+	}
+	if err := check(); err != nil {
+		return nil, NonRetryable(err)
+	}
+	store, err := state.Current()
+	if err != nil {
+		return nil, NonRetryable(err)
+	}
+	return store, nil
+}
+
+func checkKey(key string) error {
+	if key == "" {
+		return errors.New("state needs a key")
+	}
+	return nil
+}
+
+// scopedKey prefixes key with the tenant of ctx, the way instance IDs are, so tenants do not
+// see each other's state.
+func scopedKey(ctx context.Context, key string) string {
+	return tenant.InstanceID(tenant.FromContext(ctx), key)
+}
+
+// stateError marks ETag mismatches as non-retryable.
+func stateError(err error) error {
+	if errors.Is(err, state.ErrETagMismatch) {
+		return NonRetryable(err)
+	}
+	return err
+}
+
+// mergePatch applies a JSON merge patch to target.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
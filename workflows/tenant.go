@@ -0,0 +1,63 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dapr/durabletask-go/workflow"
+
+	"github.com/javier-aliaga/dapr-go-samples/tenant"
+)
+
+// tenantInput wraps the input of activities scheduled by tenant-scoped instances, since
+// activities do not see the instance ID. The field names are unlikely to clash with a
+// regular input.
+type tenantInput struct {
+	Tenant string `json:"$tenant"`
+	Input  any    `json:"$input,omitempty"`
+}
+
+// TenantInterceptor unwraps inputs wrapped by tenant-scoped instances, so activities read
+// their own input, and puts the tenant in the baggage of the activity context.
+func TenantInterceptor() ActivityInterceptor {
+	return func(name string, next workflow.Activity) workflow.Activity {
+		return func(ctx workflow.ActivityContext) (any, error) {
+			var raw json.RawMessage
+			if err := ctx.GetInput(&raw); err != nil || len(raw) == 0 || raw[0] != '{' {
+				return next(ctx)
+			}
+
+			var in struct {
+				Tenant string          `json:"$tenant"`
+				Input  json.RawMessage `json:"$input"`
+			}
+			if err := json.Unmarshal(raw, &in); err != nil || in.Tenant == "" {
+				return next(ctx)
+			}
+
+			return next(tenantActivityContext{
+				ActivityContext: ctx,
+				ctx:             tenant.WithContext(ctx.Context(), in.Tenant),
+				input:           in.Input,
+			})
+		}
+	}
+}
+
+// tenantActivityContext replaces the context and input of an ActivityContext.
+type tenantActivityContext struct {
+	workflow.ActivityContext
+	ctx   context.Context
+	input json.RawMessage
+}
+
+func (a tenantActivityContext) Context() context.Context {
+	return a.ctx
+}
+
+func (a tenantActivityContext) GetInput(v any) error {
+	if len(a.input) == 0 {
+		return nil
+	}
+	return json.Unmarshal(a.input, v)
+}